@@ -1,15 +1,27 @@
 package db
 
 import (
+	"errors"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
 )
 
 var (
-	Open              = gorm.Open
-	ErrRecordNotFound = gorm.ErrRecordNotFound
-	Expr              = gorm.Expr
+	Open                  = gorm.Open
+	ErrRecordNotFound     = gorm.ErrRecordNotFound
+	ErrMissingWhereClause = gorm.ErrMissingWhereClause
+	Expr                  = gorm.Expr
+
+	// ErrStaleModel 乐观锁冲突：记录已经被其他人修改（version不匹配）
+	ErrStaleModel = errors.New("stale model: the record has been modified by others")
+)
+
+const (
+	// VersionColumn 乐观锁的数据库字段名
+	VersionColumn = "version"
+	// DeletedAtColumn 软删除的数据库字段名
+	DeletedAtColumn = "deleted_at"
 )
 
 type (
@@ -6,12 +6,16 @@ import (
 )
 
 type (
-	Locking    = clause.Locking
-	Expression = clause.Expression
-	Table      = clause.Table
-	Returning  = clause.Returning
-	Column     = clause.Column
-	Join       = clause.Join
+	Locking       = clause.Locking
+	Expression    = clause.Expression
+	Table         = clause.Table
+	Returning     = clause.Returning
+	Column        = clause.Column
+	Join          = clause.Join
+	OnConflict    = clause.OnConflict
+	Eq            = clause.Eq
+	Neq           = clause.Neq
+	OrderByColumn = clause.OrderByColumn
 )
 
 const (
@@ -19,3 +23,7 @@ const (
 	Read         = dbresolver.Read
 	Write        = dbresolver.Write
 )
+
+var (
+	AssignmentColumns = clause.AssignmentColumns
+)
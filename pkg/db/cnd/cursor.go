@@ -0,0 +1,97 @@
+package cnd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrCursorSecretNotSet 没有调用SetCursorSecret设置游标签名的密钥
+	ErrCursorSecretNotSet = errors.New("cursor secret is not set, call cnd.SetCursorSecret first")
+)
+
+var (
+	cursorSecret   []byte
+	cursorSecretMu sync.RWMutex
+)
+
+// SetCursorSecret 设置游标签名的密钥，集群中的所有节点必须一致（否则其它节点签发的游标会返回ErrInvalidCursor），
+// 需要在程序启动时设置，没有设置时EncodeCursor、DecodeCursor返回ErrCursorSecretNotSet。密钥为空时不做修改
+func SetCursorSecret(secret []byte) {
+	if len(secret) == 0 {
+		return
+	}
+	cursorSecretMu.Lock()
+	defer cursorSecretMu.Unlock()
+	cursorSecret = secret
+}
+
+// CheckCursorSecret 检查是否已设置游标签名的密钥，没有设置时返回ErrCursorSecretNotSet
+func CheckCursorSecret() error {
+	cursorSecretMu.RLock()
+	defer cursorSecretMu.RUnlock()
+	if len(cursorSecret) == 0 {
+		return ErrCursorSecretNotSet
+	}
+	return nil
+}
+
+func signCursor(payload string) (string, error) {
+	cursorSecretMu.RLock()
+	defer cursorSecretMu.RUnlock()
+	if len(cursorSecret) == 0 {
+		return "", ErrCursorSecretNotSet
+	}
+
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// EncodeCursor 将排序字段的值编码为不透明的、带签名的游标
+// 格式为：base64(json(values)).base64(hmac)，没有设置密钥时返回ErrCursorSecretNotSet
+func EncodeCursor(values ...any) (string, error) {
+	j, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(j)
+	signature, err := signCursor(payload)
+	if err != nil {
+		return "", err
+	}
+	return payload + "." + signature, nil
+}
+
+// DecodeCursor 校验游标的签名，并返回排序字段的值（json原文，需要调用方根据字段类型再次解析）
+// 签名不合法或格式错误时返回ErrInvalidCursor，没有设置密钥时返回ErrCursorSecretNotSet
+func DecodeCursor(cursor string) ([]json.RawMessage, error) {
+	payload, signature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	expected, err := signCursor(payload)
+	if err != nil {
+		return nil, err
+	} else if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return nil, ErrInvalidCursor
+	}
+
+	j, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var values []json.RawMessage
+	if err = json.Unmarshal(j, &values); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return values, nil
+}
@@ -0,0 +1,41 @@
+package cnd
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCursorSecretRequired(t *testing.T) {
+	cursorSecretMu.Lock()
+	secret := cursorSecret
+	cursorSecret = nil
+	cursorSecretMu.Unlock()
+	defer SetCursorSecret(secret)
+
+	if _, err := EncodeCursor(1); !errors.Is(err, ErrCursorSecretNotSet) {
+		t.Fatalf("expected ErrCursorSecretNotSet, got %v", err)
+	}
+	if _, err := DecodeCursor("a.b"); !errors.Is(err, ErrCursorSecretNotSet) {
+		t.Fatalf("expected ErrCursorSecretNotSet, got %v", err)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	SetCursorSecret([]byte("cursor-test-secret"))
+
+	cursor, err := EncodeCursor(10, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := DecodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(values) != 2 || string(values[0]) != "10" || string(values[1]) != `"a"` {
+		t.Fatalf("unexpected values %s", values)
+	}
+
+	// 篡改内容后签名不再匹配
+	if _, err = DecodeCursor("W10" + cursor[3:]); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
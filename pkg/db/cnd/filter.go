@@ -1,9 +1,12 @@
 package cnd
 
 import (
+	"errors"
 	"fmt"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/utils"
+	"regexp"
+	"strings"
 )
 
 type Operators map[string]Operator
@@ -28,7 +31,114 @@ const (
 	OperatorPageSize   Operator = "page_size"
 )
 
+var (
+	ErrInvalidFilter       = errors.New("invalid filter")
+	ErrUnknownColumn       = errors.New("unknown filter column")
+	ErrInvalidColumn       = errors.New("invalid column name")
+	ErrUnsupportedOperator = errors.New("unsupported filter operator")
+)
+
+// operatorAliases 请求中可以使用的操作符别名，比如：{"field": "age", "op": "gte", "value": 18}
+var operatorAliases = map[string]Operator{
+	"eq":          OperatorEq,
+	"ne":          OperatorNe,
+	"neq":         OperatorNe,
+	"!=":          OperatorNe,
+	"gt":          OperatorGt,
+	"ge":          OperatorGe,
+	"gte":         OperatorGe,
+	"lt":          OperatorLt,
+	"le":          OperatorLe,
+	"lte":         OperatorLe,
+	"not_in":      OperatorNotIn,
+	"nin":         OperatorNotIn,
+	"not_between": OperatorNotBetween,
+	"is_null":     OperatorIsNull,
+	"is_not_null": OperatorIsNotNull,
+}
+
+// ParseOperator 将字符串解析为Operator，支持Operator本身的值（比如">="）和别名（比如"gte"）
+func ParseOperator(s string) (Operator, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch op := Operator(s); op {
+	case OperatorEq, OperatorNe, OperatorGt, OperatorGe, OperatorLt, OperatorLe, OperatorLike,
+		OperatorIn, OperatorNotIn, OperatorBetween, OperatorNotBetween, OperatorIsNull, OperatorIsNotNull:
+		return op, true
+	}
+	op, ok := operatorAliases[s]
+	return op, ok
+}
+
+// columnNameRegexp 合法的字段名：column 或 table.column
+var columnNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// IsValidColumnName 检查字段名是否合法，防止字段名拼接到SQL中导致注入
+func IsValidColumnName(column string) bool {
+	return columnNameRegexp.MatchString(column)
+}
+
+// buildCondition 构建单个字段的查询条件，column必须是已经校验过的字段名
+func buildCondition(column string, operator Operator, value any) (string, []any, error) {
+	switch operator {
+	case OperatorEq, OperatorNe, OperatorGt, OperatorGe, OperatorLt, OperatorLe:
+		return column + " " + string(operator) + " ?", []any{value}, nil
+	case OperatorIn, OperatorNotIn:
+		values := toSlice(value)
+		if len(values) == 0 {
+			return "", nil, fmt.Errorf("%w: \"%s\" requires at least one value", ErrInvalidFilter, operator)
+		}
+		return column + " " + string(operator) + " ?", []any{values}, nil
+	case OperatorLike:
+		return column + " like ?", []any{fmt.Sprintf("%%%v%%", value)}, nil
+	case OperatorBetween, OperatorNotBetween:
+		values := toRange(value)
+		if len(values) != 2 {
+			return "", nil, fmt.Errorf("%w: \"%s\" requires exactly 2 values", ErrInvalidFilter, operator)
+		}
+		return column + " " + string(operator) + " ? and ?", values, nil
+	case OperatorIsNull, OperatorIsNotNull:
+		return column + " " + string(operator), nil, nil
+	}
+	return "", nil, fmt.Errorf("%w: \"%s\"", ErrUnsupportedOperator, operator)
+}
+
+// toSlice 将值转换为[]any，支持slice/array，以及逗号分隔的字符串
+func toSlice(value any) []any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	case string:
+		if v == "" {
+			return nil
+		}
+		return utils.StringsToInterfaces(strings.Split(v, ","))
+	}
+	if values, ok := reflectSlice(value); ok {
+		return values
+	}
+	return []any{value}
+}
+
+// toRange 将值转换为between的两个值，支持slice/array、逗号分隔的字符串，以及{"start": .., "end": ..}、{"from": .., "to": ..}、{"min": .., "max": ..}
+func toRange(value any) []any {
+	if m, ok := value.(map[string]any); ok {
+		for _, keys := range [][2]string{{"start", "end"}, {"from", "to"}, {"min", "max"}} {
+			start, ok1 := m[keys[0]]
+			end, ok2 := m[keys[1]]
+			if ok1 && ok2 {
+				return []any{start, end}
+			}
+		}
+		return nil
+	}
+	return toSlice(value)
+}
+
 // ParseQueryBuilder 解析protobuf请求参数，构建查询条件
+// columns的key为请求的字段名（同时也是数据库的字段名），不合法的字段名会被忽略。
+// 如果需要字段映射、类型转换、嵌套的AND/OR条件、排序等，请使用FilterSpec
 func ParseQueryBuilder(query *QueryBuilder, request utils.IProtobuf, columns Operators) (*QueryBuilder, *db.Pagination) {
 
 	var page, pageSize int64
@@ -42,18 +152,17 @@ func ParseQueryBuilder(query *QueryBuilder, request utils.IProtobuf, columns Ope
 		}
 
 		switch operator {
-		case OperatorEq, OperatorNe, OperatorGt, OperatorGe, OperatorLt, OperatorLe, OperatorIn, OperatorNotIn:
-			query.Where(colName+" "+string(operator)+" ?", val)
-		case OperatorLike:
-			query.Where(colName+" "+string(operator)+" ?", fmt.Sprintf("%%%s%%", val))
-		case OperatorBetween, OperatorNotBetween:
-
-		case OperatorIsNull, OperatorIsNotNull:
-			query.Where(colName + " " + string(operator))
 		case OperatorPage:
 			page = utils.ToInt64(val)
 		case OperatorPageSize:
 			pageSize = utils.ToInt64(val)
+		default:
+			if !IsValidColumnName(colName) {
+				continue
+			}
+			if sql, args, err := buildCondition(colName, operator, val); err == nil {
+				query.Where(sql, args...)
+			}
 		}
 	}
 	return query, db.NewPagination(int(page), int(pageSize))
@@ -0,0 +1,396 @@
+package cnd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/utils"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ColumnType int
+
+const (
+	ColumnTypeAny ColumnType = iota // 不转换
+	ColumnTypeString
+	ColumnTypeInt
+	ColumnTypeUint
+	ColumnTypeFloat
+	ColumnTypeBool
+	ColumnTypeTime // 支持RFC3339、"2006-01-02 15:04:05"、"2006-01-02"、unix时间戳（秒）
+)
+
+// FilterField 允许被过滤的字段
+type FilterField struct {
+	// Column 数据库字段名，为空时使用请求中的字段名
+	Column string
+	// Type 值的类型，会将请求的值转换为该类型，转换失败会返回ErrInvalidFilter
+	Type ColumnType
+	// Operators 允许的操作符，第一个为默认操作符（请求中直接传字段值时使用）。为空表示只允许OperatorEq
+	Operators []Operator
+}
+
+func (f FilterField) defaultOperator() Operator {
+	if len(f.Operators) == 0 {
+		return OperatorEq
+	}
+	return f.Operators[0]
+}
+
+func (f FilterField) allow(operator Operator) bool {
+	if len(f.Operators) == 0 {
+		return operator == OperatorEq
+	}
+	return lo.Contains(f.Operators, operator)
+}
+
+// Filter 结构化的过滤条件，叶子节点使用Field/Operator/Value，分组节点使用And或Or
+//
+//	{"or": [{"field": "status", "op": "in", "value": [1, 2]}, {"and": [{"field": "age", "op": "between", "value": [18, 30]}, ...]}]}
+type Filter struct {
+	Field    string   `json:"field,omitempty"`
+	Operator string   `json:"op,omitempty"`
+	Value    any      `json:"value,omitempty"`
+	And      []Filter `json:"and,omitempty"`
+	Or       []Filter `json:"or,omitempty"`
+}
+
+// FilterSpec 过滤条件的白名单，用于将请求参数（protobuf/map）安全地转换为QueryBuilder
+//
+//	spec := &cnd.FilterSpec{
+//		Fields: map[string]cnd.FilterField{
+//			"name":       {Operators: []cnd.Operator{cnd.OperatorLike, cnd.OperatorEq}},
+//			"created_at": {Column: "users.created_at", Type: cnd.ColumnTypeTime, Operators: []cnd.Operator{cnd.OperatorBetween}},
+//		},
+//		Sorts: map[string]string{"created_at": "users.created_at", "id": "users.id"},
+//	}
+//	query, pagination, err := spec.ParseProtobuf(cnd.NewQueryBuilder(), req)
+type FilterSpec struct {
+	// Fields 允许过滤的字段，key为请求中的字段名
+	Fields map[string]FilterField
+	// Sorts 允许排序的字段，key为请求中的字段名，value为数据库字段名（为空时使用key）
+	Sorts map[string]string
+
+	// FilterKey 结构化过滤条件（Filter）在请求中的字段名，默认为"filter"
+	FilterKey string
+	// SortKey 排序在请求中的字段名，默认为"sort"。值为"-created_at,id"或["-created_at", "id"]，"-"表示降序
+	SortKey string
+	// PageKey 页码在请求中的字段名，默认为"page"
+	PageKey string
+	// PageSizeKey 每页条数在请求中的字段名，默认为"page_size"
+	PageSizeKey string
+	// MaxPageSize 每页最大条数，为0表示不限制
+	MaxPageSize int
+}
+
+func (s *FilterSpec) key(key, defaultKey string) string {
+	if key == "" {
+		return defaultKey
+	}
+	return key
+}
+
+// field 获取白名单中的字段，并返回数据库字段名
+func (s *FilterSpec) field(name string) (FilterField, string, error) {
+	field, ok := s.Fields[name]
+	if !ok {
+		return field, "", fmt.Errorf("%w: \"%s\"", ErrUnknownColumn, name)
+	}
+	column := s.key(field.Column, name)
+	if !IsValidColumnName(column) {
+		return field, "", fmt.Errorf("%w: \"%s\"", ErrInvalidColumn, column)
+	}
+	return field, column, nil
+}
+
+// ParseProtobuf 解析protobuf请求参数，构建查询条件和分页。支持嵌套的message（比如filter字段）。
+// 字段名使用proto中定义的名字；注意：proto3非optional的字段为零值时，视为未设置
+func (s *FilterSpec) ParseProtobuf(query *QueryBuilder, request utils.IProtobuf) (*QueryBuilder, *db.Pagination, error) {
+	if request == nil {
+		return s.Parse(query, nil)
+	}
+
+	j, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(request.ProtoReflect().Interface())
+	if err != nil {
+		return query, nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+
+	var input map[string]any
+	if err = json.Unmarshal(j, &input); err != nil {
+		return query, nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	return s.Parse(query, input)
+}
+
+// Parse 解析请求参数，构建查询条件和分页
+//
+//  1. input中的key如果在Fields中，则使用该字段的默认操作符构建条件
+//  2. FilterKey对应的值为结构化的Filter（map、[]map或json字符串），支持嵌套的AND/OR
+//  3. SortKey、PageKey、PageSizeKey分别为排序、页码、每页条数
+//  4. 其它的key会被忽略；但是Filter/Sort中出现不在白名单中的字段，会返回ErrUnknownColumn
+func (s *FilterSpec) Parse(query *QueryBuilder, input map[string]any) (*QueryBuilder, *db.Pagination, error) {
+	var page, pageSize int64
+	filterKey, sortKey := s.key(s.FilterKey, "filter"), s.key(s.SortKey, "sort")
+	pageKey, pageSizeKey := s.key(s.PageKey, "page"), s.key(s.PageSizeKey, "page_size")
+
+	// 按key排序，保证生成的SQL稳定
+	keys := lo.Keys(input)
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := input[key]
+		if value == nil || value == "" {
+			continue
+		}
+
+		switch key {
+		case pageKey:
+			page = utils.ToInt64(value)
+		case pageSizeKey:
+			pageSize = utils.ToInt64(value)
+		case sortKey:
+			if err := s.applySorts(query, value); err != nil {
+				return query, nil, err
+			}
+		case filterKey:
+			filters, err := parseFilters(value)
+			if err != nil {
+				return query, nil, err
+			}
+			sql, args, err := s.compile(Filter{And: filters})
+			if err != nil {
+				return query, nil, err
+			} else if sql != "" {
+				query.Where("("+sql+")", args...)
+			}
+		default:
+			if _, ok := s.Fields[key]; !ok {
+				continue
+			}
+			sql, args, err := s.compileLeaf(Filter{Field: key, Value: value})
+			if err != nil {
+				return query, nil, err
+			}
+			query.Where(sql, args...)
+		}
+	}
+
+	if s.MaxPageSize > 0 && pageSize > int64(s.MaxPageSize) {
+		pageSize = int64(s.MaxPageSize)
+	}
+	return query, db.NewPagination(int(page), int(pageSize)), nil
+}
+
+// applySorts 解析排序，"-"前缀表示降序
+func (s *FilterSpec) applySorts(query *QueryBuilder, value any) error {
+	for _, item := range toSlice(value) {
+		name := strings.TrimSpace(utils.ToString(item, false))
+		if name == "" {
+			continue
+		}
+		asc := true
+		if strings.HasPrefix(name, "-") {
+			name, asc = name[1:], false
+		} else if strings.HasPrefix(name, "+") {
+			name = name[1:]
+		}
+
+		column, ok := s.Sorts[name]
+		if !ok {
+			return fmt.Errorf("%w: sort \"%s\"", ErrUnknownColumn, name)
+		}
+		column = s.key(column, name)
+		if !IsValidColumnName(column) {
+			return fmt.Errorf("%w: \"%s\"", ErrInvalidColumn, column)
+		}
+		query.Order(column, asc)
+	}
+	return nil
+}
+
+// compile 将Filter编译为SQL条件和参数
+func (s *FilterSpec) compile(filter Filter) (string, []any, error) {
+	isGroup := len(filter.And) > 0 || len(filter.Or) > 0
+	switch {
+	case filter.Field != "" && isGroup, len(filter.And) > 0 && len(filter.Or) > 0:
+		return "", nil, fmt.Errorf("%w: a filter node must be either a field condition or an and/or group", ErrInvalidFilter)
+	case filter.Field != "":
+		return s.compileLeaf(filter)
+	}
+
+	children, glue := filter.And, " AND "
+	if len(filter.Or) > 0 {
+		children, glue = filter.Or, " OR "
+	}
+
+	var segments []string
+	var args []any
+	for _, child := range children {
+		sql, _args, err := s.compile(child)
+		if err != nil {
+			return "", nil, err
+		} else if sql == "" {
+			continue
+		}
+		segments = append(segments, sql)
+		args = append(args, _args...)
+	}
+
+	if len(segments) <= 1 {
+		return strings.Join(segments, ""), args, nil
+	}
+	return "(" + strings.Join(segments, ")"+glue+"(") + ")", args, nil
+}
+
+// compileLeaf 编译单个字段的条件：校验白名单、操作符，并转换值的类型
+func (s *FilterSpec) compileLeaf(filter Filter) (string, []any, error) {
+	field, column, err := s.field(filter.Field)
+	if err != nil {
+		return "", nil, err
+	}
+
+	operator := field.defaultOperator()
+	if filter.Operator != "" {
+		var ok bool
+		if operator, ok = ParseOperator(filter.Operator); !ok {
+			return "", nil, fmt.Errorf("%w: \"%s\"", ErrUnsupportedOperator, filter.Operator)
+		}
+	}
+	if !field.allow(operator) {
+		return "", nil, fmt.Errorf("%w: \"%s\" is not allowed for \"%s\"", ErrUnsupportedOperator, operator, filter.Field)
+	}
+
+	value, err := coerceOperatorValue(operator, field.Type, filter.Value)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: \"%s\" %v", ErrInvalidFilter, filter.Field, err)
+	}
+	return buildCondition(column, operator, value)
+}
+
+// parseFilters 解析请求中的Filter，支持map、[]map、json字符串
+func parseFilters(value any) ([]Filter, error) {
+	var j []byte
+	var err error
+	switch v := value.(type) {
+	case string:
+		j = []byte(v)
+	case []byte:
+		j = v
+	default:
+		if j, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+	}
+
+	j = []byte(strings.TrimSpace(string(j)))
+	if len(j) > 0 && j[0] == '[' {
+		var filters []Filter
+		if err = json.Unmarshal(j, &filters); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		return filters, nil
+	}
+
+	var filter Filter
+	if err = json.Unmarshal(j, &filter); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	return []Filter{filter}, nil
+}
+
+// coerceOperatorValue 根据操作符和字段类型转换值
+func coerceOperatorValue(operator Operator, columnType ColumnType, value any) (any, error) {
+	switch operator {
+	case OperatorIsNull, OperatorIsNotNull:
+		return nil, nil
+	case OperatorLike:
+		return utils.ToString(value, false), nil
+	case OperatorIn, OperatorNotIn, OperatorBetween, OperatorNotBetween:
+		var values []any
+		if operator == OperatorBetween || operator == OperatorNotBetween {
+			values = toRange(value)
+		} else {
+			values = toSlice(value)
+		}
+		results := make([]any, 0, len(values))
+		for _, v := range values {
+			_v, err := coerceValue(columnType, v)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, _v)
+		}
+		return results, nil
+	}
+	if _, ok := reflectSlice(value); ok {
+		return nil, fmt.Errorf("operator \"%s\" does not accept a list", operator)
+	}
+	return coerceValue(columnType, value)
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// coerceValue 将值转换为columnType的类型
+func coerceValue(columnType ColumnType, value any) (any, error) {
+	if value == nil {
+		return nil, fmt.Errorf("value is null")
+	}
+	if columnType == ColumnTypeAny {
+		return value, nil
+	}
+
+	s := strings.TrimSpace(utils.ToString(value, false))
+	switch columnType {
+	case ColumnTypeString:
+		return s, nil
+	case ColumnTypeInt:
+		if f, ok := value.(float64); ok && f == float64(int64(f)) {
+			return int64(f), nil
+		}
+		return strconv.ParseInt(s, 10, 64)
+	case ColumnTypeUint:
+		if f, ok := value.(float64); ok && f >= 0 && f == float64(uint64(f)) {
+			return uint64(f), nil
+		}
+		return strconv.ParseUint(s, 10, 64)
+	case ColumnTypeFloat:
+		return strconv.ParseFloat(s, 64)
+	case ColumnTypeBool:
+		return strconv.ParseBool(s)
+	case ColumnTypeTime:
+		if t, ok := value.(time.Time); ok {
+			return t, nil
+		}
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(ts, 0), nil
+		}
+		return nil, fmt.Errorf("\"%s\" is not a valid time", s)
+	}
+	return value, nil
+}
+
+// reflectSlice 如果value是slice/array（[]byte除外），则转换为[]any
+func reflectSlice(value any) ([]any, bool) {
+	if _, ok := value.([]byte); ok {
+		return nil, false
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	results := make([]any, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		results[i] = v.Index(i).Interface()
+	}
+	return results, true
+}
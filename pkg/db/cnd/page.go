@@ -44,7 +44,9 @@ type PageResult struct {
 
 type CursorResult struct {
 	Results any    `json:"results"`
-	Cursor  string `json:"cursor"`
+	Cursor  string `json:"cursor"`          // 下一页的游标，为空表示没有更多数据
+	HasMore bool   `json:"has_more"`        // 是否还有下一页
+	Total   *int64 `json:"total,omitempty"` // 总数据条数，未统计时为nil
 }
 
 func SqlNullString(value string) sql.NullString {
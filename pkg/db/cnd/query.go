@@ -2,14 +2,29 @@ package cnd
 
 import (
 	"github.com/samber/lo"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/clause"
 	"gorm.io/gorm"
+	"slices"
+	"strings"
 	"time"
 )
 
+// condition 按顺序保存的where条件，or表示使用OR连接，group不为nil表示分组条件
+type condition struct {
+	or    bool
+	pair  ParamPair
+	group *QueryBuilder
+}
+
 type QueryBuilder struct {
+	table         *ParamPair
 	columns       []string
-	and, or       []ParamPair  // 参数
+	distinct      bool
+	joins         []ParamPair
+	conditions    []condition  // 参数
+	groups        []string     // 分组
+	havings       []ParamPair  // 分组条件
 	orders        []OrderByCol // 排序
 	paging        *Paging      // 分页
 	limit, offset *int
@@ -18,6 +33,7 @@ type QueryBuilder struct {
 
 	preloads                   map[string][]any
 	withTrash                  bool
+	onlyTrashed                bool
 	withDeleteReturning        bool
 	deleteReturningColumnNames []string
 }
@@ -28,6 +44,24 @@ func NewQueryBuilder() *QueryBuilder {
 	}
 }
 
+// Clone 复制一个新的QueryBuilder，修改新的QueryBuilder不会影响当前的QueryBuilder
+func (q *QueryBuilder) Clone() *QueryBuilder {
+	_q := *q
+	_q.columns = slices.Clone(q.columns)
+	_q.joins = slices.Clone(q.joins)
+	_q.conditions = slices.Clone(q.conditions)
+	_q.groups = slices.Clone(q.groups)
+	_q.havings = slices.Clone(q.havings)
+	_q.orders = slices.Clone(q.orders)
+	_q.deleteReturningColumnNames = slices.Clone(q.deleteReturningColumnNames)
+	_q.preloads = lo.Assign(q.preloads)
+	if q.paging != nil {
+		paging := *q.paging
+		_q.paging = &paging
+	}
+	return &_q
+}
+
 // Columns 只获取这些字段
 func (q *QueryBuilder) Columns(columns ...string) *QueryBuilder {
 	if len(columns) > 0 {
@@ -42,15 +76,109 @@ func (q *QueryBuilder) Select(query any, args ...any) *QueryBuilder {
 	return q
 }
 
-// Where 构建where查询条件
+// Table 设置查询的表，一般用于子查询，比如：cnd.Table("orders").Columns("user_id")
+// args可以传入*QueryBuilder作为子查询：Table("(?) as t", subQuery)
+func (q *QueryBuilder) Table(name string, args ...any) *QueryBuilder {
+	q.table = &ParamPair{Query: name, Args: args}
+	return q
+}
+
+// Distinct 去重查询，columns为空时表示SELECT DISTINCT *
+func (q *QueryBuilder) Distinct(columns ...string) *QueryBuilder {
+	q.distinct = true
+	return q.Columns(columns...)
+}
+
+// Where 构建where查询条件，和之前的条件使用AND连接
+// args中可以传入*QueryBuilder作为子查询：Where("amount > (?)", cnd.Table("orders").Select("AVG(amount)"))
 func (q *QueryBuilder) Where(query string, args ...any) *QueryBuilder {
-	q.and = append(q.and, ParamPair{Query: query, Args: args})
+	q.conditions = append(q.conditions, condition{pair: ParamPair{Query: query, Args: args}})
 	return q
 }
 
-// Or 构建or查询条件
+// Or 构建or查询条件，和之前的条件使用OR连接。条件按调用顺序拼接，如需控制优先级，请使用WhereGroup/OrGroup
 func (q *QueryBuilder) Or(query string, args ...any) *QueryBuilder {
-	q.or = append(q.or, ParamPair{Query: query, Args: args})
+	q.conditions = append(q.conditions, condition{or: true, pair: ParamPair{Query: query, Args: args}})
+	return q
+}
+
+// WhereGroup 构建括号包裹的分组条件，和之前的条件使用AND连接
+// example: cnd.Eq("status", 1).WhereGroup(func(q *cnd.QueryBuilder) { q.Eq("a", 1).Or("b = ?", 2) })
+// 生成：status = 1 AND (a = 1 OR b = 2)
+func (q *QueryBuilder) WhereGroup(fn func(q *QueryBuilder)) *QueryBuilder {
+	group := NewQueryBuilder()
+	fn(group)
+	if len(group.conditions) > 0 {
+		q.conditions = append(q.conditions, condition{group: group})
+	}
+	return q
+}
+
+// OrGroup 构建括号包裹的分组条件，和之前的条件使用OR连接
+func (q *QueryBuilder) OrGroup(fn func(q *QueryBuilder)) *QueryBuilder {
+	group := NewQueryBuilder()
+	fn(group)
+	if len(group.conditions) > 0 {
+		q.conditions = append(q.conditions, condition{or: true, group: group})
+	}
+	return q
+}
+
+// WhereIn 子查询：column IN (subQuery)
+// example: cnd.WhereIn("id", cnd.Table("orders").Columns("user_id").Eq("status", 1))
+func (q *QueryBuilder) WhereIn(column string, subQuery *QueryBuilder) *QueryBuilder {
+	return q.Where(column+" IN (?)", subQuery)
+}
+
+// WhereNotIn 子查询：column NOT IN (subQuery)
+func (q *QueryBuilder) WhereNotIn(column string, subQuery *QueryBuilder) *QueryBuilder {
+	return q.Where(column+" NOT IN (?)", subQuery)
+}
+
+// WhereExists 子查询：EXISTS (subQuery)
+// example: cnd.WhereExists(cnd.Table("orders").Where("orders.user_id = users.id"))
+func (q *QueryBuilder) WhereExists(subQuery *QueryBuilder) *QueryBuilder {
+	return q.Where("EXISTS (?)", subQuery)
+}
+
+// WhereNotExists 子查询：NOT EXISTS (subQuery)
+func (q *QueryBuilder) WhereNotExists(subQuery *QueryBuilder) *QueryBuilder {
+	return q.Where("NOT EXISTS (?)", subQuery)
+}
+
+// Joins 原样传递给gorm的Joins，可以是关联名，也可以是完整的join语句
+// example: Joins("Company")、Joins("LEFT JOIN emails ON emails.user_id = users.id AND emails.email = ?", "a@b.com")
+func (q *QueryBuilder) Joins(query string, args ...any) *QueryBuilder {
+	q.joins = append(q.joins, ParamPair{Query: query, Args: args})
+	return q
+}
+
+// Join INNER JOIN table ON on
+// example: Join("orders", "orders.user_id = users.id AND orders.status = ?", 1)
+func (q *QueryBuilder) Join(table string, on string, args ...any) *QueryBuilder {
+	return q.Joins("INNER JOIN "+table+" ON "+on, args...)
+}
+
+// LeftJoin LEFT JOIN table ON on
+func (q *QueryBuilder) LeftJoin(table string, on string, args ...any) *QueryBuilder {
+	return q.Joins("LEFT JOIN "+table+" ON "+on, args...)
+}
+
+// RightJoin RIGHT JOIN table ON on
+func (q *QueryBuilder) RightJoin(table string, on string, args ...any) *QueryBuilder {
+	return q.Joins("RIGHT JOIN "+table+" ON "+on, args...)
+}
+
+// GroupBy GROUP BY columns
+func (q *QueryBuilder) GroupBy(columns ...string) *QueryBuilder {
+	q.groups = append(q.groups, columns...)
+	return q
+}
+
+// Having HAVING条件，多个Having之间使用AND连接
+// example: GroupBy("user_id").Having("SUM(amount) > ?", 100)
+func (q *QueryBuilder) Having(query string, args ...any) *QueryBuilder {
+	q.havings = append(q.havings, ParamPair{Query: query, Args: args})
 	return q
 }
 
@@ -150,6 +278,68 @@ func (q *QueryBuilder) Order(column string, ascend bool) *QueryBuilder {
 	return q
 }
 
+// GetOrders 获取已设置的排序字段
+func (q *QueryBuilder) GetOrders() []OrderByCol {
+	return q.orders
+}
+
+// Reorder 清除已设置的排序、分页和limit/offset，用于需要自行控制排序的场景（比如按主键分块遍历）
+func (q *QueryBuilder) Reorder() *QueryBuilder {
+	q.orders = nil
+	return q.Unpaged()
+}
+
+// Unpaged 清除已设置的分页和limit/offset，保留排序，用于需要自行控制分页的场景（比如游标分页）
+func (q *QueryBuilder) Unpaged() *QueryBuilder {
+	q.paging = nil
+	q.limit = nil
+	q.offset = nil
+	return q
+}
+
+// GroupConditions 将已有的条件用括号包裹为一个分组，之后追加的条件与该分组使用AND连接。
+// 用于在调用方的条件之后追加必须满足的条件（比如游标、主键范围），避免被调用方的Or条件绕过：
+// cnd.Eq("a", 1).Or("b = ?", 2).GroupConditions().Gt("id", 10) 生成：(a = 1 OR b = 2) AND id > 10
+func (q *QueryBuilder) GroupConditions() *QueryBuilder {
+	if !lo.ContainsBy(q.conditions, func(item condition) bool { return item.or }) {
+		return q
+	}
+
+	group := NewQueryBuilder()
+	group.conditions = q.conditions
+	q.conditions = []condition{{group: group}}
+	return q
+}
+
+// Seek 构建游标（keyset）分页的条件，values需要与GetOrders()的字段一一对应。
+// 比如：Order("created_at", false).Order("id", true).Seek(t, 10)
+// 会生成：(created_at < t) OR (created_at = t AND id > 10)
+// 已有的条件会先通过GroupConditions分组，所以Or条件不会绕过游标
+func (q *QueryBuilder) Seek(values ...any) *QueryBuilder {
+	if len(values) == 0 || len(values) != len(q.orders) {
+		return q
+	}
+
+	var segments []string
+	var args []any
+	for i, order := range q.orders {
+		var conditions []string
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, q.orders[j].Column+" = ?")
+			args = append(args, values[j])
+		}
+		if order.Asc {
+			conditions = append(conditions, order.Column+" > ?")
+		} else {
+			conditions = append(conditions, order.Column+" < ?")
+		}
+		args = append(args, values[i])
+		segments = append(segments, "("+strings.Join(conditions, " AND ")+")")
+	}
+
+	return q.GroupConditions().Where("("+strings.Join(segments, " OR ")+")", args...)
+}
+
 // True .
 func (q *QueryBuilder) True(column string) *QueryBuilder {
 	return q.Eq(column, true)
@@ -263,6 +453,18 @@ func (q *QueryBuilder) WithTrash() *QueryBuilder {
 	return q
 }
 
+// OnlyTrashed 只查询软删除的记录 db = db.Unscoped().Where("deleted_at IS NOT NULL")
+func (q *QueryBuilder) OnlyTrashed() *QueryBuilder {
+	q.withTrash = true
+	q.onlyTrashed = true
+	return q
+}
+
+// trashedCondition 当前表的deleted_at IS NOT NULL
+func trashedCondition() clause.Expression {
+	return clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: db.DeletedAtColumn}, Value: nil}
+}
+
 // WithDeleteReturning 启用删除回写，并设置需要返回的字段名（不设置表示返回全部字段） db = db.Clause(clause.Returning{Columns: ...})
 func (q *QueryBuilder) WithDeleteReturning(columnNames ...string) *QueryBuilder {
 	q.withDeleteReturning = true
@@ -278,6 +480,10 @@ func (q *QueryBuilder) Build(db *gorm.DB) *gorm.DB {
 		ret = ret.Unscoped()
 	}
 
+	if q.onlyTrashed {
+		ret = ret.Where(trashedCondition())
+	}
+
 	if q.withDeleteReturning {
 		ret = ret.Clauses(clause.Returning{
 			Columns: lo.Map(q.deleteReturningColumnNames, func(name string, _ int) clause.Column {
@@ -286,38 +492,43 @@ func (q *QueryBuilder) Build(db *gorm.DB) *gorm.DB {
 		})
 	}
 
+	if q.table != nil {
+		ret = ret.Table(q.table.Query.(string), buildArgs(ret, q.table.Args)...)
+	}
+
 	for preload, args := range q.preloads {
 		ret = ret.Preload(preload, args...)
 	}
 
-	if len(q.columns) > 0 {
+	if q.distinct {
+		ret = ret.Distinct(lo.ToAnySlice(q.columns)...)
+	} else if len(q.columns) > 0 {
 		ret = ret.Select(q.columns)
 	}
 
-	if len(q.and) > 0 {
-		for _, item := range q.and {
-			ret = ret.Where(item.Query, item.Args...)
-		}
+	for _, item := range q.joins {
+		ret = ret.Joins(item.Query.(string), buildArgs(ret, item.Args)...)
 	}
 
-	if len(q.or) > 0 {
-		for _, item := range q.or {
-			ret = ret.Or(item.Query, item.Args...)
-		}
+	ret = q.buildConditions(ret)
+
+	if len(q.groups) > 0 {
+		ret = ret.Group(strings.Join(q.groups, ", "))
+	}
+
+	for _, item := range q.havings {
+		ret = ret.Having(item.Query, buildArgs(ret, item.Args)...)
 	}
 
 	if len(q.orders) > 0 {
 		for _, item := range q.orders {
-			if item.Asc {
-				ret = ret.Order("`" + item.Column + "` ASC")
-			} else {
-				ret = ret.Order("`" + item.Column + "` DESC")
-			}
+			// 由数据库方言加上引号（MySQL为`table`.`column`，PgSQL/SQLite为"table"."column"）
+			ret = ret.Order(clause.OrderByColumn{Column: clause.Column{Name: item.Column}, Desc: !item.Asc})
 		}
 	}
 
 	if q.paging != nil {
-		ret = ret.Limit(q.paging.Page).Offset(q.paging.Offset())
+		ret = ret.Limit(q.paging.Limit).Offset(q.paging.Offset())
 	}
 
 	if q.limit != nil {
@@ -338,3 +549,41 @@ func (q *QueryBuilder) Build(db *gorm.DB) *gorm.DB {
 
 	return ret
 }
+
+// buildConditions 按顺序构建where条件，分组条件使用新的Session构建后作为group condition传入
+func (q *QueryBuilder) buildConditions(db *gorm.DB) *gorm.DB {
+	ret := db
+	for _, item := range q.conditions {
+		var query any
+		var args []any
+		if item.group != nil {
+			query = item.group.buildConditions(db.Session(&gorm.Session{NewDB: true}))
+		} else {
+			query, args = item.pair.Query, buildArgs(db, item.pair.Args)
+		}
+
+		if item.or {
+			ret = ret.Or(query, args...)
+		} else {
+			ret = ret.Where(query, args...)
+		}
+	}
+	return ret
+}
+
+// buildArgs 将参数中的*QueryBuilder转换为gorm的子查询
+func buildArgs(db *gorm.DB, args []any) []any {
+	if !lo.ContainsBy(args, func(arg any) bool {
+		_, ok := arg.(*QueryBuilder)
+		return ok
+	}) {
+		return args
+	}
+
+	return lo.Map(args, func(arg any, _ int) any {
+		if subQuery, ok := arg.(*QueryBuilder); ok {
+			return subQuery.Build(db.Session(&gorm.Session{NewDB: true}))
+		}
+		return arg
+	})
+}
@@ -0,0 +1,122 @@
+package cnd_test
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+type user struct {
+	ID     int64 `gorm:"primaryKey"`
+	Name   string
+	Status int
+}
+
+func (user) TableName() string {
+	return "users"
+}
+
+// toSQL 返回query构建的SELECT语句（不执行）
+func toSQL(t *testing.T, query *cnd.QueryBuilder) string {
+	t.Helper()
+
+	orm, err := sqlite.OpenInMemory(&db.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := orm.DB(); err == nil {
+		t.Cleanup(func() {
+			_ = sqlDB.Close()
+		})
+	}
+
+	return orm.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var users []user
+		return query.Build(tx.Model(&user{})).Find(&users)
+	})
+}
+
+func assertSQL(t *testing.T, query *cnd.QueryBuilder, expected string) {
+	t.Helper()
+	if sql := toSQL(t, query); sql != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, sql)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	// LIMIT为每页条数，OFFSET为(page-1)*limit
+	assertSQL(t, cnd.NewQueryBuilder().Paginate(3, 20),
+		"SELECT * FROM `users` LIMIT 20 OFFSET 40")
+	assertSQL(t, cnd.NewQueryBuilder().Paginate(1, 5),
+		"SELECT * FROM `users` LIMIT 5")
+	// Limit/Offset在Paging之后应用，会覆盖Paging
+	assertSQL(t, cnd.NewQueryBuilder().Paginate(3, 20).Limit(10),
+		"SELECT * FROM `users` LIMIT 10 OFFSET 40")
+}
+
+func TestWhereGroup(t *testing.T) {
+	query := cnd.Eq("status", 1).WhereGroup(func(q *cnd.QueryBuilder) {
+		q.Eq("name", "a").Or("name = ?", "b")
+	})
+	assertSQL(t, query, "SELECT * FROM `users` WHERE status = 1 AND (name = \"a\" OR name = \"b\")")
+
+	// 空的分组会被忽略
+	assertSQL(t, cnd.Eq("status", 1).WhereGroup(func(q *cnd.QueryBuilder) {}),
+		"SELECT * FROM `users` WHERE status = 1")
+}
+
+func TestOrGroup(t *testing.T) {
+	query := cnd.Eq("status", 1).OrGroup(func(q *cnd.QueryBuilder) {
+		q.Eq("status", 2).Eq("name", "a")
+	})
+	assertSQL(t, query, "SELECT * FROM `users` WHERE status = 1 OR (status = 2 AND name = \"a\")")
+}
+
+func TestJoin(t *testing.T) {
+	assertSQL(t, cnd.NewQueryBuilder().Join("orders", "orders.user_id = users.id AND orders.status = ?", 1),
+		"SELECT `users`.`id`,`users`.`name`,`users`.`status` FROM `users` INNER JOIN orders ON orders.user_id = users.id AND orders.status = 1")
+	assertSQL(t, cnd.NewQueryBuilder().LeftJoin("orders", "orders.user_id = users.id"),
+		"SELECT `users`.`id`,`users`.`name`,`users`.`status` FROM `users` LEFT JOIN orders ON orders.user_id = users.id")
+	assertSQL(t, cnd.NewQueryBuilder().RightJoin("orders", "orders.user_id = users.id"),
+		"SELECT `users`.`id`,`users`.`name`,`users`.`status` FROM `users` RIGHT JOIN orders ON orders.user_id = users.id")
+}
+
+func TestHaving(t *testing.T) {
+	query := cnd.NewQueryBuilder().Columns("status").GroupBy("status").Having("COUNT(*) > ?", 1).Having("MAX(id) < ?", 100)
+	assertSQL(t, query, "SELECT `status` FROM `users` GROUP BY `status` HAVING COUNT(*) > 1 AND MAX(id) < 100")
+}
+
+func TestWhereExists(t *testing.T) {
+	query := cnd.Eq("status", 1).WhereExists(cnd.Table("orders").Where("orders.user_id = users.id"))
+	assertSQL(t, query, "SELECT * FROM `users` WHERE status = 1 AND EXISTS (SELECT * FROM `orders` WHERE orders.user_id = users.id)")
+
+	query = cnd.NewQueryBuilder().WhereNotExists(cnd.Table("orders").Where("orders.user_id = users.id"))
+	assertSQL(t, query, "SELECT * FROM `users` WHERE NOT EXISTS (SELECT * FROM `orders` WHERE orders.user_id = users.id)")
+}
+
+func TestSeek(t *testing.T) {
+	query := cnd.Eq("status", 1).Or("status = ?", 2).Desc("name").Asc("id").Seek("a", 10)
+	// 已有的Or条件先被分组，游标条件不能被绕过
+	assertSQL(t, query, "SELECT * FROM `users` WHERE (status = 1 OR status = 2) AND (((name < \"a\") OR (name = \"a\" AND id > 10))) ORDER BY `name` DESC,`id`")
+
+	// 值的数量和排序字段不一致时，不追加条件
+	assertSQL(t, cnd.NewQueryBuilder().Asc("id").Seek(1, 2), "SELECT * FROM `users` ORDER BY `id`")
+}
+
+func TestReorder(t *testing.T) {
+	query := cnd.Eq("status", 1).Desc("name").Paginate(2, 10).Limit(5).Offset(3)
+
+	clone := query.Clone().Reorder()
+	assertSQL(t, clone, "SELECT * FROM `users` WHERE status = 1")
+	// Clone后修改不影响原来的QueryBuilder
+	if sql := toSQL(t, query); !strings.Contains(sql, "ORDER BY `name` DESC LIMIT 5 OFFSET 3") {
+		t.Fatalf("the original query builder is changed: %s", sql)
+	}
+
+	assertSQL(t, query.Clone().Unpaged(), "SELECT * FROM `users` WHERE status = 1 ORDER BY `name` DESC")
+}
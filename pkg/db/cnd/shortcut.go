@@ -175,6 +175,11 @@ func WithTrash() *QueryBuilder {
 	return NewQueryBuilder().WithTrash()
 }
 
+// OnlyTrashed 只查询软删除的数据
+func OnlyTrashed() *QueryBuilder {
+	return NewQueryBuilder().OnlyTrashed()
+}
+
 // PreloadWithBuilder 带条件的预加载，只支持一个关联
 func PreloadWithBuilder(preload string, args ...any) *QueryBuilder {
 	return NewQueryBuilder().PreloadWithBuilder(preload, args...)
@@ -184,3 +189,53 @@ func PreloadWithBuilder(preload string, args ...any) *QueryBuilder {
 func Preloads(preloads ...string) *QueryBuilder {
 	return NewQueryBuilder().Preloads(preloads...)
 }
+
+// Table 构造指定表的便捷操作，一般用于子查询
+func Table(name string, args ...any) *QueryBuilder {
+	return NewQueryBuilder().Table(name, args...)
+}
+
+// Distinct .
+func Distinct(columns ...string) *QueryBuilder {
+	return NewQueryBuilder().Distinct(columns...)
+}
+
+// WhereGroup 构造分组条件的便捷操作
+func WhereGroup(fn func(q *QueryBuilder)) *QueryBuilder {
+	return NewQueryBuilder().WhereGroup(fn)
+}
+
+// WhereIn 构造column IN (子查询)的便捷操作
+func WhereIn(column string, subQuery *QueryBuilder) *QueryBuilder {
+	return NewQueryBuilder().WhereIn(column, subQuery)
+}
+
+// WhereNotIn 构造column NOT IN (子查询)的便捷操作
+func WhereNotIn(column string, subQuery *QueryBuilder) *QueryBuilder {
+	return NewQueryBuilder().WhereNotIn(column, subQuery)
+}
+
+// WhereExists 构造EXISTS (子查询)的便捷操作
+func WhereExists(subQuery *QueryBuilder) *QueryBuilder {
+	return NewQueryBuilder().WhereExists(subQuery)
+}
+
+// WhereNotExists 构造NOT EXISTS (子查询)的便捷操作
+func WhereNotExists(subQuery *QueryBuilder) *QueryBuilder {
+	return NewQueryBuilder().WhereNotExists(subQuery)
+}
+
+// Join 构造INNER JOIN的便捷操作
+func Join(table string, on string, args ...any) *QueryBuilder {
+	return NewQueryBuilder().Join(table, on, args...)
+}
+
+// LeftJoin 构造LEFT JOIN的便捷操作
+func LeftJoin(table string, on string, args ...any) *QueryBuilder {
+	return NewQueryBuilder().LeftJoin(table, on, args...)
+}
+
+// GroupBy 构造GROUP BY的便捷操作
+func GroupBy(columns ...string) *QueryBuilder {
+	return NewQueryBuilder().GroupBy(columns...)
+}
@@ -5,37 +5,67 @@ import (
 	"fmt"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
 	"gorm.io/gorm/schema"
+	"slices"
+	"sync"
 )
 
 type onEventFunc func(ctx context.Context, tx *DB, model schema.Tabler, event event.EventType, args ...any) error
 
-var globalEventListeners map[string]onEventFunc
-
-func init() {
-	globalEventListeners = make(map[string]onEventFunc)
+type eventBinding struct {
+	callback onEventFunc
 }
 
+var (
+	globalEventListeners   = make(map[string][]*eventBinding)
+	globalEventListenersMu sync.RWMutex
+)
+
 func getEventName(t schema.Tabler) string {
 	return fmt.Sprintf("model.%s", t.TableName())
 }
 
 // fireModelEvent 由Model调用，触发事件
 func fireModelEvent(ctx context.Context, tx *DB, model schema.Tabler, event event.EventType) error {
+	return FireModelEvent(ctx, tx, model, event)
+}
+
+// FireModelEvent 按顺序调用model所在表的所有订阅者，遇到第一个错误时停止
+func FireModelEvent(ctx context.Context, tx *DB, model schema.Tabler, event event.EventType, args ...any) error {
 	if model.TableName() == "" {
 		return nil
 	}
 
-	listener, exists := globalEventListeners[getEventName(model)]
-	if exists {
-		return listener(ctx, tx, model, event)
+	globalEventListenersMu.RLock()
+	bindings := globalEventListeners[getEventName(model)]
+	globalEventListenersMu.RUnlock()
+
+	for _, binding := range bindings {
+		if err := binding.callback(ctx, tx, model, event, args...); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// BindModelEvents 绑定T的所有事件
-func BindModelEvents[T schema.Tabler](t T, callback onEventFunc) {
+// BindModelEvents 订阅T所在表的所有事件，同一个表可以有多个订阅者（比如多个Repository），按订阅顺序调用。
+// 返回取消订阅的函数
+func BindModelEvents[T schema.Tabler](t T, callback onEventFunc) (unbind func()) {
 	if t.TableName() == "" {
 		panic("model.TableName() must not be empty")
 	}
-	globalEventListeners[getEventName(t)] = callback
+
+	name := getEventName(t)
+	binding := &eventBinding{callback: callback}
+
+	globalEventListenersMu.Lock()
+	globalEventListeners[name] = append(slices.Clone(globalEventListeners[name]), binding)
+	globalEventListenersMu.Unlock()
+
+	return func() {
+		globalEventListenersMu.Lock()
+		defer globalEventListenersMu.Unlock()
+		globalEventListeners[name] = slices.DeleteFunc(slices.Clone(globalEventListeners[name]), func(b *eventBinding) bool {
+			return b == binding
+		})
+	}
 }
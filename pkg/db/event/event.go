@@ -2,9 +2,12 @@ package event
 
 import (
 	"context"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/job"
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
 	"reflect"
+	"slices"
+	"sync"
 )
 
 type EventType string
@@ -22,6 +25,8 @@ const (
 	Deleting     EventType = "deleting"
 	Deleted      EventType = "deleted"
 	BatchDeleted EventType = "batch_deleted"
+	Restoring    EventType = "restoring"
+	Restored     EventType = "restored"
 	Found        EventType = "found"
 	Customer     EventType = "customer"
 )
@@ -30,54 +35,159 @@ type ModelEvent[T schema.Tabler] struct {
 	EventType EventType
 	Tx        *gorm.DB
 	Model     T
+	// Payload 事件的类型化参数，比如BatchUpdated/BatchDeleted的查询条件、更新的字段，使用PayloadOf获取
+	Payload any
+	// Arguments 手动触发事件（FireEvent）时传入的参数。
+	// BatchUpdated事件为旧版本的参数：query *cnd.QueryBuilder, attributes repo.Columns（UpdateMany时attributes为nil），新代码请使用Payload
 	Arguments []any
 }
 
+// PayloadOf 获取事件的类型化参数
+// example: payload, ok := event.PayloadOf[*repo.BatchUpdatedPayload](e)
+func PayloadOf[P any, T schema.Tabler](e ModelEvent[T]) (P, bool) {
+	p, ok := e.Payload.(P)
+	return p, ok
+}
+
 // EventListenerFunc 事件监听器函数
 type EventListenerFunc[T schema.Tabler] func(ctx context.Context, modelEvent ModelEvent[T]) error
 
-// Events 事件监听器
-type Events[T schema.Tabler] map[EventType][]EventListenerFunc[T]
-
-func (e Events[T]) FireEvent(ctx context.Context, tx *gorm.DB, event EventType, model T, arguments ...any) error {
-	if callbacks, ok := e[event]; ok {
-		for _, callback := range callbacks {
-			if err := callback(ctx, ModelEvent[T]{
-				EventType: event,
-				Tx:        tx,
-				Model:     model,
-				Arguments: arguments,
-			}); err != nil {
-				return err
-			}
+// Dispatcher 执行异步监听器的任务池，worker.IWorker实现了此接口
+type Dispatcher interface {
+	Submit(job job.Job)
+}
+
+// AsyncErrorHandler 异步监听器返回错误（或panic）时的回调
+type AsyncErrorHandler func(ctx context.Context, eventType EventType, err error)
+
+type listener[T schema.Tabler] struct {
+	callback EventListenerFunc[T]
+	listenerOptions
+}
+
+// Events 事件监听器，协程安全。
+// 同步监听器按优先级（从高到低，相同优先级按注册顺序）执行，遇到第一个错误时停止并返回该错误；
+// 同步监听器全部成功后，异步监听器按相同的顺序提交到Dispatcher（未设置时使用新的协程）执行
+type Events[T schema.Tabler] struct {
+	mu         sync.RWMutex
+	listeners  map[EventType][]*listener[T]
+	dispatcher Dispatcher
+	onError    AsyncErrorHandler
+}
+
+func NewEvents[T schema.Tabler]() *Events[T] {
+	return &Events[T]{
+		listeners: make(map[EventType][]*listener[T]),
+	}
+}
+
+// SetDispatcher 设置执行异步监听器的任务池
+func (e *Events[T]) SetDispatcher(dispatcher Dispatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.dispatcher = dispatcher
+}
+
+// SetAsyncErrorHandler 设置异步监听器出错时的回调
+func (e *Events[T]) SetAsyncErrorHandler(handler AsyncErrorHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onError = handler
+}
+
+func (e *Events[T]) FireEvent(ctx context.Context, tx *gorm.DB, event EventType, model T, arguments ...any) error {
+	return e.FireEventWithPayload(ctx, tx, event, model, nil, arguments...)
+}
+
+// FireEventWithPayload 触发带类型化参数的事件
+func (e *Events[T]) FireEventWithPayload(ctx context.Context, tx *gorm.DB, event EventType, model T, payload any, arguments ...any) error {
+	e.mu.RLock()
+	listeners := e.listeners[event]
+	dispatcher, onError := e.dispatcher, e.onError
+	e.mu.RUnlock()
+
+	modelEvent := ModelEvent[T]{
+		EventType: event,
+		Tx:        tx,
+		Model:     model,
+		Payload:   payload,
+		Arguments: arguments,
+	}
+
+	for _, l := range listeners {
+		if l.async {
+			continue
+		}
+		if err := l.callback(ctx, modelEvent); err != nil {
+			return err
+		}
+	}
+
+	for _, l := range listeners {
+		if l.async {
+			dispatchAsync(ctx, dispatcher, onError, l.callback, modelEvent)
 		}
 	}
 	return nil
 }
 
-// RegisterEventListener 注册事件监听器，当事件触发时，会调用callback。注意：不是协程安全的。（因为大部分情况下，注册事件监听器是在初始化时完成的）
-func (e Events[T]) RegisterEventListener(eventType EventType, callback EventListenerFunc[T]) {
-	if _, ok := e[eventType]; !ok {
-		e[eventType] = make([]EventListenerFunc[T], 0)
+// dispatchAsync 异步执行监听器，ctx会被转换成不会cancel的context（请求结束后仍可使用ctx中的值）
+func dispatchAsync[T schema.Tabler](ctx context.Context, dispatcher Dispatcher, onError AsyncErrorHandler, callback EventListenerFunc[T], modelEvent ModelEvent[T]) {
+	ctx = context.WithoutCancel(ctx)
+	run := func(context.Context) {
+		defer func() {
+			if r := recover(); r != nil && onError != nil {
+				onError(ctx, modelEvent.EventType, panicError{r})
+			}
+		}()
+		if err := callback(ctx, modelEvent); err != nil && onError != nil {
+			onError(ctx, modelEvent.EventType, err)
+		}
+	}
+
+	if dispatcher != nil {
+		dispatcher.Submit(run)
+	} else {
+		go run(ctx)
+	}
+}
+
+// RegisterEventListener 注册事件监听器，当事件触发时，会调用callback
+// example: events.RegisterEventListener(event.Created, callback, event.WithPriority(10), event.Async())
+func (e *Events[T]) RegisterEventListener(eventType EventType, callback EventListenerFunc[T], options ...ListenerOption) {
+	l := &listener[T]{callback: callback}
+	for _, option := range options {
+		option(&l.listenerOptions)
 	}
-	e[eventType] = append(e[eventType], callback)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// 复制一份新的slice，不影响正在FireEvent中遍历的监听器
+	listeners := append(slices.Clone(e.listeners[eventType]), l)
+	slices.SortStableFunc(listeners, func(a, b *listener[T]) int {
+		return b.priority - a.priority
+	})
+	e.listeners[eventType] = listeners
 }
 
 // RemoveEventListener 移除eventType的callback监听器，使用的是reflect.ValueOf(callback).Pointer()来判断callback是否相等。如存在返回true，否则返回false
-func (e Events[T]) RemoveEventListener(eventType EventType, callback EventListenerFunc[T]) bool {
+func (e *Events[T]) RemoveEventListener(eventType EventType, callback EventListenerFunc[T]) bool {
 	fp := reflect.ValueOf(callback).Pointer()
-	if callbacks, ok := e[eventType]; ok {
-		for i, cb := range callbacks {
-			if reflect.ValueOf(cb).Pointer() == fp {
-				e[eventType] = append(e[eventType][:i], e[eventType][i+1:]...)
-				return true
-			}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, l := range e.listeners[eventType] {
+		if reflect.ValueOf(l.callback).Pointer() == fp {
+			e.listeners[eventType] = slices.Delete(slices.Clone(e.listeners[eventType]), i, i+1)
+			return true
 		}
 	}
 	return false
 }
 
 // RemoveAllEventListeners 移除eventType的所有监听器
-func (e Events[T]) RemoveAllEventListeners(eventType EventType) {
-	e[eventType] = make([]EventListenerFunc[T], 0)
+func (e *Events[T]) RemoveAllEventListeners(eventType EventType) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.listeners, eventType)
 }
@@ -0,0 +1,33 @@
+package event
+
+import "fmt"
+
+type listenerOptions struct {
+	priority int
+	async    bool
+}
+
+type ListenerOption func(*listenerOptions)
+
+// WithPriority 设置监听器的优先级，数值越大越先执行，默认为0。相同优先级按注册顺序执行
+func WithPriority(priority int) ListenerOption {
+	return func(o *listenerOptions) {
+		o.priority = priority
+	}
+}
+
+// Async 异步执行监听器：不会阻塞数据库操作，返回的错误不会中断操作（交给AsyncErrorHandler处理）。
+// 注意：执行时事务可能已经提交或回滚，不要使用ModelEvent.Tx
+func Async() ListenerOption {
+	return func(o *listenerOptions) {
+		o.async = true
+	}
+}
+
+type panicError struct {
+	value any
+}
+
+func (e panicError) Error() string {
+	return fmt.Sprintf("event listener panic: %v", e.value)
+}
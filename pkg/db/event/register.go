@@ -122,12 +122,10 @@ func afterUpdate(db *gorm.DB) {
 	if db.Error == nil && db.Statement.Schema != nil && !db.Statement.SkipHooks {
 		callMethod(db, func(value any, tx *gorm.DB) (called bool) {
 			ctx := db.Statement.Context
-			if db.Statement.Schema.AfterUpdate {
-				if i, ok := value.(iModelEvent); ok {
-					called = true
-					db.AddError(i.OnEvent(ctx, tx, i, Updated))
-					db.AddError(i.OnEvent(ctx, tx, i, Saved))
-				}
+			if i, ok := value.(iModelEvent); ok {
+				called = true
+				db.AddError(i.OnEvent(ctx, tx, i, Updated))
+				db.AddError(i.OnEvent(ctx, tx, i, Saved))
 			}
 
 			return called
@@ -0,0 +1,85 @@
+package event_test
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// note 没有实现gorm的AfterUpdate方法，只通过OnEvent接收事件
+type note struct {
+	ID     int64 `gorm:"primaryKey"`
+	Title  string
+	events *[]event.EventType `gorm:"-"`
+}
+
+func (note) TableName() string {
+	return "notes"
+}
+
+func (n *note) OnEvent(_ context.Context, _ *gorm.DB, _ schema.Tabler, eventType event.EventType) error {
+	if n.events != nil {
+		*n.events = append(*n.events, eventType)
+	}
+	return nil
+}
+
+func TestModelEvents(t *testing.T) {
+	orm, err := sqlite.OpenInMemory(&db.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := orm.DB(); err == nil {
+		t.Cleanup(func() {
+			_ = sqlDB.Close()
+		})
+	}
+	event.RegisterGormEvents(orm)
+	if err = orm.AutoMigrate(&note{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []event.EventType
+	model := &note{Title: "a", events: &events}
+	if err = orm.Create(model).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, events, event.Saving, event.Creating, event.Created, event.Saved)
+
+	events = nil
+	model.Title = "b"
+	if err = orm.Save(model).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, events, event.Saving, event.Updating, event.Updated, event.Saved)
+
+	events = nil
+	if err = orm.Model(model).Update("title", "c").Error; err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, events, event.Saving, event.Updating, event.Updated, event.Saved)
+
+	events = nil
+	if err = orm.Delete(model).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, events, event.Deleting, event.Deleted)
+}
+
+func assertEvents(t *testing.T, actual []event.EventType, expected ...event.EventType) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("expected events %v, got %v", expected, actual)
+		}
+	}
+}
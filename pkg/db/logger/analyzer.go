@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+var (
+	operationRegexp = regexp.MustCompile(`(?i)^\s*(select|insert|update|delete|replace|create|alter|drop|with|begin|commit|rollback|savepoint|release|pragma|show|explain)\b`)
+	tableRegexp     = regexp.MustCompile("(?i)\\b(?:from|into|update|table)\\s+([`\"\\[]?[\\w.]+[`\"\\]]?)")
+
+	stringLiteralRegexp = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberRegexp        = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	placeholderRegexp   = regexp.MustCompile(`\$\d+`)
+	inListRegexp        = regexp.MustCompile(`(?i)\bin\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	spaceRegexp         = regexp.MustCompile(`\s+`)
+)
+
+// parseStatement 从SQL中解析出操作类型、表名，用于指标的label
+func parseStatement(sql string) (operation, table string) {
+	operation = "other"
+	if matches := operationRegexp.FindStringSubmatch(sql); matches != nil {
+		operation = strings.ToLower(matches[1])
+	}
+
+	table = "unknown"
+	if matches := tableRegexp.FindStringSubmatch(sql); matches != nil {
+		table = strings.Trim(matches[1], "`\"[]")
+	}
+	return operation, table
+}
+
+// normalizeSQL 将SQL中的参数值替换为?，用于判断是否为相同的SQL
+func normalizeSQL(sql string) string {
+	sql = stringLiteralRegexp.ReplaceAllString(sql, "?")
+	sql = placeholderRegexp.ReplaceAllString(sql, "?")
+	sql = numberRegexp.ReplaceAllString(sql, "?")
+	sql = inListRegexp.ReplaceAllString(sql, "IN (?)")
+	return strings.TrimSpace(spaceRegexp.ReplaceAllString(sql, " "))
+}
+
+// sourceDirs gorm、db、repo的源码目录，获取调用者时跳过
+var sourceDirs = func() []string {
+	_, file, _, _ := runtime.Caller(0)
+	// pkg/db/logger/analyzer.go -> pkg/
+	pkgDir := filepath.ToSlash(filepath.Dir(filepath.Dir(filepath.Dir(file)))) + "/"
+	return []string{"gorm.io/", pkgDir + "db/", pkgDir + "repo/"}
+}()
+
+// caller 获取执行SQL的业务代码位置（跳过gorm、db、repo、标准库的源码）
+func caller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !isInternalFrame(frame) {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func isInternalFrame(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+	for _, dir := range sourceDirs {
+		if strings.Contains(frame.File, dir) {
+			return true
+		}
+	}
+
+	// 标准库的包路径第一段没有"."，比如database/sql、runtime（main包除外）
+	first, _, _ := strings.Cut(frame.Function, "/")
+	if !strings.Contains(frame.Function, "/") {
+		first, _, _ = strings.Cut(frame.Function, ".")
+	}
+	return first != "main" && !strings.Contains(first, ".")
+}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// explainer 异步执行慢查询的EXPLAIN
+type explainer struct {
+	getDB      func() *gorm.DB
+	sampleRate float64
+	// running 限制同时执行的EXPLAIN数量，繁忙时丢弃
+	running chan struct{}
+}
+
+func newExplainer(getDB func() *gorm.DB, sampleRate float64) *explainer {
+	return &explainer{
+		getDB:      getDB,
+		sampleRate: sampleRate,
+		running:    make(chan struct{}, 2),
+	}
+}
+
+// explain 按采样率异步执行EXPLAIN，完成后调用callback。sql为带占位符的原始SQL，vars为绑定的参数
+func (e *explainer) explain(ctx context.Context, sql string, vars []any, callback func(ctx context.Context, plan string, err error)) {
+	if e.sampleRate <= 0 || rand.Float64() >= e.sampleRate {
+		return
+	}
+	select {
+	case e.running <- struct{}{}:
+	default:
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() { <-e.running }()
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		plan, err := e.run(ctx, sql, vars)
+		callback(ctx, plan, err)
+	}()
+}
+
+func (e *explainer) run(ctx context.Context, sql string, vars []any) (string, error) {
+	db := e.getDB()
+	if db == nil {
+		return "", errors.New("explain failed: db is not ready")
+	}
+
+	prefix := "EXPLAIN "
+	if db.Dialector.Name() == "sqlite" {
+		prefix = "EXPLAIN QUERY PLAN "
+	}
+	// 直接使用连接池执行：占位符已经是数据库方言的格式（比如PgSQL的$1），并且EXPLAIN自身不会被记录、分析
+	rows, err := db.ConnPool.QueryContext(ctx, prefix+sql, vars...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	var plan strings.Builder
+	plan.WriteString(strings.Join(columns, " | "))
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(pointers...); err != nil {
+			return "", err
+		}
+		cells := make([]string, len(values))
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			cells[i] = fmt.Sprint(value)
+		}
+		plan.WriteString("\n" + strings.Join(cells, " | "))
+	}
+	return plan.String(), rows.Err()
+}
@@ -5,41 +5,61 @@ import (
 	"errors"
 	"fmt"
 	kratosLog "gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/metrics"
+	"gorm.io/gorm"
 	"time"
 
 	gormLogger "gorm.io/gorm/logger"
 )
 
 // New initialize logger
-func New(logHelper *kratosLog.Helper, config Config) Interface {
+// 日志中包含执行SQL的业务代码位置，request.id等由logHelper的valuer从ctx中获取
+func New(logHelper *kratosLog.Helper, config Config, options ...Option) Interface {
 	var (
-		traceStr     = "[%.3fms] [rows:%v] %s"
-		traceWarnStr = "%s\n[%.3fms] [rows:%v] %s"
-		traceErrStr  = "%s\n[%.3fms] [rows:%v] %s"
+		traceStr     = "%s\n[%.3fms] [rows:%v] %s"
+		traceWarnStr = "%s %s\n[%.3fms] [rows:%v] %s"
+		traceErrStr  = "%s %s\n[%.3fms] [rows:%v] %s"
 	)
 
 	if config.Colorful {
-		traceStr = gormLogger.Yellow + "[%.3fms] " + gormLogger.BlueBold + "[rows:%v]" + gormLogger.Reset + " %s"
-		traceWarnStr = gormLogger.Yellow + "%s\n" + gormLogger.Reset + gormLogger.RedBold + "[%.3fms] " + gormLogger.Yellow + "[rows:%v]" + gormLogger.Magenta + " %s" + gormLogger.Reset
-		traceErrStr = gormLogger.MagentaBold + "%s\n" + gormLogger.Reset + gormLogger.Yellow + "[%.3fms] " + gormLogger.BlueBold + "[rows:%v]" + gormLogger.Reset + " %s"
+		traceStr = gormLogger.Green + "%s\n" + gormLogger.Reset + gormLogger.Yellow + "[%.3fms] " + gormLogger.BlueBold + "[rows:%v]" + gormLogger.Reset + " %s"
+		traceWarnStr = gormLogger.Green + "%s " + gormLogger.Yellow + "%s\n" + gormLogger.Reset + gormLogger.RedBold + "[%.3fms] " + gormLogger.Yellow + "[rows:%v]" + gormLogger.Magenta + " %s" + gormLogger.Reset
+		traceErrStr = gormLogger.RedBold + "%s " + gormLogger.MagentaBold + "%s\n" + gormLogger.Reset + gormLogger.Yellow + "[%.3fms] " + gormLogger.BlueBold + "[rows:%v]" + gormLogger.Reset + " %s"
 	}
 
-	return &logger{
+	l := &logger{
 		Config:       config,
 		kratosLogger: logHelper,
 		traceStr:     traceStr,
 		traceWarnStr: traceWarnStr,
 		traceErrStr:  traceErrStr,
 	}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+type queryMetrics struct {
+	duration *metrics.HistogramVec
+	errors   *metrics.CounterVec
+	slow     *metrics.CounterVec
+	nPlusOne *metrics.CounterVec
 }
 
 type logger struct {
 	Config
 	kratosLogger                        *kratosLog.Helper
 	traceStr, traceErrStr, traceWarnStr string
+
+	metrics           *queryMetrics
+	explainer         *explainer
+	nPlusOneThreshold int
+	redactedColumns   map[string]struct{}
 }
 
 var _ Interface = (*logger)(nil)
+var _ gorm.ParamsFilter = (*logger)(nil)
 
 // LogMode log mode
 func (l *logger) LogMode(level gormLogger.LogLevel) Interface {
@@ -71,41 +91,119 @@ func (l *logger) Error(ctx context.Context, msg string, data ...any) {
 
 // Trace print sql message
 func (l *logger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+
+	// fc每次调用都会重新拼接SQL，只调用一次
+	var (
+		sql    string
+		rows   int64
+		called bool
+	)
+	get := func() (string, int64) {
+		if !called {
+			sql, rows = fc()
+			called = true
+		}
+		return sql, rows
+	}
+
+	// 指标、N+1、EXPLAIN不受LogLevel影响
+	l.analyze(ctx, elapsed, get, err)
+
 	if l.LogLevel <= gormLogger.Silent {
 		return
 	}
 
-	elapsed := time.Since(begin)
 	switch {
 	case err != nil && l.LogLevel >= gormLogger.Error && (!errors.Is(err, gormLogger.ErrRecordNotFound) || !l.IgnoreRecordNotFoundError):
-		sql, rows := fc()
+		sql, rows := get()
 		if rows == -1 {
-			l.kratosLogger.WithContext(ctx).Errorf(l.traceErrStr, err, float64(elapsed.Nanoseconds())/1e6, "-", sql)
+			l.kratosLogger.WithContext(ctx).Errorf(l.traceErrStr, caller(), err, float64(elapsed.Nanoseconds())/1e6, "-", sql)
 		} else {
-			l.kratosLogger.WithContext(ctx).Errorf(l.traceErrStr, err, float64(elapsed.Nanoseconds())/1e6, rows, sql)
+			l.kratosLogger.WithContext(ctx).Errorf(l.traceErrStr, caller(), err, float64(elapsed.Nanoseconds())/1e6, rows, sql)
 		}
 	case elapsed > l.SlowThreshold && l.SlowThreshold != 0 && l.LogLevel >= gormLogger.Warn:
-		sql, rows := fc()
+		sql, rows := get()
 		slowLog := fmt.Sprintf("SLOW SQL >= %v", l.SlowThreshold)
 		if rows == -1 {
-			l.kratosLogger.WithContext(ctx).Warnf(l.traceWarnStr, slowLog, float64(elapsed.Nanoseconds())/1e6, "-", sql)
+			l.kratosLogger.WithContext(ctx).Warnf(l.traceWarnStr, caller(), slowLog, float64(elapsed.Nanoseconds())/1e6, "-", sql)
 		} else {
-			l.kratosLogger.WithContext(ctx).Warnf(l.traceWarnStr, slowLog, float64(elapsed.Nanoseconds())/1e6, rows, sql)
+			l.kratosLogger.WithContext(ctx).Warnf(l.traceWarnStr, caller(), slowLog, float64(elapsed.Nanoseconds())/1e6, rows, sql)
 		}
 	case l.LogLevel == gormLogger.Info:
-		sql, rows := fc()
+		sql, rows := get()
 		if rows == -1 {
-			l.kratosLogger.WithContext(ctx).Debugf(l.traceStr, float64(elapsed.Nanoseconds())/1e6, "-", sql)
+			l.kratosLogger.WithContext(ctx).Debugf(l.traceStr, caller(), float64(elapsed.Nanoseconds())/1e6, "-", sql)
 		} else {
-			l.kratosLogger.WithContext(ctx).Debugf(l.traceStr, float64(elapsed.Nanoseconds())/1e6, rows, sql)
+			l.kratosLogger.WithContext(ctx).Debugf(l.traceStr, caller(), float64(elapsed.Nanoseconds())/1e6, rows, sql)
 		}
 	}
 }
 
-// Trace print sql message
+// analyze 采集指标、检测N+1查询、采样慢查询的EXPLAIN
+func (l *logger) analyze(ctx context.Context, elapsed time.Duration, get func() (string, int64), err error) {
+	if l.metrics == nil && l.explainer == nil && l.nPlusOneThreshold <= 0 {
+		return
+	}
+
+	// 优先使用StatementPlugin保存的原始SQL，避免每条SQL都拼接一次日志的SQL
+	stmt, captured := statementFromContext(ctx)
+	var sql string
+	if captured {
+		sql = stmt.sql
+	} else {
+		sql, _ = get()
+	}
+	operation, table := parseStatement(sql)
+	slow := l.SlowThreshold != 0 && elapsed > l.SlowThreshold
+
+	if l.metrics != nil {
+		l.metrics.duration.WithLabelValues(table, operation).Observe(elapsed.Seconds())
+		if err != nil && !errors.Is(err, gormLogger.ErrRecordNotFound) {
+			l.metrics.errors.WithLabelValues(table, operation).Inc()
+		}
+		if slow {
+			l.metrics.slow.WithLabelValues(table, operation).Inc()
+		}
+	}
+
+	if err != nil || operation != "select" {
+		return
+	}
+
+	// EXPLAIN需要原始的SQL和参数，日志的SQL可能不包含参数（ParameterizedQueries）或参数被隐藏（WithRedactedColumns）
+	if slow && l.explainer != nil && captured {
+		source := caller()
+		l.explainer.explain(ctx, stmt.sql, stmt.vars, func(ctx context.Context, plan string, err error) {
+			if err != nil {
+				l.kratosLogger.WithContext(ctx).Warnf("EXPLAIN slow sql failed: %v\n%s\n%s", err, source, sql)
+			} else {
+				l.kratosLogger.WithContext(ctx).Warnf("EXPLAIN slow sql (%.3fms) %s\n%s\n%s", float64(elapsed.Nanoseconds())/1e6, source, sql, plan)
+			}
+		})
+	}
+
+	if l.nPlusOneThreshold > 0 {
+		if tracker := fromContext(ctx); tracker != nil {
+			normalized := normalizeSQL(sql)
+			// 只在达到阈值时记录一次
+			if tracker.incr(normalized) == l.nPlusOneThreshold {
+				l.kratosLogger.WithContext(ctx).Warnf("N+1 query detected, executed %d times in one request: %s\n%s", l.nPlusOneThreshold, caller(), normalized)
+				if l.metrics != nil {
+					l.metrics.nPlusOne.WithLabelValues(table).Inc()
+				}
+			}
+		}
+	}
+}
+
+// ParamsFilter 过滤日志中的SQL参数：ParameterizedQueries时不输出参数，WithRedactedColumns的字段参数替换为RedactedValue
 func (l *logger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
 	if l.Config.ParameterizedQueries {
 		return sql, nil
 	}
+	if len(l.redactedColumns) > 0 {
+		params = l.redactParams(sql, params)
+	}
 	return sql, params
 }
@@ -0,0 +1,201 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/sqlite"
+	kratosLog "gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+type user struct {
+	ID       int64 `gorm:"primaryKey"`
+	Name     string
+	Password string
+}
+
+func (user) TableName() string {
+	return "users"
+}
+
+// syncBuffer 协程安全的日志输出
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// newTestDB 使用此logger打开SQLite内存数据库，并注册StatementPlugin，日志输出到返回的buffer中
+func newTestDB(t *testing.T, config Config, options ...Option) (*gorm.DB, *syncBuffer) {
+	t.Helper()
+
+	buf := &syncBuffer{}
+	helper := kratosLog.NewModuleHelper(kratosLog.New(context.Background(), kratosLog.WithWriter(buf)), "db")
+	orm, err := sqlite.OpenInMemory(&db.Config{Logger: New(helper, config, options...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := orm.DB(); err == nil {
+		t.Cleanup(func() {
+			_ = sqlDB.Close()
+		})
+	}
+	if err = orm.Use(StatementPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = orm.AutoMigrate(&user{}); err != nil {
+		t.Fatal(err)
+	}
+	return orm, buf
+}
+
+func TestNPlusOneDetection(t *testing.T) {
+	orm, buf := newTestDB(t, Config{LogLevel: gormLogger.Warn}, WithNPlusOneDetection(3))
+
+	// 没有调用NewContext时不检测
+	for i := 0; i < 5; i++ {
+		var u user
+		orm.WithContext(context.Background()).Where("id = ?", i).Find(&u)
+	}
+	if strings.Contains(buf.String(), "N+1") {
+		t.Fatalf("expected no N+1 warning without NewContext, got %s", buf.String())
+	}
+
+	ctx := NewContext(context.Background())
+	if NewContext(ctx) != ctx {
+		t.Fatal("expected NewContext keeps the existing tracker")
+	}
+	for i := 0; i < 5; i++ {
+		var u user
+		orm.WithContext(ctx).Where("id = ?", i).Find(&u)
+	}
+	var users []user
+	orm.WithContext(ctx).Where("name = ?", "a").Find(&users)
+
+	// 参数不同的相同SQL合并计数，达到阈值时只警告一次
+	tracker := fromContext(ctx)
+	if count := tracker.counts["SELECT * FROM `users` WHERE id = ?"]; count != 5 {
+		t.Fatalf("expected the query is counted 5 times, got %v", tracker.counts)
+	}
+	if len(tracker.counts) != 2 {
+		t.Fatalf("expected 2 different queries, got %v", tracker.counts)
+	}
+	if count := strings.Count(buf.String(), "N+1 query detected"); count != 1 {
+		t.Fatalf("expected 1 N+1 warning, got %d:\n%s", count, buf.String())
+	}
+}
+
+func TestRedactedColumns(t *testing.T) {
+	// 所有SQL都作为慢查询输出（带参数）
+	orm, buf := newTestDB(t, Config{LogLevel: gormLogger.Warn, SlowThreshold: time.Nanosecond}, WithRedactedColumns("Password"))
+	ctx := context.Background()
+
+	if err := orm.WithContext(ctx).Create(&user{Name: "tom", Password: "secret-1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var users []user
+	orm.WithContext(ctx).Where("name = ? AND `users`.`password` = ?", "tom", "secret-2").Find(&users)
+	orm.WithContext(ctx).Where("password IN ?", []string{"secret-3", "secret-4"}).Find(&users)
+	orm.WithContext(ctx).Model(&user{}).Where("id = ?", 1).Update("password", "secret-5")
+
+	logs := buf.String()
+	for _, secret := range []string{"secret-1", "secret-2", "secret-3", "secret-4", "secret-5"} {
+		if strings.Contains(logs, secret) {
+			t.Fatalf("expected %s is redacted:\n%s", secret, logs)
+		}
+	}
+	if !strings.Contains(logs, RedactedValue) || !strings.Contains(logs, "tom") {
+		t.Fatalf("expected only the password is redacted:\n%s", logs)
+	}
+}
+
+func TestRedactParams(t *testing.T) {
+	l := New(nil, Config{}, WithRedactedColumns("password", "phone")).(*logger)
+
+	cases := []struct {
+		sql      string
+		params   []any
+		expected []any
+	}{
+		{"INSERT INTO `users` (`name`,`password`) VALUES (?,?),(?,?)", []any{"a", "p1", "b", "p2"}, []any{"a", RedactedValue, "b", RedactedValue}},
+		{"INSERT INTO users (name,password) VALUES (?,?) ON CONFLICT (id) DO UPDATE SET name = ?", []any{"a", "p1", "b"}, []any{"a", RedactedValue, "b"}},
+		{"SELECT * FROM users WHERE phone IN (?,?,?) AND name = ?", []any{"1", "2", "3", "a"}, []any{RedactedValue, RedactedValue, RedactedValue, "a"}},
+		{`SELECT * FROM users WHERE "users"."password" = $1 AND name = $2`, []any{"p", "a"}, []any{RedactedValue, "a"}},
+		// 引号中的?不是占位符
+		{"SELECT * FROM users WHERE name = '?' AND password = ?", []any{"p"}, []any{RedactedValue}},
+		{"SELECT * FROM users WHERE name = ?", []any{"a"}, []any{"a"}},
+	}
+	for _, c := range cases {
+		actual := l.redactParams(c.sql, c.params)
+		if len(actual) != len(c.expected) {
+			t.Fatalf("%s: expected %v, got %v", c.sql, c.expected, actual)
+		}
+		for i := range actual {
+			if actual[i] != c.expected[i] {
+				t.Fatalf("%s: expected %v, got %v", c.sql, c.expected, actual)
+			}
+		}
+	}
+
+	// 没有隐藏的参数时返回原参数，不复制
+	params := []any{"a"}
+	if actual := l.redactParams("SELECT * FROM users WHERE name = ?", params); &actual[0] != &params[0] {
+		t.Fatal("expected the original params are returned")
+	}
+}
+
+func TestStatementPlugin(t *testing.T) {
+	orm, _ := newTestDB(t, Config{LogLevel: gormLogger.Silent})
+	ctx := context.Background()
+
+	// 同一个Statement执行多次，ctx只包装一次，保存的SQL为最后一次执行的
+	tx := orm.WithContext(ctx).Model(&user{}).Where("name = ?", "a")
+	var users []user
+	if err := tx.Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	bound := tx.Statement.Context
+	stmt, ok := statementFromContext(bound)
+	if !ok || !strings.HasPrefix(stmt.sql, "SELECT * FROM `users` WHERE name = ?") || len(stmt.vars) != 1 || stmt.vars[0] != "a" {
+		t.Fatalf("unexpected statement %+v", stmt)
+	}
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if tx.Statement.Context != bound {
+		t.Fatal("expected the context of a reused statement is not wrapped again")
+	}
+	if stmt, ok = statementFromContext(tx.Statement.Context); !ok || !strings.HasPrefix(stmt.sql, "SELECT count(*)") {
+		t.Fatalf("expected the statement of the last execution, got %+v", stmt)
+	}
+
+	// 复制的Statement不会使用上一个Statement保存的SQL
+	derived := tx.Session(&gorm.Session{})
+	if _, ok = statementFromContext(derived.Statement.Context); !ok {
+		t.Fatal("expected the derived context still points to the executed statement")
+	}
+	bindStatement(derived)
+	if _, ok = statementFromContext(derived.Statement.Context); ok {
+		t.Fatal("expected the copied statement setting is cleared before execution")
+	}
+}
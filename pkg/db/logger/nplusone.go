@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+	"sync"
+)
+
+// queryTracker 记录一个请求中每个SQL（参数替换为?后）的执行次数
+type queryTracker struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (t *queryTracker) incr(sql string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.counts[sql]++
+	return t.counts[sql]
+}
+
+type trackerKey struct{}
+
+// NewContext 开始记录ctx中执行的SQL，用于检测N+1查询（WithNPlusOneDetection）。通常在请求开始时调用
+func NewContext(ctx context.Context) context.Context {
+	if fromContext(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, trackerKey{}, &queryTracker{counts: map[string]int{}})
+}
+
+func fromContext(ctx context.Context) *queryTracker {
+	if ctx == nil {
+		return nil
+	}
+	tracker, _ := ctx.Value(trackerKey{}).(*queryTracker)
+	return tracker
+}
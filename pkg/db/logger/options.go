@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/metrics"
+	"gorm.io/gorm"
+	"strings"
+)
+
+type Option func(*logger)
+
+// WithMetrics 采集SQL的Prometheus指标（建议同时注册StatementPlugin，否则每条SQL都需要拼接一次日志的SQL）：
+//   - db_duration_sec{table, operation}：SQL耗时直方图
+//   - db_errors_total{table, operation}：SQL错误数
+//   - db_slow_total{table, operation}：慢查询数（需要设置Config.SlowThreshold）
+//   - db_n_plus_one_total{table}：N+1查询数（需要WithNPlusOneDetection）
+func WithMetrics(reg *metrics.Metrics) Option {
+	return func(l *logger) {
+		reg = reg.WithSubsystem("db")
+		l.metrics = &queryMetrics{
+			duration: reg.WithHelp("sql query duration(sec).").
+				RegisterHistogramVec("duration_sec", []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}, "table", "operation"),
+			errors: reg.WithHelp("The total number of failed sql queries").
+				RegisterCounterVec("errors_total", "table", "operation"),
+			slow: reg.WithHelp("The total number of slow sql queries").
+				RegisterCounterVec("slow_total", "table", "operation"),
+			nPlusOne: reg.WithHelp("The total number of detected N+1 queries").
+				RegisterCounterVec("n_plus_one_total", "table"),
+		}
+	}
+}
+
+// WithSlowQueryExplain 对慢查询（SELECT）按sampleRate（0~1）采样，异步执行EXPLAIN并记录到日志。
+// getDB返回执行EXPLAIN的连接，通常是使用此logger打开的db（logger先于db创建，所以需要传函数）。
+// 需要注册StatementPlugin，EXPLAIN使用原始的SQL和参数执行
+// example:
+//
+//	var orm *gorm.DB
+//	l := logger.New(helper, config, logger.WithSlowQueryExplain(func() *gorm.DB { return orm }, 0.1))
+//	orm, _ = gorm.Open(dialector, &gorm.Config{Logger: l})
+//	_ = orm.Use(logger.StatementPlugin{})
+func WithSlowQueryExplain(getDB func() *gorm.DB, sampleRate float64) Option {
+	return func(l *logger) {
+		l.explainer = newExplainer(getDB, sampleRate)
+	}
+}
+
+// WithNPlusOneDetection 检测N+1查询：同一个请求中，相同的SELECT（参数不同）执行了threshold次时记录一条警告。
+// 需要在请求的ctx中调用NewContext（或使用middleware/dblogger.Server()）
+func WithNPlusOneDetection(threshold int) Option {
+	return func(l *logger) {
+		l.nPlusOneThreshold = threshold
+	}
+}
+
+// WithRedactedColumns 日志中隐藏这些字段的参数值（比如password、id_card、phone），不区分大小写
+func WithRedactedColumns(columns ...string) Option {
+	return func(l *logger) {
+		if l.redactedColumns == nil {
+			l.redactedColumns = make(map[string]struct{}, len(columns))
+		}
+		for _, column := range columns {
+			l.redactedColumns[strings.ToLower(column)] = struct{}{}
+		}
+	}
+}
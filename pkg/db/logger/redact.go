@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RedactedValue 隐藏的参数值
+const RedactedValue = "[REDACTED]"
+
+var (
+	insertColumnsRegexp = regexp.MustCompile("(?is)^\\s*(?:insert|replace)\\s+into\\s+\\S+\\s*\\(([^)]*)\\)\\s*values\\b")
+	onClauseRegexp      = regexp.MustCompile(`(?i)\bon\s+(?:conflict|duplicate)\b`)
+	// 占位符前面的字段名，比如：`users`.`password` = ?、phone IN (?,?,
+	comparedColumnRegexp = regexp.MustCompile("(?i)([\\w.`\"]+)\\s*(?:=|<>|!=|>=|<=|>|<|\\blike|\\bin\\s*\\((?:\\s*(?:\\?|\\$\\d+)\\s*,)*)\\s*$")
+)
+
+type placeholder struct {
+	pos   int
+	index int
+}
+
+// findPlaceholders 找出SQL中的占位符（?或者$n），忽略引号中的内容
+func findPlaceholders(sql string) []placeholder {
+	var (
+		placeholders []placeholder
+		quote        byte
+		next         int
+	)
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			placeholders = append(placeholders, placeholder{pos: i, index: next})
+			next++
+		case c == '$':
+			j := i + 1
+			for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+				j++
+			}
+			if n, err := strconv.Atoi(sql[i+1 : j]); err == nil && n > 0 {
+				placeholders = append(placeholders, placeholder{pos: i, index: n - 1})
+				i = j - 1
+			}
+		}
+	}
+	return placeholders
+}
+
+func unquoteColumn(column string) string {
+	if i := strings.LastIndexByte(column, '.'); i >= 0 {
+		column = column[i+1:]
+	}
+	return strings.ToLower(strings.Trim(column, "`\""))
+}
+
+// redactParams 将redactedColumns字段对应的参数替换为RedactedValue，返回新的参数列表
+func (l *logger) redactParams(sql string, params []any) []any {
+	var (
+		insertColumns []string
+		valuesStart   int
+		valuesEnd     = len(sql)
+		redacted      []any
+	)
+	if matches := insertColumnsRegexp.FindStringSubmatchIndex(sql); matches != nil {
+		for _, column := range strings.Split(sql[matches[2]:matches[3]], ",") {
+			insertColumns = append(insertColumns, unquoteColumn(strings.TrimSpace(column)))
+		}
+		valuesStart = matches[1]
+		if loc := onClauseRegexp.FindStringIndex(sql[valuesStart:]); loc != nil {
+			valuesEnd = valuesStart + loc[0]
+		}
+	}
+
+	inValues := 0
+	for _, p := range findPlaceholders(sql) {
+		var column string
+		if len(insertColumns) > 0 && p.pos > valuesStart && p.pos < valuesEnd {
+			// INSERT ... VALUES (?,?),(?,?)：按顺序对应字段
+			column = insertColumns[inValues%len(insertColumns)]
+			inValues++
+		} else if matches := comparedColumnRegexp.FindStringSubmatch(sql[:p.pos]); matches != nil {
+			column = unquoteColumn(matches[1])
+		}
+
+		if _, ok := l.redactedColumns[column]; ok && p.index < len(params) {
+			if redacted == nil {
+				redacted = append([]any(nil), params...)
+			}
+			redacted[p.index] = RedactedValue
+		}
+	}
+
+	if redacted == nil {
+		return params
+	}
+	return redacted
+}
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"slices"
+)
+
+type statementKey struct{}
+
+// statement 执行的原始SQL（带占位符）和绑定的参数，不是日志中拼接（或隐藏）了参数的SQL
+type statement struct {
+	sql  string
+	vars []any
+}
+
+// StatementPlugin gorm插件，执行SQL后将原始的SQL和参数保存到Statement.Settings中，logger直接使用：
+//   - 慢查询的EXPLAIN使用原始的SQL和参数执行（WithSlowQueryExplain需要注册此插件，否则不会执行EXPLAIN）
+//   - 指标、N+1检测不再需要拼接日志的SQL（未注册时，每条SQL都需要拼接一次）
+//
+// example: orm.Use(logger.StatementPlugin{})
+type StatementPlugin struct{}
+
+var _ gorm.Plugin = StatementPlugin{}
+
+// statementSettingKey 保存在Statement.Settings中的key
+const statementSettingKey = "logger:statement"
+
+func (StatementPlugin) Name() string {
+	return "logger:statement"
+}
+
+func (StatementPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("*").Register("logger:statement_bind", bindStatement),
+		callbacks.Create().After("gorm:create").Register("logger:statement", captureStatement),
+		callbacks.Query().Before("*").Register("logger:statement_bind", bindStatement),
+		callbacks.Query().After("gorm:query").Register("logger:statement", captureStatement),
+		callbacks.Update().Before("*").Register("logger:statement_bind", bindStatement),
+		callbacks.Update().After("gorm:update").Register("logger:statement", captureStatement),
+		callbacks.Delete().Before("*").Register("logger:statement_bind", bindStatement),
+		callbacks.Delete().After("gorm:delete").Register("logger:statement", captureStatement),
+		callbacks.Row().Before("*").Register("logger:statement_bind", bindStatement),
+		callbacks.Row().After("gorm:row").Register("logger:statement", captureStatement),
+		callbacks.Raw().Before("*").Register("logger:statement_bind", bindStatement),
+		callbacks.Raw().After("gorm:raw").Register("logger:statement", captureStatement),
+	)
+}
+
+// bindStatement gorm在所有回调执行完毕后使用Statement.Context调用logger.Trace，所以需要在ctx中关联当前的Statement，
+// 每个Statement只包装一次ctx（重复执行的Statement不会让ctx越来越深）。
+// 同时清除上次执行（或者复制Statement时带过来的）SQL，避免被本次执行使用
+func bindStatement(db *gorm.DB) {
+	if db.Statement.Context == nil {
+		return
+	}
+	db.Statement.Settings.Delete(statementSettingKey)
+	if bound, _ := db.Statement.Context.Value(statementKey{}).(*gorm.Statement); bound != db.Statement {
+		db.Statement.Context = context.WithValue(db.Statement.Context, statementKey{}, db.Statement)
+	}
+}
+
+// captureStatement 将本次执行的SQL和参数保存到Statement.Settings
+func captureStatement(db *gorm.DB) {
+	if db.Statement.SQL.Len() == 0 {
+		return
+	}
+	db.Statement.Settings.Store(statementSettingKey, &statement{
+		sql:  db.Statement.SQL.String(),
+		vars: slices.Clone(db.Statement.Vars),
+	})
+}
+
+// statementFromContext 获取StatementPlugin保存的SQL和参数
+func statementFromContext(ctx context.Context) (*statement, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	bound, ok := ctx.Value(statementKey{}).(*gorm.Statement)
+	if !ok {
+		return nil, false
+	}
+	value, ok := bound.Settings.Load(statementSettingKey)
+	if !ok {
+		return nil, false
+	}
+	stmt, ok := value.(*statement)
+	return stmt, ok
+}
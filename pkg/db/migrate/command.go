@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/command"
+	"strings"
+	"text/tabwriter"
+)
+
+// NewMigrateCmd 迁移命令：migrate up、migrate down --step=1、migrate status
+func NewMigrateCmd(m *Migrator) command.ICmder {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Run database migrations",
+	}
+
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Run all pending migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			versions, err := m.Up(cmd.Context())
+			cmd.Printf("migrated up %d migration(s): %s\n", len(versions), strings.Join(versions, ", "))
+			return err
+		},
+	}
+
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Rollback the last batch of migrations, or the last N migrations with --step",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			step, _ := cmd.Flags().GetInt("step")
+			versions, err := m.Down(cmd.Context(), step)
+			cmd.Printf("migrated down %d migration(s): %s\n", len(versions), strings.Join(versions, ", "))
+			return err
+		},
+	}
+	down.Flags().Int("step", 0, "number of migrations to rollback, 0 means the last batch")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of each migration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			statuses, err := m.Status(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tBATCH\tAPPLIED AT")
+			for _, s := range statuses {
+				state, batch, appliedAt := "pending", "", ""
+				if s.Applied {
+					state, batch, appliedAt = "applied", fmt.Sprint(s.Batch), s.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				if s.Modified {
+					state += " (modified)"
+				} else if s.Missing {
+					state += " (missing)"
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Version, s.Name, state, batch, appliedAt)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.AddCommand(up, down, status)
+	return command.NewBaseCmd(cmd)
+}
+
+// NewSeedCmd 数据填充命令：db:seed、db:seed --force、db:seed --only=UserSeeder,RoleSeeder
+func NewSeedCmd(m *Migrator) command.ICmder {
+	cmd := &cobra.Command{
+		Use:   "db:seed",
+		Short: "Run database seeders",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			force, _ := cmd.Flags().GetBool("force")
+			only, _ := cmd.Flags().GetStringSlice("only")
+			names, err := m.Seed(cmd.Context(), force, only...)
+			cmd.Printf("seeded %d seeder(s): %s\n", len(names), strings.Join(names, ", "))
+			return err
+		},
+	}
+	cmd.Flags().Bool("force", false, "rerun the seeders which have been run")
+	cmd.Flags().StringSlice("only", nil, "only run the seeders with these names")
+	return command.NewBaseCmd(cmd)
+}
@@ -0,0 +1,125 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MigrateFunc Go代码的迁移函数，tx为事务（注意：MySQL的DDL会隐式提交，无法回滚）
+type MigrateFunc func(ctx context.Context, tx *db.DB) error
+
+// Migration 一个版本的迁移，Up/Down与UpSQL/DownSQL二选一
+type Migration struct {
+	// Version 版本号，按字符串排序执行，建议使用时间戳，比如：20240101120000
+	Version string
+	Name    string
+
+	Up   MigrateFunc
+	Down MigrateFunc
+
+	UpSQL   string
+	DownSQL string
+
+	// Checksum 为空时，SQL迁移使用UpSQL的sha256，Go迁移使用Version+Name的sha256。
+	// 注意：Go迁移无法计算代码的校验和，修改Up函数不会被检测到（只检测Version、Name的修改），
+	// 如果需要检测，请在修改Up函数时手动修改Checksum（比如设置为"v2"）
+	Checksum string
+}
+
+// GetChecksum 获取迁移的校验和，已执行的迁移被修改后，校验和不一致会拒绝继续迁移。
+// SQL迁移检测UpSQL的修改；Go迁移默认只检测Version、Name的修改，不检测Up函数的修改
+func (m *Migration) GetChecksum() string {
+	if m.Checksum != "" {
+		return m.Checksum
+	}
+	content := m.UpSQL
+	if m.Up != nil || content == "" {
+		content = m.Version + "_" + m.Name
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Migration) up(ctx context.Context, tx *db.DB) error {
+	if m.Up != nil {
+		return m.Up(ctx, tx)
+	} else if strings.TrimSpace(m.UpSQL) != "" {
+		return tx.Exec(m.UpSQL).Error
+	}
+	return nil
+}
+
+func (m *Migration) down(ctx context.Context, tx *db.DB) error {
+	if m.Down != nil {
+		return m.Down(ctx, tx)
+	} else if strings.TrimSpace(m.DownSQL) != "" {
+		return tx.Exec(m.DownSQL).Error
+	}
+	return errors.Errorf("migration %s_%s has no down migration", m.Version, m.Name)
+}
+
+// sqlFileRegexp 迁移文件名：{version}_{name}.up.sql、{version}_{name}.down.sql
+var sqlFileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadSQL 从fsys（比如embed.FS）的dir目录中加载SQL迁移文件，文件名格式：{version}_{name}.up.sql、{version}_{name}.down.sql
+// 注意：一个文件中包含多条SQL时，MySQL的DSN需要开启multiStatements=true
+// example:
+//
+//	//go:embed migrations/*.sql
+//	var migrationFS embed.FS
+//	migrations, err := migrate.LoadSQL(migrationFS, "migrations")
+func LoadSQL(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read migrations dir \"%s\" failed", dir)
+	}
+
+	migrations := map[string]*Migration{}
+	for _, entry := range entries {
+		matches := sqlFileRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read migration file \"%s\" failed", entry.Name())
+		}
+
+		version, name := matches[1], matches[2]
+		m, ok := migrations[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			migrations[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %s has different names: \"%s\" and \"%s\"", version, m.Name, name)
+		}
+		if matches[3] == "up" {
+			m.UpSQL = string(content)
+		} else {
+			m.DownSQL = string(content)
+		}
+	}
+
+	results := make([]*Migration, 0, len(migrations))
+	for _, m := range migrations {
+		results = append(results, m)
+	}
+	sortMigrations(results)
+	return results, nil
+}
+
+func sortMigrations(migrations []*Migration) {
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
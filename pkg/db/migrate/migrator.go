@@ -0,0 +1,384 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/clause"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"os"
+	"slices"
+	"time"
+)
+
+var (
+	ErrLocked           = errors.New("migration is locked by another process")
+	ErrLockLost         = errors.New("migration lock is lost")
+	ErrChecksumMismatch = errors.New("applied migration has been modified")
+)
+
+// Migrator 数据库迁移：按版本执行up/down迁移、AutoMigrate模型、按顺序执行seeders。
+// 使用数据库表实现的锁（持有期间定时续期），保证集群中只有一个进程在迁移
+type Migrator struct {
+	db     *db.DB
+	logger *log.Helper
+
+	migrations []*Migration
+	models     db.ModelCollection
+	seeders    db.SeederCollection
+
+	tablePrefix string
+	lockWait    time.Duration
+	lockTTL     time.Duration
+}
+
+func NewMigrator(_db *db.DB, logger log.Logger, options ...Option) *Migrator {
+	m := &Migrator{
+		db:          _db,
+		logger:      log.NewModuleHelper(logger, "db/migrate"),
+		tablePrefix: "schema_",
+		lockWait:    time.Minute,
+		lockTTL:     10 * time.Minute,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	sortMigrations(m.migrations)
+	return m
+}
+
+func (m *Migrator) table(ctx context.Context, name string) *db.DB {
+	return m.db.WithContext(ctx).Table(m.tablePrefix + name)
+}
+
+// ensureTables 创建迁移相关的表
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	for name, model := range map[string]any{
+		"migrations": &schemaMigration{},
+		"seeders":    &schemaSeeder{},
+		"locks":      &schemaLock{},
+	} {
+		if err := m.table(ctx, name).AutoMigrate(model); err != nil {
+			return errors.Wrapf(err, "create table \"%s%s\" failed", m.tablePrefix, name)
+		}
+	}
+	return nil
+}
+
+// lock 获取迁移锁，返回持有锁期间有效的ctx（失去锁时被取消）和释放锁的函数。
+// 锁已被其他进程持有时等待lockWait，超时返回ErrLocked；其它数据库错误直接返回
+func (m *Migrator) lock(ctx context.Context) (context.Context, func(), error) {
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano())
+	deadline := time.Now().Add(m.lockWait)
+
+	for {
+		// 抢占过期的锁（持有锁的进程可能已经崩溃，正常持有时会定时续期，不会过期）
+		if err := m.table(ctx, "locks").Where("id = 1 AND locked_at < ?", time.Now().Add(-m.lockTTL)).Delete(&schemaLock{}).Error; err != nil {
+			return nil, nil, errors.Wrap(err, "delete expired migration lock failed")
+		}
+
+		err := m.table(ctx, "locks").Create(&schemaLock{ID: 1, Owner: owner, LockedAt: time.Now()}).Error
+		if err == nil {
+			lockCtx, unlock := m.keepLock(ctx, owner)
+			return lockCtx, unlock, nil
+		}
+
+		// 插入失败但锁不存在，说明不是锁冲突，而是数据库错误（比如没有权限、连接断开）
+		var count int64
+		if countErr := m.table(ctx, "locks").Where("id = 1").Count(&count).Error; countErr != nil {
+			return nil, nil, errors.Wrap(countErr, "query migration lock failed")
+		} else if count == 0 {
+			return nil, nil, errors.Wrap(err, "acquire migration lock failed")
+		}
+
+		if time.Now().After(deadline) {
+			return nil, nil, ErrLocked
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// keepLock 持有锁期间每lockTTL/3续期一次，所以迁移的执行时间可以超过lockTTL。
+// 锁已经被其他进程抢占，或者超过2/3的lockTTL没有续期成功（锁即将过期）时，取消返回的ctx，中止迁移
+func (m *Migrator) keepLock(ctx context.Context, owner string) (context.Context, func()) {
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(max(m.lockTTL/3, time.Second))
+		defer ticker.Stop()
+
+		renewedAt := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-lockCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			result := m.table(lockCtx, "locks").Where("id = 1 AND owner = ?", owner).Update("locked_at", time.Now())
+			switch {
+			case result.Error == nil && result.RowsAffected == 0:
+				m.logger.WithContext(ctx).Error("migration lock is taken over by another process")
+				cancel(ErrLockLost)
+				return
+			case result.Error == nil:
+				renewedAt = time.Now()
+			case time.Since(renewedAt) > m.lockTTL*2/3:
+				m.logger.WithContext(ctx).Errorf("renew migration lock failed, the lock is about to expire: %v", result.Error)
+				cancel(ErrLockLost)
+				return
+			default:
+				m.logger.WithContext(ctx).Warnf("renew migration lock failed: %v", result.Error)
+			}
+		}
+	}()
+
+	return lockCtx, func() {
+		close(stop)
+		<-stopped
+		cancel(nil)
+		// 使用新的ctx，保证ctx取消后也能释放锁
+		m.table(context.Background(), "locks").Where("id = 1 AND owner = ?", owner).Delete(&schemaLock{})
+	}
+}
+
+// prepare 创建表并获取锁，之后的迁移需要使用返回的ctx
+func (m *Migrator) prepare(ctx context.Context) (context.Context, func(), error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, nil, err
+	}
+	return m.lock(ctx)
+}
+
+// applied 获取已执行的迁移，按版本升序
+func (m *Migrator) applied(ctx context.Context) ([]*schemaMigration, error) {
+	var records []*schemaMigration
+	if err := m.table(ctx, "migrations").Order("version").Find(&records).Error; err != nil {
+		return nil, errors.Wrap(err, "query applied migrations failed")
+	}
+	return records, nil
+}
+
+func (m *Migrator) findMigration(version string) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
+
+// Up 执行AutoMigrate（如果设置了WithAutoMigrate），然后按版本执行所有未执行的迁移，返回本次执行的版本。
+// 已执行的迁移被修改（校验和不一致，Go迁移见Migration.Checksum）时返回ErrChecksumMismatch，不会执行任何迁移
+func (m *Migrator) Up(ctx context.Context) ([]string, error) {
+	ctx, unlock, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if len(m.models) > 0 {
+		models := make([]any, 0, len(m.models))
+		for _, model := range m.models {
+			models = append(models, model)
+		}
+		if err = m.db.WithContext(ctx).AutoMigrate(models...); err != nil {
+			return nil, errors.Wrap(err, "auto migrate models failed")
+		}
+	}
+
+	records, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]*schemaMigration, len(records))
+	batch := 0
+	for _, record := range records {
+		applied[record.Version] = record
+		batch = max(batch, record.Batch)
+		if migration := m.findMigration(record.Version); migration != nil && migration.GetChecksum() != record.Checksum {
+			return nil, errors.Wrapf(ErrChecksumMismatch, "migration %s_%s", record.Version, record.Name)
+		}
+	}
+	batch++
+
+	var versions []string
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err = m.db.WithContext(ctx).Transaction(func(tx *db.DB) error {
+			if err := migration.up(ctx, tx); err != nil {
+				return err
+			}
+			return tx.Table(m.tablePrefix + "migrations").Create(&schemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.GetChecksum(),
+				Batch:     batch,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return versions, errors.Wrapf(err, "migrate up %s_%s failed", migration.Version, migration.Name)
+		}
+
+		versions = append(versions, migration.Version)
+		m.logger.WithContext(ctx).Infof("migrated up: %s_%s", migration.Version, migration.Name)
+	}
+	return versions, nil
+}
+
+// Down 回滚最近的steps个迁移，steps<=0时回滚最后一批（最后一次Up执行的迁移），返回本次回滚的版本
+func (m *Migrator) Down(ctx context.Context, steps int) ([]string, error) {
+	ctx, unlock, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	records, err := m.applied(ctx)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	slices.Reverse(records)
+
+	if steps <= 0 {
+		lastBatch := 0
+		for _, record := range records {
+			lastBatch = max(lastBatch, record.Batch)
+		}
+		records = slices.DeleteFunc(records, func(record *schemaMigration) bool {
+			return record.Batch != lastBatch
+		})
+	} else if steps < len(records) {
+		records = records[:steps]
+	}
+
+	var versions []string
+	for _, record := range records {
+		migration := m.findMigration(record.Version)
+		if migration == nil {
+			return versions, errors.Errorf("migration %s_%s is not found in code", record.Version, record.Name)
+		}
+
+		err = m.db.WithContext(ctx).Transaction(func(tx *db.DB) error {
+			if err := migration.down(ctx, tx); err != nil {
+				return err
+			}
+			return tx.Table(m.tablePrefix+"migrations").Where("version = ?", record.Version).Delete(&schemaMigration{}).Error
+		})
+		if err != nil {
+			return versions, errors.Wrapf(err, "migrate down %s_%s failed", migration.Version, migration.Name)
+		}
+
+		versions = append(versions, migration.Version)
+		m.logger.WithContext(ctx).Infof("migrated down: %s_%s", migration.Version, migration.Name)
+	}
+	return versions, nil
+}
+
+// Status 获取所有迁移的状态，按版本升序
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	records, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]*schemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	var statuses []*Status
+	for _, migration := range m.migrations {
+		status := &Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.Batch = record.Batch
+			status.AppliedAt = &record.AppliedAt
+			status.Modified = record.Checksum != migration.GetChecksum()
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range records {
+		if _, ok := applied[record.Version]; ok {
+			statuses = append(statuses, &Status{
+				Version:   record.Version,
+				Name:      record.Name,
+				Applied:   true,
+				Batch:     record.Batch,
+				AppliedAt: &record.AppliedAt,
+				Missing:   true,
+			})
+		}
+	}
+	slices.SortStableFunc(statuses, func(a, b *Status) int {
+		return cmpString(a.Version, b.Version)
+	})
+	return statuses, nil
+}
+
+// Seed 按顺序执行seeders，已执行过的会跳过（force为true时重新执行），names不为空时只执行指定名称的seeder。返回本次执行的seeder
+func (m *Migrator) Seed(ctx context.Context, force bool, names ...string) ([]string, error) {
+	ctx, unlock, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var records []*schemaSeeder
+	if err = m.table(ctx, "seeders").Find(&records).Error; err != nil {
+		return nil, errors.Wrap(err, "query ran seeders failed")
+	}
+	ran := make(map[string]bool, len(records))
+	for _, record := range records {
+		ran[record.Name] = true
+	}
+
+	var executed []string
+	for _, seeder := range m.seeders {
+		name := seeder.GetName()
+		if (len(names) > 0 && !slices.Contains(names, name)) || (ran[name] && !force) {
+			continue
+		}
+
+		if err = seeder.Handle(ctx); err != nil {
+			return executed, errors.Wrapf(err, "seeder \"%s\" failed", name)
+		}
+		if err = m.table(ctx, "seeders").
+			Clauses(clause.OnConflict{UpdateAll: true}).
+			Create(&schemaSeeder{Name: name, RanAt: time.Now()}).Error; err != nil {
+			return executed, errors.Wrapf(err, "record seeder \"%s\" failed", name)
+		}
+
+		executed = append(executed, name)
+		m.logger.WithContext(ctx).Infof("seeded: %s", name)
+	}
+	return executed, nil
+}
+
+func cmpString(a, b string) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,240 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/sqlite"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	orm, err := sqlite.OpenInMemory(&db.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := orm.DB(); err == nil {
+		t.Cleanup(func() {
+			_ = sqlDB.Close()
+		})
+	}
+	return orm
+}
+
+// recordMigration 返回一个Go迁移，执行up/down时记录到steps中
+func recordMigration(version string, steps *[]string) *Migration {
+	return &Migration{
+		Version: version,
+		Name:    "create_t" + version,
+		Up: func(ctx context.Context, tx *db.DB) error {
+			*steps = append(*steps, "up:"+version)
+			return tx.Exec("CREATE TABLE t" + version + " (id INTEGER)").Error
+		},
+		Down: func(ctx context.Context, tx *db.DB) error {
+			*steps = append(*steps, "down:"+version)
+			return tx.Exec("DROP TABLE t" + version).Error
+		},
+	}
+}
+
+func assertStrings(t *testing.T, actual []string, expected ...string) {
+	t.Helper()
+	if !slices.Equal(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	orm := newTestDB(t)
+	ctx := context.Background()
+	var steps []string
+
+	// 按Version排序执行，与添加的顺序无关
+	m := NewMigrator(orm, log.New(ctx), WithMigrations(recordMigration("2", &steps), recordMigration("1", &steps)))
+	versions, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStrings(t, versions, "1", "2")
+
+	// 第二批
+	m = NewMigrator(orm, log.New(ctx), WithMigrations(recordMigration("2", &steps), recordMigration("1", &steps), &Migration{
+		Version: "3",
+		Name:    "create_t3",
+		UpSQL:   "CREATE TABLE t3 (id INTEGER)",
+		DownSQL: "DROP TABLE t3",
+	}))
+	if versions, err = m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertStrings(t, versions, "3")
+	if versions, err = m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertStrings(t, versions)
+
+	// steps<=0回滚最后一批，然后按版本倒序回滚
+	if versions, err = m.Down(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	assertStrings(t, versions, "3")
+	if versions, err = m.Down(ctx, 5); err != nil {
+		t.Fatal(err)
+	}
+	assertStrings(t, versions, "2", "1")
+	assertStrings(t, steps, "up:1", "up:2", "down:2", "down:1")
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Fatalf("expected migration %s is not applied", status.Version)
+		}
+	}
+}
+
+func TestUpFailedRollback(t *testing.T) {
+	orm := newTestDB(t)
+	ctx := context.Background()
+
+	m := NewMigrator(orm, log.New(ctx), WithMigrations(
+		&Migration{Version: "1", Name: "ok", UpSQL: "CREATE TABLE t1 (id INTEGER)"},
+		&Migration{Version: "2", Name: "broken", UpSQL: "CREATE TABLE"},
+		&Migration{Version: "3", Name: "never", UpSQL: "CREATE TABLE t3 (id INTEGER)"},
+	))
+	versions, err := m.Up(ctx)
+	if err == nil {
+		t.Fatal("expected error of the broken migration")
+	}
+	// 失败的迁移及之后的迁移都不会执行，之前的迁移保留
+	assertStrings(t, versions, "1")
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if !statuses[0].Applied || statuses[1].Applied || statuses[2].Applied {
+		t.Fatalf("unexpected statuses %+v %+v %+v", statuses[0], statuses[1], statuses[2])
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	orm := newTestDB(t)
+	ctx := context.Background()
+	var steps []string
+
+	goMigration := recordMigration("1", &steps)
+	m := NewMigrator(orm, log.New(ctx), WithMigrations(goMigration, &Migration{Version: "2", Name: "sql", UpSQL: "CREATE TABLE t2 (id INTEGER)"}))
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 修改了已执行的SQL迁移
+	m = NewMigrator(orm, log.New(ctx), WithMigrations(goMigration, &Migration{Version: "2", Name: "sql", UpSQL: "CREATE TABLE t2 (id BIGINT)"}))
+	if _, err := m.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+
+	// Go迁移修改了Up函数不会被检测到，需要手动修改Checksum
+	changed := recordMigration("1", &steps)
+	m = NewMigrator(orm, log.New(ctx), WithMigrations(changed, &Migration{Version: "2", Name: "sql", UpSQL: "CREATE TABLE t2 (id INTEGER)"}))
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	changed.Checksum = "v2"
+	if _, err := m.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	orm := newTestDB(t)
+	ctx := context.Background()
+
+	m := NewMigrator(orm, log.New(ctx), WithLock(0, time.Minute))
+	if err := m.ensureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, unlock, err := m.lock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他进程持有锁时，超过等待时间返回ErrLocked
+	other := NewMigrator(orm, log.New(ctx), WithLock(0, time.Minute))
+	if _, err = other.Up(ctx); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	unlock()
+	if _, err = other.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockExpired(t *testing.T) {
+	orm := newTestDB(t)
+	ctx := context.Background()
+
+	m := NewMigrator(orm, log.New(ctx), WithLock(0, time.Minute))
+	if err := m.ensureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 崩溃的进程留下的过期锁可以被抢占
+	if err := m.table(ctx, "locks").Create(&schemaLock{ID: 1, Owner: "crashed", LockedAt: time.Now().Add(-2 * time.Minute)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockRenewal(t *testing.T) {
+	orm := newTestDB(t)
+	ctx := context.Background()
+
+	// 每秒续期一次
+	m := NewMigrator(orm, log.New(ctx), WithLock(0, 3*time.Second))
+	if err := m.ensureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lockCtx, unlock, err := m.lock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	var before schemaLock
+	if err = m.table(ctx, "locks").First(&before).Error; err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	var after schemaLock
+	if err = m.table(ctx, "locks").First(&after).Error; err != nil {
+		t.Fatal(err)
+	} else if !after.LockedAt.After(before.LockedAt) {
+		t.Fatalf("expected the lock is renewed, locked at %v -> %v", before.LockedAt, after.LockedAt)
+	} else if lockCtx.Err() != nil {
+		t.Fatalf("expected the lock is held, got %v", context.Cause(lockCtx))
+	}
+
+	// 锁被其他进程抢占后，取消迁移的ctx
+	if err = m.table(ctx, "locks").Where("id = 1").Update("owner", "other").Error; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-lockCtx.Done():
+		if !errors.Is(context.Cause(lockCtx), ErrLockLost) {
+			t.Fatalf("expected ErrLockLost, got %v", context.Cause(lockCtx))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the lock context is canceled")
+	}
+}
@@ -0,0 +1,38 @@
+package migrate
+
+import "time"
+
+// schemaMigration 已执行的迁移记录
+type schemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:64"`
+	Name      string    `gorm:"size:255;not null"`
+	Checksum  string    `gorm:"size:64;not null"`
+	Batch     int       `gorm:"not null;index"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// schemaSeeder 已执行的seeder记录
+type schemaSeeder struct {
+	Name  string    `gorm:"primaryKey;size:255"`
+	RanAt time.Time `gorm:"not null"`
+}
+
+// schemaLock 迁移锁，只有一行，插入成功表示获取到锁
+type schemaLock struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"size:255;not null"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+// Status 迁移的状态
+type Status struct {
+	Version   string     `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	Batch     int        `json:"batch,omitempty"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Modified 已执行的迁移被修改了（校验和不一致），Go迁移只有修改了Version、Name或Checksum时才会检测到
+	Modified bool `json:"modified,omitempty"`
+	// Missing 数据库中有记录，但是代码中已经没有此迁移
+	Missing bool `json:"missing,omitempty"`
+}
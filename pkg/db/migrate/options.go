@@ -0,0 +1,44 @@
+package migrate
+
+import (
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"time"
+)
+
+type Option func(*Migrator)
+
+// WithMigrations 添加迁移，会按Version排序
+func WithMigrations(migrations ...*Migration) Option {
+	return func(m *Migrator) {
+		m.migrations = append(m.migrations, migrations...)
+	}
+}
+
+// WithAutoMigrate 在执行迁移之前，对models执行gorm的AutoMigrate
+func WithAutoMigrate(models db.ModelCollection) Option {
+	return func(m *Migrator) {
+		m.models = models
+	}
+}
+
+// WithSeeders 添加seeders，按顺序执行
+func WithSeeders(seeders db.SeederCollection) Option {
+	return func(m *Migrator) {
+		m.seeders = append(m.seeders, seeders...)
+	}
+}
+
+// WithTablePrefix 迁移相关表的前缀，默认为schema_，即：schema_migrations、schema_seeders、schema_locks
+func WithTablePrefix(prefix string) Option {
+	return func(m *Migrator) {
+		m.tablePrefix = prefix
+	}
+}
+
+// WithLock 等待迁移锁的超时时间（默认1分钟），以及锁的过期时间（默认10分钟，持有锁期间每ttl/3续期一次，进程崩溃后锁会在过期后被抢占）
+func WithLock(wait time.Duration, ttl time.Duration) Option {
+	return func(m *Migrator) {
+		m.lockWait = wait
+		m.lockTTL = ttl
+	}
+}
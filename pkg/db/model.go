@@ -44,3 +44,34 @@ func (m *SoftDeleteModel) TableName() string {
 func (m *SoftDeleteModel) OnEvent(ctx context.Context, tx *gorm.DB, model schema.Tabler, eventType event.EventType) error {
 	return fireModelEvent(ctx, tx, model, eventType)
 }
+
+// IVersionedModel 实现了此接口的Model启用乐观锁（需要有数据库字段名为version的整数字段），
+// 只有version字段而没有实现此接口的Model不会启用乐观锁
+type IVersionedModel interface {
+	GetVersion() int64
+}
+
+// VersionedModel 带乐观锁的Model，Repository的Update/Save/UpdateColumns会将version加入WHERE条件并递增，
+// 没有更新到记录时返回ErrStaleModel。也可以在自己的Model中添加Version字段（数据库字段名为version）并实现IVersionedModel来启用
+type VersionedModel struct {
+	ID        int64     `gorm:"primaryKey" json:"id" yaml:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int64     `gorm:"not null;default:0" json:"version"`
+}
+
+func (m *VersionedModel) GetID() int64 {
+	return m.ID
+}
+
+func (m *VersionedModel) GetVersion() int64 {
+	return m.Version
+}
+
+func (m *VersionedModel) TableName() string {
+	return ""
+}
+
+func (m *VersionedModel) OnEvent(ctx context.Context, tx *gorm.DB, model schema.Tabler, eventType event.EventType) error {
+	return fireModelEvent(ctx, tx, model, eventType)
+}
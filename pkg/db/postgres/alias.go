@@ -0,0 +1,10 @@
+package postgres
+
+import "gorm.io/driver/postgres"
+
+type Config = postgres.Config
+
+var (
+	Open = postgres.Open
+	New  = postgres.New
+)
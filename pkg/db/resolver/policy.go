@@ -0,0 +1,41 @@
+package resolver
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"sync/atomic"
+)
+
+type (
+	Policy       = dbresolver.Policy
+	RandomPolicy = dbresolver.RandomPolicy
+)
+
+// RoundRobinPolicy 轮询从库
+type RoundRobinPolicy struct {
+	next atomic.Uint64
+}
+
+func (p *RoundRobinPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	return connPools[(p.next.Add(1)-1)%uint64(len(connPools))]
+}
+
+// healthPolicy 过滤掉被健康检查剔除的从库，再交给policy选择
+type healthPolicy struct {
+	policy   Policy
+	resolver *Resolver
+}
+
+func (p *healthPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	healthy := make([]gorm.ConnPool, 0, len(connPools))
+	for _, connPool := range connPools {
+		if !p.resolver.isEjected(connPool) {
+			healthy = append(healthy, connPool)
+		}
+	}
+	// 全部剔除时，forcePrimary会切换到主库，这里只是兜底
+	if len(healthy) == 0 {
+		healthy = connPools
+	}
+	return p.policy.Resolve(healthy)
+}
@@ -0,0 +1,255 @@
+package resolver
+
+import (
+	"context"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"strings"
+	"sync"
+	"time"
+)
+
+// writeSettingName dbresolver中表示使用主库的Statement.Settings的key
+const writeSettingName = "gorm:db_resolver:write"
+
+type Config struct {
+	// Primary 主库的DSN
+	Primary string
+	// Replicas 从库的DSN
+	Replicas []string
+	// Policy 从库的负载均衡策略，默认为RandomPolicy，也可以使用&RoundRobinPolicy{}
+	Policy Policy
+	// StickyDuration 同一个请求上下文（resolver.NewContext）写入后，读操作使用主库的时间，0表示不启用
+	StickyDuration time.Duration
+	// HealthCheckInterval 从库健康检查的间隔，0表示不启用。需要将Resolver注册到app的servers中
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout 单次健康检查的超时时间，默认3秒
+	HealthCheckTimeout time.Duration
+	// EjectAfterFailures 连续失败多少次后剔除从库，默认3次。剔除后检查成功一次即恢复
+	EjectAfterFailures int
+}
+
+// Resolver 读写分离，基于gorm的dbresolver插件，增加了写后读主库、从库健康检查
+type Resolver struct {
+	config *Config
+	logger *log.Helper
+	plugin *dbresolver.DBResolver
+
+	primary  gorm.ConnPool
+	replicas []gorm.ConnPool
+
+	mu       sync.RWMutex
+	failures map[gorm.ConnPool]int
+	ejected  map[gorm.ConnPool]bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ transport.Server = (*Resolver)(nil)
+
+// Open 打开主库，并配置从库的读写分离
+// example:
+//
+//	orm, r, err := resolver.Open(mysql.Open, resolver.Config{
+//		Primary:             "root:pwd@tcp(primary:3306)/app",
+//		Replicas:            []string{"root:pwd@tcp(replica1:3306)/app", "root:pwd@tcp(replica2:3306)/app"},
+//		StickyDuration:      3 * time.Second,
+//		HealthCheckInterval: 10 * time.Second,
+//	}, &db.Config{}, logger)
+func Open(open func(dsn string) gorm.Dialector, config Config, gormConfig *db.Config, logger log.Logger) (*db.DB, *Resolver, error) {
+	if config.Policy == nil {
+		config.Policy = RandomPolicy{}
+	}
+	if config.HealthCheckTimeout <= 0 {
+		config.HealthCheckTimeout = 3 * time.Second
+	}
+	if config.EjectAfterFailures <= 0 {
+		config.EjectAfterFailures = 3
+	}
+
+	orm, err := db.Open(open(config.Primary), gormConfig)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "open primary database failed")
+	}
+
+	r := &Resolver{
+		config:   &config,
+		logger:   log.NewModuleHelper(logger, "db/resolver"),
+		primary:  orm.ConnPool,
+		failures: map[gorm.ConnPool]int{},
+		ejected:  map[gorm.ConnPool]bool{},
+	}
+
+	if preparedStmtDB, ok := r.primary.(*gorm.PreparedStmtDB); ok {
+		r.primary = preparedStmtDB.ConnPool
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(config.Replicas))
+	for _, dsn := range config.Replicas {
+		replicas = append(replicas, open(dsn))
+	}
+	r.plugin = dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   &healthPolicy{policy: config.Policy, resolver: r},
+	})
+	if err = orm.Use(r.plugin); err != nil {
+		return nil, nil, errors.Wrap(err, "register replicas failed")
+	}
+
+	r.plugin.Call(func(connPool gorm.ConnPool) error {
+		if connPool != r.primary {
+			r.replicas = append(r.replicas, connPool)
+		}
+		return nil
+	})
+
+	if err = r.registerCallbacks(orm); err != nil {
+		return nil, nil, err
+	}
+	return orm, r, nil
+}
+
+// Plugin 获取dbresolver插件，可以设置连接池参数，比如：r.Plugin().SetMaxOpenConns(100)
+func (r *Resolver) Plugin() *dbresolver.DBResolver {
+	return r.plugin
+}
+
+func (r *Resolver) registerCallbacks(orm *db.DB) error {
+	callbacks := orm.Callback()
+	// dbresolver的回调注册为Before("*")，无法在其之前注册，所以包装原回调
+	query, row, raw := callbacks.Query().Get("gorm:db_resolver"), callbacks.Row().Get("gorm:db_resolver"), callbacks.Raw().Get("gorm:db_resolver")
+	for _, err := range []error{
+		callbacks.Query().Before("*").Replace("gorm:db_resolver", r.forcePrimary(query)),
+		callbacks.Row().Before("*").Replace("gorm:db_resolver", r.forcePrimary(row)),
+		// dbresolver会将Raw回调中的SELECT路由到从库，同样需要读到刚写入的数据
+		callbacks.Raw().Before("*").Replace("gorm:db_resolver", r.forcePrimary(raw)),
+		callbacks.Create().After("gorm:create").Register("resolver:mark_written", r.afterWrite),
+		callbacks.Update().After("gorm:update").Register("resolver:mark_written", r.afterWrite),
+		callbacks.Delete().After("gorm:delete").Register("resolver:mark_written", r.afterWrite),
+		callbacks.Raw().After("gorm:raw").Register("resolver:mark_written", r.afterRaw),
+	} {
+		if err != nil {
+			return errors.Wrap(err, "register resolver callbacks failed")
+		}
+	}
+	return nil
+}
+
+// forcePrimary 写入后的StickyDuration内、或从库全部被剔除时，读操作使用主库
+func (r *Resolver) forcePrimary(next func(*gorm.DB)) func(*gorm.DB) {
+	return func(orm *gorm.DB) {
+		if _, isTx := orm.Statement.ConnPool.(gorm.TxCommitter); !isTx &&
+			(isSticky(orm.Statement.Context, r.config.StickyDuration) || r.allEjected()) {
+			// 等同于Clauses(clause.Write)，不能调用dbresolver.Write.ModifyStatement，它会再次调用本回调
+			orm.Statement.Settings.Store(writeSettingName, struct{}{})
+		}
+		next(orm)
+	}
+}
+
+func (r *Resolver) afterWrite(orm *gorm.DB) {
+	if orm.Error == nil {
+		markWritten(orm.Statement.Context)
+	}
+}
+
+func (r *Resolver) afterRaw(orm *gorm.DB) {
+	if sql := strings.TrimSpace(orm.Statement.SQL.String()); orm.Error == nil && !strings.EqualFold(sql[:min(len(sql), 6)], "select") {
+		markWritten(orm.Statement.Context)
+	}
+}
+
+func (r *Resolver) isEjected(connPool gorm.ConnPool) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ejected[connPool]
+}
+
+func (r *Resolver) allEjected() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.replicas) > 0 && len(r.ejected) >= len(r.replicas)
+}
+
+// HealthyReplicas 返回健康的从库数量和从库总数
+func (r *Resolver) HealthyReplicas() (healthy int, total int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.replicas) - len(r.ejected), len(r.replicas)
+}
+
+// check 检查所有从库，连续失败EjectAfterFailures次后剔除，成功一次后恢复
+func (r *Resolver) check(ctx context.Context) {
+	for i, connPool := range r.replicas {
+		pinger, ok := connPool.(interface{ PingContext(context.Context) error })
+		if !ok {
+			continue
+		}
+
+		_ctx, cancel := context.WithTimeout(ctx, r.config.HealthCheckTimeout)
+		err := pinger.PingContext(_ctx)
+		cancel()
+
+		r.mu.Lock()
+		if err != nil {
+			r.failures[connPool]++
+			if r.failures[connPool] >= r.config.EjectAfterFailures && !r.ejected[connPool] {
+				r.ejected[connPool] = true
+				r.logger.WithContext(ctx).Errorf("replica #%d is ejected after %d failures: %v", i, r.failures[connPool], err)
+			}
+		} else {
+			delete(r.failures, connPool)
+			if r.ejected[connPool] {
+				delete(r.ejected, connPool)
+				r.logger.WithContext(ctx).Infof("replica #%d is healthy again", i)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Start 启动从库的健康检查
+func (r *Resolver) Start(ctx context.Context) error {
+	if r.config.HealthCheckInterval <= 0 || len(r.replicas) == 0 {
+		return nil
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.config.HealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.check(ctx)
+			}
+		}
+	}()
+
+	r.logger.WithContext(ctx).Infof("health check of %d replicas started", len(r.replicas))
+	return nil
+}
+
+// Stop 停止从库的健康检查
+func (r *Resolver) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	r.logger.WithContext(ctx).Info("health check of replicas stopped")
+	return nil
+}
@@ -0,0 +1,46 @@
+package resolver
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type stickyKey struct{}
+
+// sticky 记录同一个请求上下文中最后一次写入的时间
+type sticky struct {
+	writtenAt atomic.Int64
+}
+
+// NewContext 给ctx添加写入记录，在StickyDuration内，该ctx（及其子ctx）的读操作会使用主库，避免主从延迟导致读不到刚写入的数据。
+// 一般在请求的中间件中调用，参见middleware/resolver
+func NewContext(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(stickyKey{}).(*sticky); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, stickyKey{}, &sticky{})
+}
+
+// markWritten 记录写入时间，ctx中没有NewContext添加的记录时忽略
+func markWritten(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if s, ok := ctx.Value(stickyKey{}).(*sticky); ok {
+		s.writtenAt.Store(time.Now().UnixNano())
+	}
+}
+
+// isSticky ctx是否在写入后的duration内
+func isSticky(ctx context.Context, duration time.Duration) bool {
+	if ctx == nil || duration <= 0 {
+		return false
+	}
+	if s, ok := ctx.Value(stickyKey{}).(*sticky); ok {
+		if writtenAt := s.writtenAt.Load(); writtenAt > 0 {
+			return time.Since(time.Unix(0, writtenAt)) < duration
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package sqlite
+
+import (
+	"fmt"
+	"github.com/glebarez/sqlite"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"sync/atomic"
+)
+
+type Dialector = sqlite.Dialector
+
+var (
+	Open = sqlite.Open
+)
+
+var memoryID atomic.Int64
+
+// OpenInMemory 打开一个SQLite内存数据库（纯Go实现，不需要cgo），每次调用都是一个独立的库，连接池中的连接共享同一个库。
+// 最后一个连接关闭后数据会被清空，常用于单元测试
+func OpenInMemory(config *db.Config) (*db.DB, error) {
+	dsn := fmt.Sprintf("file:memdb%d?mode=memory&cache=shared&_pragma=foreign_keys(1)", memoryID.Add(1))
+	return db.Open(sqlite.Open(dsn), config)
+}
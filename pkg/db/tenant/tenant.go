@@ -0,0 +1,117 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/auth"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultColumn 默认的租户字段名
+const DefaultColumn = "tenant_id"
+
+var ErrMissingTenant = errors.New("tenant is missing in context")
+
+// Resolver 从ctx中解析租户ID，第二个返回值表示是否解析成功
+type Resolver func(ctx context.Context) (any, bool)
+
+// ITenant 实现了此接口的auth.IGuard（或auth.IAuth），可以被AuthResolver解析出租户ID
+type ITenant interface {
+	GetTenantID() any
+}
+
+type tenantKey struct{}
+type withoutScopeKey struct{}
+
+// NewContext 将租户ID放入ctx，可以在中间件中调用
+func NewContext(ctx context.Context, tenantID any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// FromContext 获取NewContext设置的租户ID
+func FromContext(ctx context.Context) (any, bool) {
+	tenantID := ctx.Value(tenantKey{})
+	return tenantID, tenantID != nil
+}
+
+// WithoutScope 返回的ctx会跳过租户隔离（不添加租户条件、不填充租户字段），用于管理后台、定时任务等跨租户的场景
+func WithoutScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutScopeKey{}, true)
+}
+
+// IsScopeDisabled ctx是否已经通过WithoutScope跳过租户隔离
+func IsScopeDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(withoutScopeKey{}).(bool)
+	return disabled
+}
+
+// ContextResolver 从NewContext设置的ctx中解析租户ID
+func ContextResolver(ctx context.Context) (any, bool) {
+	return FromContext(ctx)
+}
+
+// AuthResolver 从auth.FromContext中解析租户ID，要求登录的guard model（或IAuth本身）实现了ITenant
+func AuthResolver(ctx context.Context) (any, bool) {
+	info, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	if t, ok := info.GetGuardModel().(ITenant); ok {
+		return t.GetTenantID(), true
+	} else if t, ok := info.(ITenant); ok {
+		return t.GetTenantID(), true
+	}
+	return nil, false
+}
+
+// ChainResolver 依次尝试resolvers，返回第一个解析成功的租户ID
+// example: tenant.ChainResolver(tenant.ContextResolver, tenant.AuthResolver)
+func ChainResolver(resolvers ...Resolver) Resolver {
+	return func(ctx context.Context) (any, bool) {
+		for _, resolver := range resolvers {
+			if tenantID, ok := resolver(ctx); ok {
+				return tenantID, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// Scope 用于gorm的Scopes，给查询、更新、删除添加租户条件。ctx中没有租户时返回ErrMissingTenant
+// example: db.Scopes(tenant.Scope(ctx, tenant.DefaultColumn, tenant.AuthResolver)).Find(&users)
+func Scope(ctx context.Context, column string, resolver Resolver) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if IsScopeDisabled(ctx) {
+			return tx
+		}
+		tenantID, ok := resolver(ctx)
+		if !ok {
+			_ = tx.AddError(ErrMissingTenant)
+			return tx
+		}
+		groupConditions(tx.Statement)
+		return tx.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: tenantID})
+	}
+}
+
+// groupConditions 已有的where条件中包含OR时，将其包裹为一个分组（与gorm软删除的处理相同），
+// 避免生成 a OR b AND tenant_id = ? 导致查询到其他租户的记录
+func groupConditions(stmt *gorm.Statement) {
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok {
+		return
+	}
+	where, ok := c.Expression.(clause.Where)
+	if !ok || len(where.Exprs) < 2 {
+		return
+	}
+	for _, expr := range where.Exprs {
+		if orCond, ok := expr.(clause.OrConditions); ok && len(orCond.Exprs) == 1 {
+			where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
+			c.Expression = where
+			stmt.Clauses["WHERE"] = c
+			return
+		}
+	}
+}
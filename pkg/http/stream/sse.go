@@ -14,7 +14,9 @@ import (
 )
 
 const (
-	SseContentType = "text/event-stream"
+	SseContentType   = "text/event-stream"
+	CsvContentType   = "text/csv; charset=utf-8"
+	JsonlContentType = "application/x-ndjson"
 )
 
 type Sse struct {
@@ -0,0 +1,16 @@
+package dblogger
+
+import (
+	"context"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/logger"
+)
+
+// Server 记录每个请求中执行的SQL，配合logger.WithNPlusOneDetection检测N+1查询
+func Server() middleware.Middleware {
+	return func(nextHandler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req any) (reply any, err error) {
+			return nextHandler(logger.NewContext(ctx), req)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package resolver
+
+import (
+	"context"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/resolver"
+)
+
+// Server 读写分离中间件，请求中写入数据库后的StickyDuration内，读操作使用主库
+func Server() middleware.Middleware {
+	return func(nextHandler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req any) (reply any, err error) {
+			return nextHandler(resolver.NewContext(ctx), req)
+		}
+	}
+}
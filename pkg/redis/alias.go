@@ -20,5 +20,8 @@ type (
 
 const Nil = redis.Nil
 
+// KeepTTL 设置值时保留原有的过期时间（Options.Expiration为KeepTTL时，不会设置过期时间）
+const KeepTTL = redis.KeepTTL
+
 var interfacesToStrings = utils.InterfacesToStrings
 var InstrumentTracing = redisotel.InstrumentTracing
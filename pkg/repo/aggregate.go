@@ -0,0 +1,197 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+)
+
+type TimeBucket string
+
+const (
+	BucketDay   TimeBucket = "day"
+	BucketWeek  TimeBucket = "week"
+	BucketMonth TimeBucket = "month"
+)
+
+// TimeSeriesPoint 时间序列的一个点，Bucket的格式：day为2006-01-02，week为该ISO周的周一（2006-01-02），month为2006-01。
+// 各数据库的格式相同，同样的数据在MySQL、PgSQL、SQLite中得到相同的Bucket
+type TimeSeriesPoint[V any] struct {
+	Bucket string `json:"bucket" gorm:"column:bucket"`
+	Value  V      `json:"value" gorm:"column:value"`
+}
+
+// groupRow 分组聚合的一行
+type groupRow[K comparable, V any] struct {
+	GroupKey   K `gorm:"column:group_key"`
+	GroupValue V `gorm:"column:group_value"`
+}
+
+// aggregateValue 对column执行聚合函数fn，并将单个结果扫描到scanner。
+// column只能是字段名（比如amount、orders.amount），避免拼接到SQL中导致注入；
+// 调用方query中的排序、分页和limit/offset会被忽略，否则聚合结果会被OFFSET跳过
+func (repo *Repository[T]) aggregateValue(ctx context.Context, method string, query *cnd.QueryBuilder, fn string, column string, scanner any) error {
+	if !cnd.IsValidColumnName(column) {
+		return errors.Wrapf(cnd.ErrInvalidColumn, "repo %s method of table \"%s\" failed: \"%s\"", method, repo.modelCreator().TableName(), column)
+	}
+
+	orm := repo.GetDB(ctx).Model(repo.modelCreator())
+	if err := query.Clone().Reorder().Build(orm).Select(fmt.Sprintf(fn, column)).Scan(scanner).Error; err != nil {
+		return errors.Wrapf(err, "repo %s method of table \"%s\" failed", method, repo.modelCreator().TableName())
+	}
+	return nil
+}
+
+// Sum 求和，没有记录时返回0
+func (repo *Repository[T]) Sum(ctx context.Context, query *cnd.QueryBuilder, column string) (float64, error) {
+	var res sql.NullFloat64
+	err := repo.aggregateValue(ctx, "Sum", query, "SUM(%s)", column, &res)
+	return res.Float64, err
+}
+
+// Avg 求平均值，没有记录时返回0
+func (repo *Repository[T]) Avg(ctx context.Context, query *cnd.QueryBuilder, column string) (float64, error) {
+	var res sql.NullFloat64
+	err := repo.aggregateValue(ctx, "Avg", query, "AVG(%s)", column, &res)
+	return res.Float64, err
+}
+
+// Min 求最小值，结果扫描到scanner中（比如*int64、*time.Time、*sql.NullTime），类型安全的版本请使用AggregateValue
+func (repo *Repository[T]) Min(ctx context.Context, query *cnd.QueryBuilder, column string, scanner any) error {
+	return repo.aggregateValue(ctx, "Min", query, "MIN(%s)", column, scanner)
+}
+
+// Max 求最大值，结果扫描到scanner中（比如*int64、*time.Time、*sql.NullTime），类型安全的版本请使用AggregateValue
+func (repo *Repository[T]) Max(ctx context.Context, query *cnd.QueryBuilder, column string, scanner any) error {
+	return repo.aggregateValue(ctx, "Max", query, "MAX(%s)", column, scanner)
+}
+
+// CountDistinct 统计column去重后的数量
+func (repo *Repository[T]) CountDistinct(ctx context.Context, query *cnd.QueryBuilder, column string) (int64, error) {
+	var res sql.NullInt64
+	err := repo.aggregateValue(ctx, "CountDistinct", query, "COUNT(DISTINCT %s)", column, &res)
+	return res.Int64, err
+}
+
+// Exists 是否存在符合条件的记录，使用SELECT 1 ... LIMIT 1，比Count更快。query中的排序和分页会被忽略
+func (repo *Repository[T]) Exists(ctx context.Context, query *cnd.QueryBuilder) (bool, error) {
+	var res []int
+	orm := repo.GetDB(ctx).Model(repo.modelCreator())
+	if err := query.Clone().Reorder().Build(orm).Select("1").Limit(1).Scan(&res).Error; err != nil {
+		return false, errors.Wrapf(err, "repo Exists method of table \"%s\" failed", repo.modelCreator().TableName())
+	}
+	return len(res) > 0, nil
+}
+
+// Aggregate 执行自定义的聚合查询，并将多行结果扫描到scanner（比如*[]struct）中。query需要自行设置Select、GroupBy等
+// example: repo.Aggregate(ctx, cnd.Select("status, COUNT(*) AS total").GroupBy("status"), &rows)
+func (repo *Repository[T]) Aggregate(ctx context.Context, query *cnd.QueryBuilder, scanner any) error {
+	orm := repo.GetDB(ctx).Model(repo.modelCreator())
+	if err := query.Build(orm).Scan(scanner).Error; err != nil {
+		return errors.Wrapf(err, "repo Aggregate method of table \"%s\" failed", repo.modelCreator().TableName())
+	}
+	return nil
+}
+
+// TimeSeries 按时间分桶聚合，结果按bucket升序扫描到scanner（*[]TimeSeriesPoint[V]）中
+// column为时间字段（只能是字段名），valueExpr为聚合表达式，比如："COUNT(*)"、"SUM(amount)"，不要传入用户输入。
+// 调用方query中的排序、分页和limit/offset会被忽略
+func (repo *Repository[T]) TimeSeries(ctx context.Context, query *cnd.QueryBuilder, column string, bucket TimeBucket, valueExpr string, scanner any) error {
+	if !cnd.IsValidColumnName(column) {
+		return errors.Wrapf(cnd.ErrInvalidColumn, "repo TimeSeries method of table \"%s\" failed: \"%s\"", repo.modelCreator().TableName(), column)
+	}
+
+	orm := repo.GetDB(ctx)
+	bucketExpr, err := timeBucketExpr(orm.Dialector.Name(), column, bucket)
+	if err != nil {
+		return errors.Wrapf(err, "repo TimeSeries method of table \"%s\" failed", repo.modelCreator().TableName())
+	}
+
+	q := query.Clone().Reorder().
+		Select(fmt.Sprintf("%s AS bucket, %s AS value", bucketExpr, valueExpr)).
+		GroupBy(bucketExpr).
+		Asc("bucket")
+	return repo.Aggregate(ctx, q, scanner)
+}
+
+// timeBucketExpr 不同数据库的时间分桶表达式，week统一计算为ISO周（周一开始）的第一天，
+// 避免各数据库周数的算法不同（比如SQLite的%W不是ISO周）
+func timeBucketExpr(dialect string, column string, bucket TimeBucket) (string, error) {
+	formats := map[string]map[TimeBucket]string{
+		"mysql": {
+			BucketDay:   "DATE_FORMAT(%s, '%%Y-%%m-%%d')",
+			BucketWeek:  "DATE_FORMAT(DATE_SUB(%[1]s, INTERVAL WEEKDAY(%[1]s) DAY), '%%Y-%%m-%%d')",
+			BucketMonth: "DATE_FORMAT(%s, '%%Y-%%m')",
+		},
+		"postgres": {
+			BucketDay:   "TO_CHAR(%s, 'YYYY-MM-DD')",
+			BucketWeek:  "TO_CHAR(DATE_TRUNC('week', %s), 'YYYY-MM-DD')",
+			BucketMonth: "TO_CHAR(%s, 'YYYY-MM')",
+		},
+		"sqlite": {
+			BucketDay:   "STRFTIME('%%Y-%%m-%%d', %s)",
+			BucketWeek:  "STRFTIME('%%Y-%%m-%%d', %s, 'weekday 0', '-6 days')",
+			BucketMonth: "STRFTIME('%%Y-%%m', %s)",
+		},
+	}
+
+	format, ok := formats[dialect][bucket]
+	if !ok {
+		return "", errors.Errorf("time bucket \"%s\" is not supported by dialect \"%s\"", bucket, dialect)
+	}
+	return fmt.Sprintf(format, column), nil
+}
+
+// AggregateValue 类型安全的单值聚合，expr为聚合表达式，不要传入用户输入。query中的排序和分页会被忽略
+// example: maxTime, err := repo.AggregateValue[time.Time](ctx, userRepo, cnd.Eq("status", 1), "MAX(created_at)")
+// 需要缓存时，传入userRepo.Remember("key")
+func AggregateValue[V any](ctx context.Context, aggregator IOrmAggregator, query *cnd.QueryBuilder, expr string) (V, error) {
+	var rows []struct {
+		Value V `gorm:"column:value"`
+	}
+	var value V
+	if err := aggregator.Aggregate(ctx, query.Clone().Reorder().Select(expr+" AS value"), &rows); err != nil || len(rows) == 0 {
+		return value, err
+	}
+	return rows[0].Value, nil
+}
+
+// GroupMap 按keyColumn（只能是字段名）分组聚合到map中，valueExpr为聚合表达式，不要传入用户输入。query中的排序和分页会被忽略
+// example: totals, err := repo.GroupMap[int, float64](ctx, orderRepo, cnd.Gt("amount", 0), "status", "SUM(amount)")
+func GroupMap[K comparable, V any](ctx context.Context, aggregator IOrmAggregator, query *cnd.QueryBuilder, keyColumn string, valueExpr string) (map[K]V, error) {
+	if !cnd.IsValidColumnName(keyColumn) {
+		return nil, errors.Wrapf(cnd.ErrInvalidColumn, "repo GroupMap failed: \"%s\"", keyColumn)
+	}
+
+	var rows []groupRow[K, V]
+	q := query.Clone().Reorder().
+		Select(fmt.Sprintf("%s AS group_key, %s AS group_value", keyColumn, valueExpr)).
+		GroupBy(keyColumn)
+	if err := aggregator.Aggregate(ctx, q, &rows); err != nil {
+		return nil, err
+	}
+
+	results := make(map[K]V, len(rows))
+	for _, row := range rows {
+		results[row.GroupKey] = row.GroupValue
+	}
+	return results, nil
+}
+
+// GroupRows 分组聚合到结构体列表中，query需要自行设置Select、GroupBy
+// example: rows, err := repo.GroupRows[StatusTotal](ctx, orderRepo, cnd.Select("status, SUM(amount) AS total").GroupBy("status"))
+func GroupRows[R any](ctx context.Context, aggregator IOrmAggregator, query *cnd.QueryBuilder) ([]R, error) {
+	var rows []R
+	err := aggregator.Aggregate(ctx, query, &rows)
+	return rows, err
+}
+
+// TimeSeries 类型安全的时间序列聚合
+// example: points, err := repo.TimeSeries[int64](ctx, orderRepo, cnd.Gte("created_at", start), "created_at", repo.BucketDay, "COUNT(*)")
+func TimeSeries[V any](ctx context.Context, aggregator IOrmAggregator, query *cnd.QueryBuilder, column string, bucket TimeBucket, valueExpr string) ([]TimeSeriesPoint[V], error) {
+	var points []TimeSeriesPoint[V]
+	err := aggregator.TimeSeries(ctx, query, column, bucket, valueExpr, &points)
+	return points, err
+}
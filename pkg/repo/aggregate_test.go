@@ -0,0 +1,99 @@
+package repo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo/repotest"
+)
+
+type sale struct {
+	ID        int64 `gorm:"primaryKey"`
+	Amount    float64
+	Status    int
+	CreatedAt time.Time
+}
+
+func (sale) TableName() string {
+	return "sales"
+}
+
+func newSaleRepo(t *testing.T) *repo.Repository[*sale] {
+	orm := repotest.NewDB(t, &sale{})
+	sales := repotest.NewRepository(t, orm, func() *sale { return &sale{} })
+	day := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	models := []*sale{
+		{Amount: 10, Status: 1, CreatedAt: day},
+		{Amount: 20, Status: 1, CreatedAt: day},
+		{Amount: 30, Status: 2, CreatedAt: day.AddDate(0, 0, 1)},
+		{Amount: 40, Status: 2, CreatedAt: day.AddDate(0, 0, 2)},
+	}
+	if err := sales.Create(context.Background(), models...); err != nil {
+		t.Fatal(err)
+	}
+	return sales
+}
+
+func TestAggregateIgnoresPaging(t *testing.T) {
+	sales := newSaleRepo(t)
+	ctx := context.Background()
+
+	// 调用方的排序、分页不能带入聚合查询，否则OFFSET会跳过聚合结果
+	query := cnd.NewQueryBuilder().Desc("amount").Paginate(2, 2)
+	if sum, err := sales.Sum(ctx, query, "amount"); err != nil {
+		t.Fatal(err)
+	} else if sum != 100 {
+		t.Fatalf("expected sum 100, got %v", sum)
+	}
+	if count, err := sales.CountDistinct(ctx, query, "status"); err != nil {
+		t.Fatal(err)
+	} else if count != 2 {
+		t.Fatalf("expected 2 distinct status, got %d", count)
+	}
+	if exists, err := sales.Exists(ctx, query); err != nil {
+		t.Fatal(err)
+	} else if !exists {
+		t.Fatal("expected exists")
+	}
+
+	totals, err := repo.GroupMap[int, float64](ctx, sales, query, "status", "SUM(amount)")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(totals) != 2 || totals[1] != 30 || totals[2] != 70 {
+		t.Fatalf("unexpected totals %v", totals)
+	}
+
+	points, err := repo.TimeSeries[int64](ctx, sales, query, "created_at", repo.BucketDay, "COUNT(*)")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(points) != 3 || points[0].Bucket != "2024-01-01" || points[0].Value != 2 {
+		t.Fatalf("unexpected points %+v", points)
+	}
+}
+
+func TestAggregateInvalidColumn(t *testing.T) {
+	sales := newSaleRepo(t)
+	ctx := context.Background()
+
+	column := "amount) FROM sales; DROP TABLE sales; --"
+	if _, err := sales.Sum(ctx, cnd.NewQueryBuilder(), column); !errors.Is(err, cnd.ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+	if _, err := repo.GroupMap[int, float64](ctx, sales, cnd.NewQueryBuilder(), column, "SUM(amount)"); !errors.Is(err, cnd.ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+	if _, err := repo.TimeSeries[int64](ctx, sales, cnd.NewQueryBuilder(), column, repo.BucketDay, "COUNT(*)"); !errors.Is(err, cnd.ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+
+	// 带表名前缀的字段名是合法的
+	if sum, err := sales.Sum(ctx, cnd.NewQueryBuilder(), "sales.amount"); err != nil {
+		t.Fatal(err)
+	} else if sum != 100 {
+		t.Fatalf("expected sum 100, got %v", sum)
+	}
+}
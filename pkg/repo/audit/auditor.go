@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/auth"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/requestid"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/job"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"time"
+)
+
+// Auditor 审计日志，记录谁（auth.FromContext）在什么请求（requestid.FromContext）中修改了哪条记录的哪些字段
+// example:
+//
+//	auditor := audit.NewAuditor(db, logger)
+//	audit.Watch[*User](auditor, userRepo, audit.WithExcludedFields("password"))
+type Auditor struct {
+	db     *db.DB
+	table  string
+	logger *log.Helper
+}
+
+func NewAuditor(_db *db.DB, logger log.Logger, options ...Option) *Auditor {
+	a := &Auditor{
+		db:     _db,
+		table:  DefaultTableName,
+		logger: log.NewModuleHelper(logger, "repo/audit"),
+	}
+	for _, option := range options {
+		option(a)
+	}
+	return a
+}
+
+// AutoMigrate 创建审计日志表
+func (a *Auditor) AutoMigrate() error {
+	return a.db.Table(a.table).AutoMigrate(&Log{})
+}
+
+// record 写入一条审计日志。tx不为空时使用tx（与修改在同一个事务中）
+func (a *Auditor) record(ctx context.Context, tx *db.DB, table string, recordID any, eventType event.EventType, changes Changes) error {
+	j, err := json.Marshal(changes)
+	if err != nil {
+		return errors.Wrapf(err, "marshal audit changes of table \"%s\" failed", table)
+	}
+
+	l := &Log{
+		Table:     table,
+		Event:     string(eventType),
+		RequestID: requestid.FromContext(ctx),
+		Changes:   j,
+	}
+	if recordID != nil {
+		l.RecordID = fmt.Sprint(recordID)
+	}
+	if info, ok := auth.FromContext(ctx); ok && info.GetGuardModel() != nil {
+		l.GuardName = info.GetGuardModel().GetGuardName()
+		l.ActorID = info.GetGuardModel().GetAuthorizationID()
+	}
+
+	if tx == nil {
+		tx = a.db
+	}
+	if err = tx.Session(&gorm.Session{NewDB: true, SkipHooks: true, Context: ctx}).Table(a.table).Create(l).Error; err != nil {
+		return errors.Wrapf(err, "write audit log of table \"%s\" failed", table)
+	}
+	return nil
+}
+
+// History 查询一条记录的审计日志，按时间倒序。pagination为nil时返回全部
+func (a *Auditor) History(ctx context.Context, table string, recordID any, pagination *db.Pagination) ([]*Log, error) {
+	var logs []*Log
+	orm := a.db.WithContext(ctx).Table(a.table).
+		Where("table_name = ? AND record_id = ?", table, fmt.Sprint(recordID))
+
+	if pagination != nil {
+		if err := orm.Count(&pagination.Total).Error; err != nil {
+			return nil, errors.Wrapf(err, "count audit logs of [%s:%v] failed", table, recordID)
+		}
+		orm = orm.Limit(pagination.Limit).Offset(pagination.GetOffset())
+	}
+
+	if err := orm.Order("id DESC").Find(&logs).Error; err != nil {
+		return nil, errors.Wrapf(err, "query audit logs of [%s:%v] failed", table, recordID)
+	}
+	return logs, nil
+}
+
+// HistoryOf 查询model（需要有主键）的审计日志，按时间倒序
+func (a *Auditor) HistoryOf(ctx context.Context, model db.Tabler, pagination *db.Pagination) ([]*Log, error) {
+	stmt := &gorm.Statement{DB: a.db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	} else if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, errors.Errorf("primary key of table \"%s\" is required", model.TableName())
+	}
+
+	recordID, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(ctx, reflect.ValueOf(model))
+	return a.History(ctx, model.TableName(), recordID, pagination)
+}
+
+// Purge 删除retention之前的审计日志，返回删除的数量
+func (a *Auditor) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	result := a.db.WithContext(ctx).Table(a.table).
+		Where("created_at < ?", time.Now().Add(-retention)).
+		Delete(&Log{})
+	if result.Error != nil {
+		return 0, errors.Wrapf(result.Error, "purge audit logs before %s failed", retention)
+	}
+	return result.RowsAffected, nil
+}
+
+// PurgeJob 返回定时清理审计日志的job
+// example: worker.OnceForCluster("audit:purge").CronWith(auditor.PurgeJob(90 * 24 * time.Hour)).DailyAt("03:00")
+func (a *Auditor) PurgeJob(retention time.Duration) job.Job {
+	return func(ctx context.Context) {
+		if n, err := a.Purge(ctx, retention); err != nil {
+			a.logger.WithContext(ctx).Errorf("purge audit logs failed: %v", err)
+		} else {
+			a.logger.WithContext(ctx).Infof("purged %d audit logs before %s", n, retention)
+		}
+	}
+}
+
+// snapshot 获取model所有字段的值
+func snapshot(ctx context.Context, sch *schema.Schema, model any, excluded map[string]struct{}) map[string]any {
+	value := reflect.ValueOf(model)
+	values := make(map[string]any, len(sch.DBNames))
+	for _, name := range sch.DBNames {
+		if _, ok := excluded[name]; ok {
+			continue
+		}
+		values[name], _ = sch.FieldsByDBName[name].ValueOf(ctx, value)
+	}
+	return values
+}
@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"time"
+)
+
+// DefaultTableName 审计日志的默认表名
+const DefaultTableName = "audit_logs"
+
+// Change 单个字段的修改，创建时Before为空，删除时After为空
+type Change struct {
+	Before any `json:"before,omitempty"`
+	After  any `json:"after,omitempty"`
+}
+
+// Changes 字段名（数据库字段名）=> 修改
+type Changes map[string]Change
+
+// Log 审计日志
+type Log struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	Table     string    `gorm:"column:table_name;size:64;not null;index:idx_audit_logs_record,priority:1" json:"table_name"`
+	RecordID  string    `gorm:"size:64;index:idx_audit_logs_record,priority:2" json:"record_id"`
+	Event     string    `gorm:"size:32;not null" json:"event"`
+	GuardName string    `gorm:"size:64" json:"guard_name"`
+	ActorID   int64     `json:"actor_id"`
+	RequestID string    `gorm:"size:255" json:"request_id"`
+	Changes   db.JSON   `json:"changes"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (l *Log) TableName() string {
+	return DefaultTableName
+}
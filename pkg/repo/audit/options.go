@@ -0,0 +1,32 @@
+package audit
+
+type Option func(*Auditor)
+
+// WithTableName 自定义审计日志的表名，默认为audit_logs
+func WithTableName(table string) Option {
+	return func(a *Auditor) {
+		a.table = table
+	}
+}
+
+type watchOptions struct {
+	excludedFields map[string]struct{}
+}
+
+type WatchOption func(*watchOptions)
+
+// WithExcludedFields 不记录的字段（数据库字段名），比如：password。默认不记录created_at、updated_at
+func WithExcludedFields(fields ...string) WatchOption {
+	return func(o *watchOptions) {
+		for _, field := range fields {
+			o.excludedFields[field] = struct{}{}
+		}
+	}
+}
+
+// WithAllFields 记录所有字段，包括created_at、updated_at
+func WithAllFields() WatchOption {
+	return func(o *watchOptions) {
+		o.excludedFields = map[string]struct{}{}
+	}
+}
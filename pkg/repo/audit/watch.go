@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+type watcher[T db.Tabler] struct {
+	auditor *Auditor
+	options *watchOptions
+}
+
+// Watch 记录repository的Created、Updated、Deleted、Restored、BatchUpdated事件到审计日志。
+// Updating、Deleting、Restoring时会从数据库加载原始记录，Updated、Restored后重新加载，比较出字段级别的修改
+func Watch[T db.Tabler](auditor *Auditor, events repo.IModelEvent[T], options ...WatchOption) {
+	w := &watcher[T]{
+		auditor: auditor,
+		options: &watchOptions{
+			excludedFields: map[string]struct{}{"created_at": {}, "updated_at": {}},
+		},
+	}
+	for _, option := range options {
+		option(w.options)
+	}
+
+	events.RegisterEventListeners([]event.EventType{event.Updating, event.Deleting, event.Restoring}, w.loadOriginal)
+	events.RegisterEventListeners([]event.EventType{event.Created, event.Updated, event.Deleted, event.Restored, event.BatchUpdated}, w.onEvent)
+}
+
+// parse 解析model的schema，返回主键的值，没有主键或主键为零值时返回nil
+func (w *watcher[T]) parse(ctx context.Context, tx *gorm.DB, model T) (*schema.Schema, any, error) {
+	if tx == nil {
+		tx = w.auditor.db
+	}
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return nil, nil, err
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return stmt.Schema, nil, nil
+	}
+	id, zero := stmt.Schema.PrioritizedPrimaryField.ValueOf(ctx, reflect.ValueOf(model))
+	if zero {
+		return stmt.Schema, nil, nil
+	}
+	return stmt.Schema, id, nil
+}
+
+// load 从数据库加载记录（包括软删除的记录），不触发事件
+func (w *watcher[T]) load(ctx context.Context, tx *gorm.DB, sch *schema.Schema, model T, id any) (T, bool, error) {
+	fresh := reflect.New(reflect.TypeOf(model).Elem()).Interface().(T)
+	result := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true, Context: ctx}).Unscoped().
+		Where(map[string]any{sch.PrioritizedPrimaryField.DBName: id}).
+		Limit(1).Find(fresh)
+	return fresh, result.RowsAffected > 0, result.Error
+}
+
+// loadOriginal 修改、删除之前加载原始记录
+func (w *watcher[T]) loadOriginal(ctx context.Context, e event.ModelEvent[T]) error {
+	if reflect.ValueOf(e.Model).IsNil() || e.Tx == nil {
+		return nil
+	}
+	sch, id, err := w.parse(ctx, e.Tx, e.Model)
+	if err != nil || id == nil {
+		return err
+	}
+
+	original, found, err := w.load(ctx, e.Tx, sch, e.Model, id)
+	if err != nil {
+		return err
+	} else if found {
+		// 保存在当前语句（gorm.Statement）中，语句执行完毕（包括失败）后随语句一起释放
+		e.Tx.Statement.Settings.Store(originalKey(e.Model), original)
+	}
+	return nil
+}
+
+// originalKey 原始记录在语句中的key，同一个语句可能删除多个model（Delete(models)），所以需要包含model的指针
+func originalKey(model any) string {
+	return fmt.Sprintf("audit:original:%p", model)
+}
+
+// getOriginal 获取loadOriginal保存在当前语句中的原始记录
+func (w *watcher[T]) getOriginal(e event.ModelEvent[T]) (any, bool) {
+	if e.Tx == nil || e.Tx.Statement == nil {
+		return nil, false
+	}
+	return e.Tx.Statement.Settings.Load(originalKey(e.Model))
+}
+
+func (w *watcher[T]) onEvent(ctx context.Context, e event.ModelEvent[T]) error {
+	if e.EventType == event.BatchUpdated {
+		return w.onBatchUpdated(ctx, e)
+	} else if reflect.ValueOf(e.Model).IsNil() {
+		return nil
+	}
+
+	sch, id, err := w.parse(ctx, e.Tx, e.Model)
+	if err != nil || id == nil {
+		return err
+	}
+
+	changes := Changes{}
+	switch e.EventType {
+	case event.Created:
+		for name, value := range snapshot(ctx, sch, e.Model, w.options.excludedFields) {
+			changes[name] = Change{After: value}
+		}
+	case event.Deleted:
+		var model any = e.Model
+		if original, ok := w.getOriginal(e); ok {
+			model = original
+		}
+		for name, value := range snapshot(ctx, sch, model, w.options.excludedFields) {
+			changes[name] = Change{Before: value}
+		}
+	case event.Updated, event.Restored:
+		original, ok := w.getOriginal(e)
+		if !ok {
+			return nil
+		}
+		current, found, err := w.load(ctx, e.Tx, sch, e.Model, id)
+		if err != nil || !found {
+			return err
+		}
+		before := snapshot(ctx, sch, original, w.options.excludedFields)
+		after := snapshot(ctx, sch, current, w.options.excludedFields)
+		for name, value := range after {
+			if !reflect.DeepEqual(before[name], value) {
+				changes[name] = Change{Before: before[name], After: value}
+			}
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+	}
+
+	return w.auditor.record(ctx, e.Tx, sch.Table, id, e.EventType, changes)
+}
+
+// onBatchUpdated 批量更新没有model，只记录更新的字段值（UpdateMany每个model的值不同，只记录字段名）
+func (w *watcher[T]) onBatchUpdated(ctx context.Context, e event.ModelEvent[T]) error {
+	payload, ok := event.PayloadOf[*repo.BatchUpdatedPayload](e)
+	if !ok {
+		return nil
+	}
+
+	changes := Changes{}
+	for _, name := range payload.Columns {
+		if _, excluded := w.options.excludedFields[name]; excluded {
+			continue
+		}
+		value := payload.Attributes[name]
+		if _, isExpr := value.(clause.Expr); isExpr {
+			value = nil
+		}
+		changes[name] = Change{After: value}
+	}
+
+	return w.auditor.record(ctx, e.Tx, e.Model.TableName(), nil, e.EventType, changes)
+}
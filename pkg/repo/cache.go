@@ -2,6 +2,8 @@ package repo
 
 import (
 	"context"
+	"crypto/sha1"
+	"fmt"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/cache"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
@@ -121,3 +123,105 @@ func (c *rememberCacheGetter[T]) Paginate(ctx context.Context, query *cnd.QueryB
 		return c.repository.Paginate(ctx, query, pagination)
 	})
 }
+
+// cursorPage 用于缓存游标分页的结果，cnd.CursorResult.Results为any，直接缓存无法还原为[]T
+type cursorPage[T db.Tabler] struct {
+	Results []T    `json:"results"`
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+	Total   *int64 `json:"total,omitempty"`
+}
+
+// CursorPaginate 根据查询条件和游标获取记录的分页列表。
+// 会先尝试获取缓存，如果没有获取到，则数据库查询，并设置缓存。
+// 缓存key会自动加上cursor和limit，每一页分别缓存。
+func (c *rememberCacheGetter[T]) CursorPaginate(ctx context.Context, query *cnd.QueryBuilder, cursor string, limit int, options ...CursorOption) (*cnd.CursorResult, error) {
+	key := fmt.Sprintf("%s:cursor:%d:%x", c.cacheKey, limit, sha1.Sum([]byte(cursor)))
+	page, err := cache.AsModernCache[*cursorPage[T]](c.cache).Remember(ctx, key, func(ctx context.Context) (*cursorPage[T], error) {
+		result, err := c.repository.CursorPaginate(ctx, query, cursor, limit, options...)
+		if err != nil {
+			return nil, err
+		}
+		return &cursorPage[T]{
+			Results: result.Results.([]T),
+			Cursor:  result.Cursor,
+			HasMore: result.HasMore,
+			Total:   result.Total,
+		}, nil
+	})
+	if err != nil || page == nil {
+		return nil, err
+	}
+
+	return &cnd.CursorResult{
+		Results: page.Results,
+		Cursor:  page.Cursor,
+		HasMore: page.HasMore,
+		Total:   page.Total,
+	}, nil
+}
+
+// Sum 求和。
+// 会先尝试获取缓存，如果没有获取到，则数据库查询，并设置缓存。
+func (c *rememberCacheGetter[T]) Sum(ctx context.Context, query *cnd.QueryBuilder, column string) (float64, error) {
+	return cache.AsModernCache[float64](c.cache).Remember(ctx, c.cacheKey, func(ctx context.Context) (float64, error) {
+		return c.repository.Sum(ctx, query, column)
+	})
+}
+
+// Avg 求平均值。
+// 会先尝试获取缓存，如果没有获取到，则数据库查询，并设置缓存。
+func (c *rememberCacheGetter[T]) Avg(ctx context.Context, query *cnd.QueryBuilder, column string) (float64, error) {
+	return cache.AsModernCache[float64](c.cache).Remember(ctx, c.cacheKey, func(ctx context.Context) (float64, error) {
+		return c.repository.Avg(ctx, query, column)
+	})
+}
+
+// Min 求最小值，结果扫描到scanner中。
+// 会先尝试获取缓存，如果没有获取到，则数据库查询，并设置缓存。
+func (c *rememberCacheGetter[T]) Min(ctx context.Context, query *cnd.QueryBuilder, column string, scanner any) error {
+	return c.cache.Remember(ctx, c.cacheKey, scanner, func(ctx context.Context, actual any) error {
+		return c.repository.Min(ctx, query, column, actual)
+	})
+}
+
+// Max 求最大值，结果扫描到scanner中。
+// 会先尝试获取缓存，如果没有获取到，则数据库查询，并设置缓存。
+func (c *rememberCacheGetter[T]) Max(ctx context.Context, query *cnd.QueryBuilder, column string, scanner any) error {
+	return c.cache.Remember(ctx, c.cacheKey, scanner, func(ctx context.Context, actual any) error {
+		return c.repository.Max(ctx, query, column, actual)
+	})
+}
+
+// CountDistinct 统计column去重后的数量。
+// 会先尝试获取缓存，如果没有获取到，则数据库查询，并设置缓存。
+func (c *rememberCacheGetter[T]) CountDistinct(ctx context.Context, query *cnd.QueryBuilder, column string) (int64, error) {
+	return cache.AsModernCache[int64](c.cache).Remember(ctx, c.cacheKey, func(ctx context.Context) (int64, error) {
+		return c.repository.CountDistinct(ctx, query, column)
+	})
+}
+
+// Exists 是否存在符合条件的记录。
+// 会先尝试获取缓存，如果没有获取到，则数据库查询，并设置缓存。
+// 注意：不存在（false）时不会设置缓存。
+func (c *rememberCacheGetter[T]) Exists(ctx context.Context, query *cnd.QueryBuilder) (bool, error) {
+	return cache.AsModernCache[bool](c.cache).Remember(ctx, c.cacheKey, func(ctx context.Context) (bool, error) {
+		return c.repository.Exists(ctx, query)
+	})
+}
+
+// Aggregate 执行自定义的聚合查询，并将多行结果扫描到scanner中。
+// 会先尝试获取缓存，如果没有获取到，则数据库查询，并设置缓存。
+func (c *rememberCacheGetter[T]) Aggregate(ctx context.Context, query *cnd.QueryBuilder, scanner any) error {
+	return c.cache.Remember(ctx, c.cacheKey, scanner, func(ctx context.Context, actual any) error {
+		return c.repository.Aggregate(ctx, query, actual)
+	})
+}
+
+// TimeSeries 按时间分桶聚合，结果扫描到scanner中。
+// 会先尝试获取缓存，如果没有获取到，则数据库查询，并设置缓存。
+func (c *rememberCacheGetter[T]) TimeSeries(ctx context.Context, query *cnd.QueryBuilder, column string, bucket TimeBucket, valueExpr string, scanner any) error {
+	return c.cache.Remember(ctx, c.cacheKey, scanner, func(ctx context.Context, actual any) error {
+		return c.repository.TimeSeries(ctx, query, column, bucket, valueExpr, actual)
+	})
+}
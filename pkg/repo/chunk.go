@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"io"
+	"reflect"
+)
+
+// ErrStopIteration 在Chunk、Each的回调中返回此错误，可以提前结束遍历，并且Chunk、Each会返回nil
+var ErrStopIteration = errors.New("stop iteration")
+
+// Chunk 按主键升序分块遍历，每次查询size条记录（WHERE id > last_id LIMIT size，不使用OFFSET），适用于大表。
+// query中的排序、分页、limit/offset会被忽略。每个分块之间会检查ctx是否已取消
+// example: repo.Chunk(ctx, cnd.Eq("status", 1), 500, func(ctx context.Context, users []*User) error { ... })
+func (repo *Repository[T]) Chunk(ctx context.Context, query *cnd.QueryBuilder, size int, fn func(ctx context.Context, models []T) error) error {
+	if size <= 0 {
+		size = 1000
+	}
+
+	tableName := repo.modelCreator().TableName()
+	sch, err := repo.getSchema()
+	if err != nil {
+		return errors.Wrapf(err, "repo Chunk method of table \"%s\" parse schema failed", tableName)
+	} else if sch.PrioritizedPrimaryField == nil {
+		return errors.Errorf("repo Chunk method of table \"%s\" failed: primary key is required", tableName)
+	}
+	primary := sch.PrioritizedPrimaryField
+	column := sch.Table + "." + primary.DBName
+
+	var lastID any
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		q := query.Clone().Reorder().Asc(column).Limit(size)
+		if lastID != nil {
+			// 先将调用方的条件分组，避免Or条件绕过主键范围导致死循环
+			q.GroupConditions().Gt(column, lastID)
+		}
+
+		var models []T
+		orm := repo.GetDB(ctx).Model(repo.modelCreator())
+		if err = q.Build(orm).Find(&models).Error; err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+			return errors.Wrapf(err, "repo Chunk method of table \"%s\" get data failed", tableName)
+		} else if len(models) == 0 {
+			return nil
+		}
+
+		if err = fn(ctx, models); err != nil {
+			if errors.Is(err, ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+
+		if len(models) < size {
+			return nil
+		}
+		lastID, _ = primary.ValueOf(ctx, reflect.ValueOf(models[len(models)-1]))
+	}
+}
+
+// Each 按主键升序逐条遍历，内部使用Chunk每次查询size条记录
+func (repo *Repository[T]) Each(ctx context.Context, query *cnd.QueryBuilder, size int, fn func(ctx context.Context, model T) error) error {
+	return repo.Chunk(ctx, query, size, func(ctx context.Context, models []T) error {
+		for _, model := range models {
+			if err := fn(ctx, model); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Iterate 按主键升序遍历，返回记录channel和错误channel。遍历结束（或ctx取消）后两个channel都会被关闭，
+// 错误channel最多只有一个错误。调用方提前退出时必须取消ctx，否则读取数据库的goroutine会一直阻塞
+// example:
+//
+//	models, errs := repo.Iterate(ctx, query, 500)
+//	for model := range models { ... }
+//	if err := <-errs; err != nil { ... }
+func (repo *Repository[T]) Iterate(ctx context.Context, query *cnd.QueryBuilder, size int) (<-chan T, <-chan error) {
+	models := make(chan T, max(size, 1))
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(models)
+
+		err := repo.Each(ctx, query, size, func(ctx context.Context, model T) error {
+			select {
+			case models <- model:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return models, errs
+}
+
+// ExportCSV 按主键分块查询，将记录以CSV格式流式写入w（比如http/stream.StreamWriter），不会将全部记录加载到内存中。
+// header为表头（为空则不写表头），row将记录转换为一行
+// example:
+//
+//	stream.Streaming(httpCtx, stream.CsvContentType, func(s *stream.StreamWriter) error {
+//		return userRepo.ExportCSV(ctx, query, 1000, s, []string{"id", "name"}, func(u *User) []string {
+//			return []string{strconv.FormatUint(u.ID, 10), u.Name}
+//		})
+//	})
+func (repo *Repository[T]) ExportCSV(ctx context.Context, query *cnd.QueryBuilder, size int, w io.Writer, header []string, row func(model T) []string) error {
+	writer := csv.NewWriter(w)
+	if len(header) > 0 {
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	err := repo.Chunk(ctx, query, size, func(ctx context.Context, models []T) error {
+		for _, model := range models {
+			if err := writer.Write(row(model)); err != nil {
+				return err
+			}
+		}
+		// 每个分块刷新一次，数据会及时发送给客户端
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportJSONL 按主键分块查询，将记录以JSON Lines格式（每行一个JSON）流式写入w（比如http/stream.StreamWriter）
+// example:
+//
+//	stream.Streaming(httpCtx, stream.JsonlContentType, func(s *stream.StreamWriter) error {
+//		return userRepo.ExportJSONL(ctx, query, 1000, s)
+//	})
+func (repo *Repository[T]) ExportJSONL(ctx context.Context, query *cnd.QueryBuilder, size int, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	return repo.Each(ctx, query, size, func(ctx context.Context, model T) error {
+		return encoder.Encode(model)
+	})
+}
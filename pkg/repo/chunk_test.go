@@ -0,0 +1,61 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo"
+)
+
+func TestChunk(t *testing.T) {
+	articles := newArticleRepo(t, 1, 2, 3, 4, 5, 6, 7)
+
+	var chunks [][]int64
+	err := articles.Chunk(context.Background(), cnd.NewQueryBuilder(), 3, func(ctx context.Context, models []*article) error {
+		ids := make([]int64, 0, len(models))
+		for _, model := range models {
+			ids = append(ids, model.ID)
+		}
+		chunks = append(chunks, ids)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(chunks) != 3 || len(chunks[2]) != 1 || chunks[2][0] != 7 {
+		t.Fatalf("expected 3 chunks ending with article 7, got %v", chunks)
+	}
+}
+
+func TestChunkWithOr(t *testing.T) {
+	articles := newArticleRepo(t, 1, 2, 3, 4, 5, 6)
+
+	// 不分组时会生成 status = 1 OR score = 2 AND id > ?，每次都会查询到相同的记录，导致死循环
+	var count int
+	err := articles.Chunk(context.Background(), cnd.Eq("status", 1).Or("score = ?", 2), 2, func(ctx context.Context, models []*article) error {
+		if count += len(models); count > 4 {
+			t.Fatalf("chunk does not stop, got %d models", count)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 4 {
+		t.Fatalf("expected 4 models, got %d", count)
+	}
+}
+
+func TestChunkStopIteration(t *testing.T) {
+	articles := newArticleRepo(t, 1, 2, 3, 4, 5)
+
+	var calls int
+	err := articles.Chunk(context.Background(), cnd.NewQueryBuilder(), 2, func(ctx context.Context, models []*article) error {
+		calls++
+		return repo.ErrStopIteration
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
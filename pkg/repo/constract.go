@@ -7,6 +7,7 @@ import (
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/clause"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"io"
 )
 
 type Columns = map[string]any
@@ -27,9 +28,17 @@ type IOrm[T db.Tabler] interface {
 
 	Clauses(cnds ...clause.Expression) IOrm[T]
 
+	// WithBatchSize 设置Create、Upsert、UpdateMany每批处理的数量（默认100）
+	WithBatchSize(size int) IOrm[T]
+
+	// WithoutTenantScope 跳过租户隔离，用于管理后台、定时任务等跨租户的场景
+	WithoutTenantScope() IOrm[T]
+
 	IOrmSetter[T]
 	IOrmGetter[T]
 	IOrmScanner
+	IOrmAggregator
+	IOrmIterator[T]
 }
 
 type IOrmScanner interface {
@@ -37,6 +46,39 @@ type IOrmScanner interface {
 	Pluck(ctx context.Context, queries *cnd.QueryBuilder, field string, scanner any) error
 }
 
+type IOrmIterator[T db.Tabler] interface {
+	// Chunk 按主键升序分块遍历，回调返回ErrStopIteration可提前结束
+	Chunk(ctx context.Context, query *cnd.QueryBuilder, size int, fn func(ctx context.Context, models []T) error) error
+	// Each 按主键升序逐条遍历，回调返回ErrStopIteration可提前结束
+	Each(ctx context.Context, query *cnd.QueryBuilder, size int, fn func(ctx context.Context, model T) error) error
+	// Iterate 按主键升序遍历，返回记录channel和错误channel，ctx取消时结束
+	Iterate(ctx context.Context, query *cnd.QueryBuilder, size int) (<-chan T, <-chan error)
+	// ExportCSV 以CSV格式流式导出到w
+	ExportCSV(ctx context.Context, query *cnd.QueryBuilder, size int, w io.Writer, header []string, row func(model T) []string) error
+	// ExportJSONL 以JSON Lines格式流式导出到w
+	ExportJSONL(ctx context.Context, query *cnd.QueryBuilder, size int, w io.Writer) error
+}
+
+type IOrmAggregator interface {
+	// Sum 求和，没有记录时返回0
+	Sum(ctx context.Context, query *cnd.QueryBuilder, column string) (float64, error)
+	// Avg 求平均值，没有记录时返回0
+	Avg(ctx context.Context, query *cnd.QueryBuilder, column string) (float64, error)
+	// Min 求最小值，结果扫描到scanner中
+	Min(ctx context.Context, query *cnd.QueryBuilder, column string, scanner any) error
+	// Max 求最大值，结果扫描到scanner中
+	Max(ctx context.Context, query *cnd.QueryBuilder, column string, scanner any) error
+	// CountDistinct 统计column去重后的数量
+	CountDistinct(ctx context.Context, query *cnd.QueryBuilder, column string) (int64, error)
+	// Exists 是否存在符合条件的记录
+	Exists(ctx context.Context, query *cnd.QueryBuilder) (bool, error)
+	// Aggregate 执行自定义的聚合查询（需要自行设置Select、GroupBy），并将多行结果扫描到scanner中
+	// 类型安全的版本：AggregateValue、GroupMap、GroupRows
+	Aggregate(ctx context.Context, query *cnd.QueryBuilder, scanner any) error
+	// TimeSeries 按时间(day/week/month)分桶聚合，结果扫描到scanner（*[]TimeSeriesPoint[V]）中
+	TimeSeries(ctx context.Context, query *cnd.QueryBuilder, column string, bucket TimeBucket, valueExpr string, scanner any) error
+}
+
 type IOrmSetter[T db.Tabler] interface {
 	// Create 批量创建资源
 	// example: repo.Create(ctx, &User{Name: "tom"}, &User{Name: "jerry"})
@@ -65,6 +107,26 @@ type IOrmSetter[T db.Tabler] interface {
 	// DeletePrimary 通过主键删除资源
 	// example: repo.DeletePrimary(ctx, 1, 2, 3)
 	DeletePrimary(ctx context.Context, primary ...any) error
+	// ForceDelete 永久删除资源（忽略软删除）。如果没有主键，为了避免批量删除，会返回ErrMissingWhereClause
+	ForceDelete(ctx context.Context, models ...T) error
+	// ForceDeleteWithBuilder 使用query永久删除资源（包括已软删除的记录）
+	ForceDeleteWithBuilder(ctx context.Context, query *cnd.QueryBuilder) error
+	// Restore 恢复符合query的软删除记录，每条记录触发Restoring、Restored事件。查询软删除的记录使用cnd.OnlyTrashed()
+	// example: repo.Restore(ctx, cnd.Eq("id", 1))
+	Restore(ctx context.Context, query *cnd.QueryBuilder) error
+
+	// Upsert 批量插入，冲突时更新updateColumns（为空则更新所有非主键字段）。
+	// MySQL使用ON DUPLICATE KEY UPDATE（会忽略conflictColumns），PgSQL/SQLite使用ON CONFLICT(conflictColumns)
+	// example: repo.Upsert(ctx, users, []string{"email"}, []string{"name", "updated_at"})
+	Upsert(ctx context.Context, models []T, conflictColumns []string, updateColumns []string) error
+	// FirstOrCreate 查询符合attributes的第一条记录，不存在则使用attributes+values创建
+	// example: repo.FirstOrCreate(ctx, repo.Columns{"email": "tom@a.com"}, repo.Columns{"name": "tom"})
+	FirstOrCreate(ctx context.Context, attributes Columns, values Columns) (T, error)
+	// UpdateOrCreate 查询符合attributes的第一条记录，存在则使用values更新，不存在则使用attributes+values创建
+	UpdateOrCreate(ctx context.Context, attributes Columns, values Columns) (T, error)
+	// UpdateMany 使用一条UPDATE ... CASE WHEN语句（按批）将每个model各自的columns值更新到数据库，model必须有主键
+	// example: repo.UpdateMany(ctx, []*User{{ID: 1, Score: 10}, {ID: 2, Score: 20}}, "score")
+	UpdateMany(ctx context.Context, models []T, columns ...string) error
 
 	// Incr 递增某字段
 	Incr(ctx context.Context, query *cnd.QueryBuilder, field string, val any) error
@@ -99,20 +161,26 @@ type IOrmGetter[T db.Tabler] interface {
 
 	// Paginate 资源分页
 	Paginate(ctx context.Context, query *cnd.QueryBuilder, pagination *db.Pagination) ([]T, error)
+
+	// CursorPaginate 游标分页，不使用OFFSET，默认不统计总数。cursor为上一页返回的CursorResult.Cursor，第一页传""
+	CursorPaginate(ctx context.Context, query *cnd.QueryBuilder, cursor string, limit int, options ...CursorOption) (*cnd.CursorResult, error)
 }
 
 type IRemember[T db.Tabler] interface {
 	IOrmGetter[T]
+	IOrmAggregator
 	Do(ctx context.Context, callback func(context.Context, *Repository[T]) (any, error)) (any, error)
 }
 
 type IModelEvent[T db.Tabler] interface {
-	// RegisterEventListener 注册单个Model的事件
-	RegisterEventListener(eventType event.EventType, callback event.EventListenerFunc[T])
+	// RegisterEventListener 注册单个Model的事件，options可设置优先级（event.WithPriority）、异步执行（event.Async）
+	RegisterEventListener(eventType event.EventType, callback event.EventListenerFunc[T], options ...event.ListenerOption)
 	// RegisterEventListeners 注册多个Model的事件
-	RegisterEventListeners(eventTypes []event.EventType, callback event.EventListenerFunc[T])
+	RegisterEventListeners(eventTypes []event.EventType, callback event.EventListenerFunc[T], options ...event.ListenerOption)
 	// FireEvent 手动触发事件
 	FireEvent(ctx context.Context, model T, args ...any) error
+	// Close 取消订阅模型事件，注册了监听器、但生命周期短于进程的Repository，不再使用时需要调用
+	Close()
 }
 
 type IRepositoryCache[T db.Tabler] interface {
@@ -3,32 +3,101 @@ package repo
 import (
 	"context"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
 	"gorm.io/gorm/schema"
 )
 
+// BatchUpdatedPayload BatchUpdated事件的参数，使用event.PayloadOf[*repo.BatchUpdatedPayload](e)获取
+type BatchUpdatedPayload struct {
+	// Query 更新的条件
+	Query *cnd.QueryBuilder
+	// Columns 更新的字段名
+	Columns []string
+	// Attributes UpdateColumns更新的字段值，UpdateMany时为nil（每个model的值不同）
+	Attributes Columns
+}
+
+// BatchDeletedPayload BatchDeleted事件的参数，使用event.PayloadOf[*repo.BatchDeletedPayload](e)获取
+type BatchDeletedPayload struct {
+	// Query 删除的条件，DeletePrimary时为nil
+	Query *cnd.QueryBuilder
+	// Primaries DeletePrimary删除的主键
+	Primaries []any
+	// RowsAffected 删除的记录数
+	RowsAffected int64
+}
+
+// eventPayload 包装类型化参数，用于在同一个表的多个订阅者之间传递。
+// arguments为旧版本的参数（ModelEvent.Arguments），兼容之前按Arguments读取的监听器
+type eventPayload struct {
+	value     any
+	arguments []any
+}
+
 // RegisterEventListener 注册单个Model的事件
-func (repo *Repository[T]) RegisterEventListener(eventType event.EventType, callback event.EventListenerFunc[T]) {
-	repo.RegisterEventListeners([]event.EventType{eventType}, callback)
+// example: repo.RegisterEventListener(event.Created, callback, event.WithPriority(10), event.Async())
+func (repo *Repository[T]) RegisterEventListener(eventType event.EventType, callback event.EventListenerFunc[T], options ...event.ListenerOption) {
+	repo.RegisterEventListeners([]event.EventType{eventType}, callback, options...)
 }
 
 // RegisterEventListeners 注册多个Model的事件
-func (repo *Repository[T]) RegisterEventListeners(eventTypes []event.EventType, callback event.EventListenerFunc[T]) {
+func (repo *Repository[T]) RegisterEventListeners(eventTypes []event.EventType, callback event.EventListenerFunc[T], options ...event.ListenerOption) {
 	for _, eventType := range eventTypes {
-		repo.events.RegisterEventListener(eventType, callback)
+		repo.events.RegisterEventListener(eventType, callback, options...)
+	}
+	repo.bindModelEvents()
+}
+
+// bindModelEvents 第一次注册监听器时订阅当前model所在表的事件，同一个表的多个Repository都会收到事件。
+// 没有注册监听器的Repository（比如按请求创建的）不会订阅，避免全局订阅者越来越多
+func (repo *Repository[T]) bindModelEvents() {
+	repo.binding.mu.Lock()
+	defer repo.binding.mu.Unlock()
+	if repo.binding.unbind == nil {
+		repo.binding.unbind = db.BindModelEvents(repo.modelCreator(), repo.onModelEvent)
 	}
 }
 
-// onModelEvent 模型事件回调
+// Close 取消订阅模型事件，已注册的监听器不再被调用（再次注册监听器时会重新订阅）。
+// 注册了监听器、但生命周期短于进程的Repository，不再使用时需要调用
+func (repo *Repository[T]) Close() {
+	repo.binding.mu.Lock()
+	defer repo.binding.mu.Unlock()
+	if repo.binding.unbind != nil {
+		repo.binding.unbind()
+		repo.binding.unbind = nil
+	}
+}
+
+// onModelEvent 模型事件回调，同一个表的所有Repository都会收到
 func (repo *Repository[T]) onModelEvent(ctx context.Context, tx *db.DB, model schema.Tabler, eventType event.EventType, args ...any) error {
-	var m T
-	if model != nil {
-		m = model.(T)
+	m, ok := model.(T)
+	if !ok {
+		// 同一个表的其他类型的model
+		return nil
+	}
+
+	var payload any
+	if len(args) == 1 {
+		if p, ok := args[0].(eventPayload); ok {
+			payload, args = p.value, p.arguments
+		}
+	}
+	return repo.events.FireEventWithPayload(ctx, tx, eventType, m, payload, args...)
+}
+
+// fireBatchEvent 触发BatchUpdated/BatchDeleted事件，Model为空的model。
+// 为兼容旧的监听器，BatchUpdated的ModelEvent.Arguments仍然为：query *cnd.QueryBuilder, attributes Columns
+func (repo *Repository[T]) fireBatchEvent(ctx context.Context, tx *db.DB, eventType event.EventType, payload any) error {
+	var arguments []any
+	if p, ok := payload.(*BatchUpdatedPayload); ok {
+		arguments = []any{p.Query, p.Attributes}
 	}
-	return repo.events.FireEvent(ctx, tx, eventType, m)
+	return db.FireModelEvent(ctx, tx, repo.modelCreator(), eventType, eventPayload{value: payload, arguments: arguments})
 }
 
-// FireEvent 手动触发事件
+// FireEvent 手动触发Customer事件，只调用当前Repository注册的监听器（不会通知同一个表的其他Repository）
 func (repo *Repository[T]) FireEvent(ctx context.Context, model T, args ...any) error {
-	return repo.onModelEvent(ctx, nil, model, event.Customer, args...)
+	return repo.events.FireEvent(ctx, nil, event.Customer, model, args...)
 }
@@ -0,0 +1,142 @@
+package repo_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo/repotest"
+)
+
+type message struct {
+	db.Model
+	Title string
+}
+
+func (message) TableName() string {
+	return "messages"
+}
+
+// eventRecorder 记录收到的事件类型
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []event.EventType
+}
+
+func (r *eventRecorder) listen(_ context.Context, e event.ModelEvent[*message]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e.EventType)
+	return nil
+}
+
+func (r *eventRecorder) take() []event.EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func TestEventSubscribers(t *testing.T) {
+	orm := repotest.NewDB(t, &message{})
+	newRepo := func() *repo.Repository[*message] {
+		return repotest.NewRepository(t, orm, func() *message { return &message{} })
+	}
+	ctx := context.Background()
+
+	first, second, silent := newRepo(), newRepo(), newRepo()
+	var a, b eventRecorder
+	first.RegisterEventListener(event.Created, a.listen)
+	second.RegisterEventListener(event.Created, b.listen)
+
+	// 同一个表的多个Repository都会收到事件，没有注册监听器的Repository不影响其他订阅者
+	if err := silent.Create(ctx, &message{Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if events := a.take(); len(events) != 1 || events[0] != event.Created {
+		t.Fatalf("expected the first repository receives created, got %v", events)
+	}
+	if events := b.take(); len(events) != 1 || events[0] != event.Created {
+		t.Fatalf("expected the second repository receives created, got %v", events)
+	}
+
+	// Close后取消订阅，其他订阅者仍然收到事件
+	first.Close()
+	if err := silent.Create(ctx, &message{Title: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if events := a.take(); len(events) != 0 {
+		t.Fatalf("expected the closed repository receives nothing, got %v", events)
+	}
+	if events := b.take(); len(events) != 1 {
+		t.Fatalf("expected the second repository receives created, got %v", events)
+	}
+
+	// 再次注册监听器时重新订阅，并且Close多次是安全的
+	first.Close()
+	first.RegisterEventListener(event.Deleted, a.listen)
+	model := &message{Title: "c"}
+	if err := silent.Create(ctx, model); err != nil {
+		t.Fatal(err)
+	} else if err = silent.Delete(ctx, model); err != nil {
+		t.Fatal(err)
+	}
+	if events := a.take(); len(events) != 2 || events[0] != event.Created || events[1] != event.Deleted {
+		t.Fatalf("expected the rebound repository receives created and deleted, got %v", events)
+	}
+	b.take()
+
+	// FireEvent只调用当前Repository的监听器
+	second.RegisterEventListener(event.Customer, b.listen)
+	first.RegisterEventListener(event.Customer, a.listen)
+	if err := second.FireEvent(ctx, model, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if events := a.take(); len(events) != 0 {
+		t.Fatalf("expected FireEvent does not notify other repositories, got %v", events)
+	}
+	if events := b.take(); len(events) != 1 || events[0] != event.Customer {
+		t.Fatalf("expected the second repository receives customer, got %v", events)
+	}
+}
+
+func TestBatchUpdatedArguments(t *testing.T) {
+	orm := repotest.NewDB(t, &message{})
+	messages := repotest.NewRepository(t, orm, func() *message { return &message{} })
+	ctx := context.Background()
+	if err := messages.Create(ctx, &message{Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload *repo.BatchUpdatedPayload
+	var arguments []any
+	messages.RegisterEventListener(event.BatchUpdated, func(ctx context.Context, e event.ModelEvent[*message]) error {
+		payload, _ = event.PayloadOf[*repo.BatchUpdatedPayload](e)
+		arguments = e.Arguments
+		return nil
+	})
+
+	query := cnd.Eq("title", "a")
+	if err := messages.UpdateColumns(ctx, query, repo.Columns{"title": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if payload == nil || payload.Query != query || len(payload.Columns) != 1 || payload.Columns[0] != "title" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+
+	// 兼容旧的监听器：Arguments为query, attributes
+	if len(arguments) != 2 {
+		t.Fatalf("expected 2 arguments, got %v", arguments)
+	}
+	if q, ok := arguments[0].(*cnd.QueryBuilder); !ok || q != query {
+		t.Fatalf("expected the first argument is the query, got %v", arguments[0])
+	}
+	if attributes, ok := arguments[1].(repo.Columns); !ok || attributes["title"] != "b" {
+		t.Fatalf("expected the second argument is the attributes, got %v", arguments[1])
+	}
+}
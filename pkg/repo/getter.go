@@ -2,9 +2,13 @@ package repo
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/pkg/errors"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"strings"
 )
 
 // Count 查询资源数量，但是数据库错误了也返回0，则会让程序
@@ -98,6 +102,108 @@ func (repo *Repository[T]) Paginate(ctx context.Context, query *cnd.QueryBuilder
 	return models, nil
 }
 
+// CursorPaginate 游标（keyset）分页，不使用OFFSET，默认也不统计总数，适用于大表。
+// 排序使用query.Order设置的字段（支持多字段、混合升降序），主键会自动追加为最后一个排序字段以保证顺序唯一。
+// cursor为上一页返回的CursorResult.Cursor，第一页传""。如果需要总数，传入WithCursorTotal()
+// example: repo.CursorPaginate(ctx, cnd.Desc("created_at"), req.Cursor, 20)
+func (repo *Repository[T]) CursorPaginate(ctx context.Context, query *cnd.QueryBuilder, cursor string, limit int, options ...CursorOption) (*cnd.CursorResult, error) {
+	opts := &cursorOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	tableName := repo.modelCreator().TableName()
+	// 没有设置密钥时，无论是否有下一页都直接报错，避免上线后才发现游标无法签发
+	if err := cnd.CheckCursorSecret(); err != nil {
+		return nil, errors.Wrapf(err, "repo CursorPaginate method of table \"%s\" failed", tableName)
+	}
+	sch, err := repo.getSchema()
+	if err != nil {
+		return nil, errors.Wrapf(err, "repo CursorPaginate method of table \"%s\" parse schema failed", tableName)
+	}
+
+	// 游标分页自行控制limit，调用方设置的分页、offset会跳过记录
+	q := query.Clone().Unpaged()
+	// 追加主键作为最后一个排序字段，保证游标的唯一性
+	if primary := sch.PrioritizedPrimaryField; primary != nil {
+		orders := q.GetOrders()
+		if len(orders) == 0 || cursorColumnName(orders[len(orders)-1].Column) != primary.DBName {
+			q.Asc(primary.DBName)
+		}
+	}
+
+	orders := q.GetOrders()
+	fields := make([]*schema.Field, 0, len(orders))
+	for _, order := range orders {
+		field := sch.LookUpField(cursorColumnName(order.Column))
+		if field == nil {
+			return nil, errors.Errorf("repo CursorPaginate method of table \"%s\" failed: unknown order column \"%s\"", tableName, order.Column)
+		}
+		fields = append(fields, field)
+	}
+
+	result := &cnd.CursorResult{}
+	if opts.withTotal {
+		var total int64
+		if total, err = repo.Count(ctx, q); err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	if cursor != "" {
+		raws, err := cnd.DecodeCursor(cursor)
+		if err != nil || len(raws) != len(fields) {
+			return nil, errors.Wrapf(cnd.ErrInvalidCursor, "repo CursorPaginate method of table \"%s\" failed", tableName)
+		}
+		// 按字段类型还原游标中的值，避免time.Time等类型被当作字符串比较
+		values := make([]any, len(raws))
+		for i, raw := range raws {
+			value := reflect.New(fields[i].FieldType)
+			if err = json.Unmarshal(raw, value.Interface()); err != nil {
+				return nil, errors.Wrapf(cnd.ErrInvalidCursor, "repo CursorPaginate method of table \"%s\" failed", tableName)
+			}
+			values[i] = value.Elem().Interface()
+		}
+		q.Seek(values...)
+	}
+
+	// 多查询一条，用于判断是否还有下一页
+	var models []T
+	orm := repo.GetDB(ctx).Model(repo.modelCreator())
+	if err = q.Limit(limit + 1).Build(orm).Find(&models).Error; err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+		return nil, errors.Wrapf(err, "repo CursorPaginate method of table \"%s\" get data failed", tableName)
+	}
+
+	if len(models) > limit {
+		models = models[:limit]
+		result.HasMore = true
+
+		last := reflect.ValueOf(models[len(models)-1])
+		values := make([]any, len(fields))
+		for i, field := range fields {
+			values[i], _ = field.ValueOf(ctx, last)
+		}
+		if result.Cursor, err = cnd.EncodeCursor(values...); err != nil {
+			return nil, errors.Wrapf(err, "repo CursorPaginate method of table \"%s\" encode cursor failed", tableName)
+		}
+	}
+
+	result.Results = models
+	return result, nil
+}
+
+// cursorColumnName 去掉字段的表名前缀，比如：users.id -> id
+func cursorColumnName(column string) string {
+	if i := strings.LastIndex(column, "."); i >= 0 {
+		column = column[i+1:]
+	}
+	return strings.Trim(column, "`\"")
+}
+
 // Find 查询id，如果没有找到【不会】返回ErrRecordNotFound
 func (repo *Repository[T]) Find(ctx context.Context, id any) (T, error) {
 	var model T
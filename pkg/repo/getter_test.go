@@ -0,0 +1,125 @@
+package repo_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo/repotest"
+)
+
+func TestMain(m *testing.M) {
+	cnd.SetCursorSecret([]byte("repo-test-secret"))
+	os.Exit(m.Run())
+}
+
+type article struct {
+	ID     int64 `gorm:"primaryKey"`
+	Score  int
+	Status int
+}
+
+func (article) TableName() string {
+	return "articles"
+}
+
+func newArticleRepo(t *testing.T, scores ...int) *repo.Repository[*article] {
+	orm := repotest.NewDB(t, &article{})
+	articles := repotest.NewRepository(t, orm, func() *article { return &article{} })
+	models := make([]*article, 0, len(scores))
+	for _, score := range scores {
+		models = append(models, &article{Score: score, Status: score % 2})
+	}
+	if len(models) > 0 {
+		if err := articles.Create(context.Background(), models...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return articles
+}
+
+func TestCursorPaginate(t *testing.T) {
+	// 分数有重复，需要使用主键保证顺序唯一
+	articles := newArticleRepo(t, 5, 3, 5, 1, 3, 5, 2)
+	ctx := context.Background()
+
+	var ids []int64
+	var cursor string
+	for page := 0; ; page++ {
+		if page > 10 {
+			t.Fatal("too many pages")
+		}
+		result, err := articles.CursorPaginate(ctx, cnd.NewQueryBuilder().Desc("score"), cursor, 3, repo.WithCursorTotal())
+		if err != nil {
+			t.Fatal(err)
+		} else if *result.Total != 7 {
+			t.Fatalf("expected total 7, got %d", *result.Total)
+		}
+		for _, model := range result.Results.([]*article) {
+			ids = append(ids, model.ID)
+		}
+		if !result.HasMore {
+			break
+		}
+		cursor = result.Cursor
+	}
+
+	expected := []int64{1, 3, 6, 2, 5, 7, 4}
+	if len(ids) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ids)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, ids)
+		}
+	}
+}
+
+func TestCursorPaginateWithOr(t *testing.T) {
+	articles := newArticleRepo(t, 1, 2, 3, 4)
+	ctx := context.Background()
+
+	query := cnd.Eq("score", 1).Or("score = ?", 2)
+	first, err := articles.CursorPaginate(ctx, query, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	} else if !first.HasMore {
+		t.Fatal("expected more pages")
+	}
+
+	// 游标条件不能被Or条件绕过，否则第二页会重复返回第一页的记录
+	second, err := articles.CursorPaginate(ctx, query, first.Cursor, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	models := second.Results.([]*article)
+	if len(models) != 1 || models[0].ID != 2 || second.HasMore {
+		t.Fatalf("expected the second page to be article 2 without more pages, got %+v, has more %v", models, second.HasMore)
+	}
+}
+
+func TestCursorPaginateInvalidCursor(t *testing.T) {
+	articles := newArticleRepo(t, 1)
+	if _, err := articles.CursorPaginate(context.Background(), cnd.NewQueryBuilder(), "invalid", 1); err == nil {
+		t.Fatal("expected error of the invalid cursor")
+	}
+}
+
+func TestCursorPaginateIgnoresPaging(t *testing.T) {
+	articles := newArticleRepo(t, 1, 2, 3, 4, 5)
+
+	// 调用方设置的分页不能带入游标查询，否则OFFSET会跳过记录
+	query := cnd.NewQueryBuilder().Asc("score").Paginate(2, 2)
+	result, err := articles.CursorPaginate(context.Background(), query, "", 3, repo.WithCursorTotal())
+	if err != nil {
+		t.Fatal(err)
+	} else if *result.Total != 5 {
+		t.Fatalf("expected total 5, got %d", *result.Total)
+	}
+	models := result.Results.([]*article)
+	if len(models) != 3 || models[0].ID != 1 || !result.HasMore {
+		t.Fatalf("expected the first 3 articles with more pages, got %+v, has more %v", models, result.HasMore)
+	}
+}
@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"context"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/tenant"
+)
+
+type cursorOptions struct {
+	withTotal bool
+}
+
+type CursorOption func(*cursorOptions)
+
+// WithCursorTotal 游标分页时同时统计总数（会额外执行一次COUNT(*)，大表请谨慎使用）
+func WithCursorTotal() CursorOption {
+	return func(o *cursorOptions) {
+		o.withTotal = true
+	}
+}
+
+type repositoryOptions struct {
+	tenantColumn   string
+	tenantResolver tenant.Resolver
+	tenantRouter   TenantRouter
+
+	eventDispatcher event.Dispatcher
+}
+
+type Option func(*repositoryOptions)
+
+// TenantRouter 根据租户ID返回该租户的数据库连接（比如独立的库、或PgSQL设置了search_path的连接）
+type TenantRouter func(ctx context.Context, tenantID any) (*db.DB, error)
+
+// WithTenantScope 开启租户隔离：所有查询、更新、删除自动添加column = 租户ID的条件，创建、保存时自动填充column。
+// ctx中解析不到租户时返回tenant.ErrMissingTenant；跨租户的场景使用tenant.WithoutScope(ctx)或repo.WithoutTenantScope()。
+// 注意：Remember的缓存key需要自行包含租户ID
+// example: repo.NewRepository(db, cache, creator, logger, repo.WithTenantScope(tenant.DefaultColumn, tenant.AuthResolver))
+func WithTenantScope(column string, resolver tenant.Resolver) Option {
+	return func(o *repositoryOptions) {
+		o.tenantColumn = column
+		o.tenantResolver = resolver
+	}
+}
+
+// WithTenantRouter 按租户路由数据库连接（需要同时使用WithTenantScope）。事务中使用开启事务时的连接
+func WithTenantRouter(router TenantRouter) Option {
+	return func(o *repositoryOptions) {
+		o.tenantRouter = router
+	}
+}
+
+// WithEventDispatcher 设置执行异步监听器（event.Async()）的任务池，比如worker.Worker；未设置时每个异步监听器使用新的协程执行
+func WithEventDispatcher(dispatcher event.Dispatcher) Option {
+	return func(o *repositoryOptions) {
+		o.eventDispatcher = dispatcher
+	}
+}
@@ -2,12 +2,18 @@ package repo
 
 import (
 	"context"
+	"github.com/pkg/errors"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/cache"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/clause"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/tenant"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 	"reflect"
+	"slices"
+	"sync"
 )
 
 type Repository[T db.Tabler] struct {
@@ -15,23 +21,41 @@ type Repository[T db.Tabler] struct {
 	cache        *cache.Cache
 	modelCreator func() T
 	logger       *log.Helper
+	batchSize    int
+	options      repositoryOptions
+	clauses      []clause.Expression
 
-	events event.Events[T]
+	withoutTenantScope bool
+
+	events  *event.Events[T]
+	binding *eventBinding
+}
+
+// eventBinding 订阅全局模型事件的状态，Repository及其Clauses、WithBatchSize等返回的副本共享
+type eventBinding struct {
+	mu     sync.Mutex
+	unbind func()
 }
 
 func NewRepository[T db.Tabler](
 	_db *db.DB,
 	_cache *cache.Cache,
 	modelCreator func() T,
-	logger log.Logger) *Repository[T] {
+	logger log.Logger,
+	options ...Option) *Repository[T] {
 
 	repo := &Repository[T]{
 		db:           _db,
 		cache:        _cache,
 		modelCreator: modelCreator,
 		logger:       log.NewModuleHelper(logger, "repo/base"),
+		batchSize:    100,
 
-		events: make(event.Events[T]),
+		events:  event.NewEvents[T](),
+		binding: &eventBinding{},
+	}
+	for _, option := range options {
+		option(&repo.options)
 	}
 
 	typeOf := reflect.TypeOf(modelCreator())
@@ -39,8 +63,13 @@ func NewRepository[T db.Tabler](
 		panic("modelCreator[T] must return a pointer of model")
 	}
 
-	// Hook当前model的事件
-	db.BindModelEvents(modelCreator(), repo.onModelEvent)
+	if repo.options.eventDispatcher != nil {
+		repo.events.SetDispatcher(repo.options.eventDispatcher)
+	}
+	repo.events.SetAsyncErrorHandler(func(ctx context.Context, eventType event.EventType, err error) {
+		repo.logger.WithContext(ctx).Errorf("async listener of event \"%s\" on table \"%s\" failed: %v", eventType, repo.modelCreator().TableName(), err)
+	})
+
 	return repo
 }
 
@@ -51,28 +80,122 @@ func newTxContext(ctx context.Context, value *db.DB) context.Context {
 	return context.WithValue(ctx, &transactionKey{}, value)
 }
 
-// GetDB 获取db，如果是事务，并将ctx附加到gorm中
+// GetDB 获取db，如果是事务，并将ctx附加到gorm中。开启了租户隔离时，会添加租户条件
 func (repo *Repository[T]) GetDB(ctx context.Context) *db.DB {
 	if ctx != nil {
 		if tx := ctx.Value(&transactionKey{}); tx != nil {
-			return tx.(*db.DB).WithContext(ctx)
+			return repo.scopeTenant(ctx, repo.withClauses(tx.(*db.DB).WithContext(ctx)))
 		}
 	}
 
-	return repo.db.WithContext(ctx)
+	return repo.scopeTenant(ctx, repo.withClauses(repo.getConnection(ctx).WithContext(ctx)))
+}
+
+// withClauses 添加Clauses设置的条件
+func (repo *Repository[T]) withClauses(orm *db.DB) *db.DB {
+	if len(repo.clauses) == 0 {
+		return orm
+	}
+	return orm.Clauses(repo.clauses...)
+}
+
+// getConnection 获取数据库连接，如果设置了TenantRouter，则返回租户的连接
+func (repo *Repository[T]) getConnection(ctx context.Context) *db.DB {
+	if repo.options.tenantRouter == nil || !repo.isTenantScoped(ctx) {
+		return repo.db
+	}
+
+	tenantID, ok := repo.options.tenantResolver(ctx)
+	if !ok {
+		orm := repo.db.Session(&gorm.Session{})
+		_ = orm.AddError(tenant.ErrMissingTenant)
+		return orm
+	}
+	orm, err := repo.options.tenantRouter(ctx, tenantID)
+	if err != nil {
+		orm = repo.db.Session(&gorm.Session{})
+		_ = orm.AddError(errors.Wrapf(err, "route connection of tenant \"%v\" failed", tenantID))
+	}
+	return orm
+}
+
+// isTenantScoped 当前是否需要租户隔离
+func (repo *Repository[T]) isTenantScoped(ctx context.Context) bool {
+	return repo.options.tenantColumn != "" && repo.options.tenantResolver != nil &&
+		!repo.withoutTenantScope && (ctx == nil || !tenant.IsScopeDisabled(ctx))
 }
 
-// Clauses 添加条件，比如：Clauses(clause.Write).Find(ctx, cnd.Eq("id", 1))，表示强制使用主库查询
+// scopeTenant 添加租户条件
+func (repo *Repository[T]) scopeTenant(ctx context.Context, orm *db.DB) *db.DB {
+	if ctx == nil || !repo.isTenantScoped(ctx) {
+		return orm
+	}
+	return orm.Scopes(tenant.Scope(ctx, repo.options.tenantColumn, repo.options.tenantResolver))
+}
+
+// stampTenant 将租户ID填充到models的租户字段中（会覆盖原值，防止写入其他租户的数据）
+func (repo *Repository[T]) stampTenant(ctx context.Context, models ...T) error {
+	if !repo.isTenantScoped(ctx) || len(models) == 0 {
+		return nil
+	}
+
+	tenantID, ok := repo.options.tenantResolver(ctx)
+	if !ok {
+		return tenant.ErrMissingTenant
+	}
+	sch, err := repo.getSchema()
+	if err != nil {
+		return err
+	}
+	field := sch.LookUpField(repo.options.tenantColumn)
+	if field == nil {
+		return errors.Errorf("tenant column \"%s\" is not found in table \"%s\"", repo.options.tenantColumn, sch.Table)
+	}
+	for _, model := range models {
+		if err = field.Set(ctx, reflect.ValueOf(model), tenantID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithoutTenantScope 返回跳过租户隔离的IOrm，不影响当前repo。用于管理后台、定时任务等跨租户的场景
+func (repo *Repository[T]) WithoutTenantScope() IOrm[T] {
+	_repo := *repo
+	_repo.withoutTenantScope = true
+	return &_repo
+}
+
+// getSchema 解析T的schema（gorm内部有缓存）
+func (repo *Repository[T]) getSchema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: repo.db}
+	if err := stmt.Parse(repo.modelCreator()); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// Clauses 添加条件，比如：Clauses(clause.Write).Find(ctx, cnd.Eq("id", 1))，表示强制使用主库查询。
+// 返回新的IOrm，不影响当前repo。在事务、按租户路由的连接中同样生效
 func (repo *Repository[T]) Clauses(cnds ...clause.Expression) IOrm[T] {
 	_repo := *repo
-	_repo.db = repo.db.Clauses(cnds...)
+	_repo.clauses = append(slices.Clone(repo.clauses), cnds...)
+	return &_repo
+}
+
+// WithBatchSize 设置Create、Upsert、UpdateMany每批处理的数量（默认100），返回新的IOrm，不影响当前repo
+func (repo *Repository[T]) WithBatchSize(size int) IOrm[T] {
+	_repo := *repo
+	if size > 0 {
+		_repo.batchSize = size
+	}
 	return &_repo
 }
 
 // Transaction 开启事务
 func (repo *Repository[T]) Transaction(ctx context.Context, steps ...func(ctx context.Context) error) error {
 	var err error
-	tx := repo.db.Begin()
+	tx := repo.getConnection(ctx).Begin()
 	defer func() {
 		if err != nil {
 			repo.logger.WithContext(ctx).Error(err)
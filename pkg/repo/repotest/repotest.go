@@ -0,0 +1,51 @@
+package repotest
+
+import (
+	"context"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/sqlite"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo"
+	gormLogger "gorm.io/gorm/logger"
+	"testing"
+)
+
+// NewDB 打开一个独立的SQLite内存数据库用于单元测试：注册模型事件，并对models执行AutoMigrate，测试结束时自动关闭
+// example:
+//
+//	func TestUserRepo(t *testing.T) {
+//		orm := repotest.NewDB(t, &User{})
+//		userRepo := repotest.NewRepository(t, orm, func() *User { return &User{} })
+//		...
+//	}
+func NewDB(tb testing.TB, models ...any) *db.DB {
+	tb.Helper()
+
+	orm, err := sqlite.OpenInMemory(&db.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		tb.Fatalf("open sqlite in memory failed: %v", err)
+	}
+	event.RegisterGormEvents(orm)
+
+	if sqlDB, err := orm.DB(); err == nil {
+		tb.Cleanup(func() {
+			_ = sqlDB.Close()
+		})
+	}
+
+	if len(models) > 0 {
+		if err = orm.AutoMigrate(models...); err != nil {
+			tb.Fatalf("auto migrate failed: %v", err)
+		}
+	}
+	return orm
+}
+
+// NewRepository 使用orm（通常是NewDB返回的）创建Repository，不使用缓存。测试结束时取消订阅模型事件
+func NewRepository[T db.Tabler](tb testing.TB, orm *db.DB, modelCreator func() T, options ...repo.Option) *repo.Repository[T] {
+	tb.Helper()
+	r := repo.NewRepository[T](orm, nil, modelCreator, log.New(context.Background()), options...)
+	tb.Cleanup(r.Close)
+	return r
+}
@@ -4,18 +4,25 @@ import (
 	"context"
 	"fmt"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/clause"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"maps"
+	"reflect"
+	"slices"
 )
 
 // Create 批量创建资源
 // example: repo.Create(ctx, &User{Name: "tom"}, &User{Name: "jerry"})
 // T必须为指针类型
 func (repo *Repository[T]) Create(ctx context.Context, models ...T) error {
-	// 每次INSERT 100条
-	return repo.GetDB(ctx).CreateInBatches(models, 100).Error
+	if err := repo.stampTenant(ctx, models...); err != nil {
+		return err
+	}
+	// 每次INSERT batchSize条，默认100条
+	return repo.GetDB(ctx).CreateInBatches(models, repo.batchSize).Error
 }
 
 // Save 保存资源，如果主键为空，则创建，否则更新。注意：零值【会】更新
@@ -23,8 +30,33 @@ func (repo *Repository[T]) Create(ctx context.Context, models ...T) error {
 // example: repo.Save(ctx, &User{ID: 1, Name: "tom"}, &User{Name: "jerry"})
 // T必须为指针类型
 func (repo *Repository[T]) Save(ctx context.Context, models ...T) error {
+	if err := repo.stampTenant(ctx, models...); err != nil {
+		return err
+	}
+	sch, err := repo.getSchema()
+	if err != nil {
+		return err
+	}
+	versionField := repo.getVersionField()
 	// Save 不支持[]T，需要遍历
 	for _, model := range models {
+		if primary := sch.PrioritizedPrimaryField; primary != nil {
+			if _, zero := primary.ValueOf(ctx, reflect.ValueOf(model)); !zero {
+				if versionField != nil {
+					// 乐观锁：主键不为空时，带版本更新所有字段
+					if err = repo.updateVersioned(ctx, model, versionField, true); err != nil {
+						return err
+					}
+					continue
+				} else if repo.isTenantScoped(ctx) {
+					// 租户隔离：只更新本租户的记录，不使用Save没有更新到记录时ON CONFLICT插入的行为，避免覆盖其他租户的记录
+					if err = repo.GetDB(ctx).Model(model).Select("*").Updates(model).Error; err != nil {
+						return err
+					}
+					continue
+				}
+			}
+		}
 		if err := repo.GetDB(ctx).Save(model).Error; err != nil {
 			return err
 		}
@@ -36,8 +68,18 @@ func (repo *Repository[T]) Save(ctx context.Context, models ...T) error {
 // example: repo.Update(ctx, &User{ID: 1, Name: "tom"}, &User{ID: 2, Name: "jerry"})
 // T必须为指针类型
 func (repo *Repository[T]) Update(ctx context.Context, models ...T) error {
+	if err := repo.stampTenant(ctx, models...); err != nil {
+		return err
+	}
+	versionField := repo.getVersionField()
 	// Updates 不支持[]T，需要遍历
 	for _, model := range models {
+		if versionField != nil {
+			if err := repo.updateVersioned(ctx, model, versionField, false); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := repo.GetDB(ctx).Model(model).Updates(model).Error; err != nil {
 			return err
 		}
@@ -52,65 +94,90 @@ func (repo *Repository[T]) Delete(ctx context.Context, models ...T) error {
 	return repo.GetDB(ctx).Delete(models).Error
 }
 
-// DeleteWithBuilder 使用query删除资源
+// DeleteWithBuilder 使用query删除资源，每条记录触发Deleted事件（需要数据库支持RETURNING），最后触发BatchDeleted事件
 // example: repo.Delete(ctx, db.ID(1))、或repo.Delete(ctx, cnd.Where("name", "tom"))
 func (repo *Repository[T]) DeleteWithBuilder(ctx context.Context, query *cnd.QueryBuilder) error {
 	orm := repo.GetDB(ctx).Model(repo.modelCreator())
 	var models []T
 	// 启用删除回写（PgSQL支持）
 	// Delete()第一个参数必须是model(s)，不然无法绑定Where条件，并且不能在Delete之前设置db.Model(...)
-	if err := query.WithDeleteReturning().Build(repo.GetDB(ctx)).Delete(&models).Error; err != nil {
-		return err
+	result := query.WithDeleteReturning().Build(repo.GetDB(ctx)).Delete(&models)
+	if result.Error != nil {
+		return result.Error
 	}
 	// 循环触发事件
 	for _, model := range models {
-		if err := repo.onModelEvent(ctx, orm, model, event.Deleted); err != nil {
+		if err := db.FireModelEvent(ctx, orm, model, event.Deleted); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return repo.fireBatchEvent(ctx, orm, event.BatchDeleted, &BatchDeletedPayload{
+		Query:        query,
+		RowsAffected: result.RowsAffected,
+	})
 }
 
-// DeletePrimary 通过主键删除资源
+// DeletePrimary 通过主键删除资源，每条记录触发Deleted事件（需要数据库支持RETURNING），最后触发BatchDeleted事件
 // example: repo.DeletePrimary(ctx, 1, 2, 3)
 func (repo *Repository[T]) DeletePrimary(ctx context.Context, primary ...any) error {
 	orm := repo.GetDB(ctx).Model(repo.modelCreator())
 	var models []T
 	// 启用删除回写（PgSQL支持）
 	// Delete() 第一个参数必须是model(s)，并且不能在Delete之前设置db.Model(...)
-	if err := repo.GetDB(ctx).Clauses(clause.Returning{}).Delete(&models, primary).Error; err != nil {
-		return err
+	result := repo.GetDB(ctx).Clauses(clause.Returning{}).Delete(&models, primary)
+	if result.Error != nil {
+		return result.Error
 	}
 	// 循环触发事件
 	for _, model := range models {
-		if err := repo.onModelEvent(ctx, orm, model, event.Deleted); err != nil {
+		if err := db.FireModelEvent(ctx, orm, model, event.Deleted); err != nil {
 			return err
 		}
 	}
-	return nil
+
+	return repo.fireBatchEvent(ctx, orm, event.BatchDeleted, &BatchDeletedPayload{
+		Primaries:    primary,
+		RowsAffected: result.RowsAffected,
+	})
 }
 
-// UpdateColumns 更新资源多个字段
+// UpdateColumns 更新资源多个字段。
+// 乐观锁：如果model启用了乐观锁（db.IVersionedModel），并且attributes中包含version，则将其作为期望的版本加入WHERE条件，
+// 并将version递增，没有更新到记录时返回ErrStaleModel；attributes中不包含version时不校验、也不修改version
 func (repo *Repository[T]) UpdateColumns(ctx context.Context, query *cnd.QueryBuilder, attributes Columns) error {
+	var checkVersion bool
+	if versionField := repo.getVersionField(); versionField != nil {
+		var expected any
+		if expected, checkVersion = attributes[versionField.DBName]; checkVersion {
+			// 先将调用方的条件分组，避免Or条件绕过版本校验
+			query = query.Clone().GroupConditions().Eq(versionField.DBName, expected)
+			attributes = maps.Clone(attributes)
+			attributes[versionField.DBName] = db.Expr(versionField.DBName + " + 1")
+		}
+	}
+
 	orm := repo.GetDB(ctx).Model(repo.modelCreator())
 	// 和Delete不同的是，需要在Updates之前设置orm.Model(...)
-	if err := query.Build(orm).Updates(attributes).Error; err != nil {
-		return errors.Wrapf(err, "repo UpdateColumns method of table \"%s\" failed", repo.modelCreator().TableName())
+	result := query.Build(orm).Updates(map[string]any(attributes))
+	if result.Error != nil {
+		return errors.Wrapf(result.Error, "repo UpdateColumns method of table \"%s\" failed", repo.modelCreator().TableName())
+	} else if checkVersion && result.RowsAffected == 0 {
+		return errors.Wrapf(db.ErrStaleModel, "repo UpdateColumns method of table \"%s\" failed", repo.modelCreator().TableName())
 	}
 
-	return repo.onModelEvent(ctx, orm, nil, event.BatchUpdated, query, attributes)
+	columns := lo.Keys(attributes)
+	slices.Sort(columns)
+	return repo.fireBatchEvent(ctx, orm, event.BatchUpdated, &BatchUpdatedPayload{
+		Query:      query,
+		Columns:    columns,
+		Attributes: attributes,
+	})
 }
 
-// UpdateColumn 更新资源单个字段
+// UpdateColumn 更新资源单个字段，乐观锁的行为同UpdateColumns
 func (repo *Repository[T]) UpdateColumn(ctx context.Context, query *cnd.QueryBuilder, key string, value any) error {
-	orm := repo.GetDB(ctx).Model(repo.modelCreator())
-	// 和Delete不同的是，需要在Update之前设置orm.Model(...)
-	if err := query.Build(orm).Update(key, value).Error; err != nil {
-		return errors.Wrapf(err, "repo UpdateColumn method of table \"%s\" failed", repo.modelCreator().TableName())
-	}
-
-	return repo.onModelEvent(ctx, orm, nil, event.BatchUpdated, query, Columns{key: value})
+	return repo.UpdateColumns(ctx, query, Columns{key: value})
 }
 
 // Incr 递增某字段
@@ -0,0 +1,96 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/tenant"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo/repotest"
+)
+
+type tenantOrder struct {
+	ID       int64 `gorm:"primaryKey"`
+	TenantID int64
+	Status   int
+}
+
+func (tenantOrder) TableName() string {
+	return "tenant_orders"
+}
+
+func newTenantRepo(t *testing.T) *repo.Repository[*tenantOrder] {
+	orm := repotest.NewDB(t, &tenantOrder{})
+	return repotest.NewRepository(t, orm, func() *tenantOrder { return &tenantOrder{} },
+		repo.WithTenantScope(tenant.DefaultColumn, tenant.ContextResolver))
+}
+
+func TestTenantScope(t *testing.T) {
+	orders := newTenantRepo(t)
+	ctx1 := tenant.NewContext(context.Background(), int64(1))
+	ctx2 := tenant.NewContext(context.Background(), int64(2))
+
+	// 创建时填充租户ID，覆盖传入的值
+	if err := orders.Create(ctx1, &tenantOrder{Status: 1}, &tenantOrder{TenantID: 2, Status: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := orders.Create(ctx2, &tenantOrder{Status: 1}, &tenantOrder{Status: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := orders.Get(ctx1, cnd.NewQueryBuilder())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(models) != 2 {
+		t.Fatalf("expected 2 orders of tenant 1, got %d", len(models))
+	}
+	for _, model := range models {
+		if model.TenantID != 1 {
+			t.Fatalf("expected tenant 1, got %d", model.TenantID)
+		}
+	}
+
+	if _, err = orders.Get(context.Background(), cnd.NewQueryBuilder()); err == nil {
+		t.Fatal("expected ErrMissingTenant without tenant in context")
+	}
+
+	count, err := orders.WithoutTenantScope().Count(context.Background(), cnd.NewQueryBuilder())
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 4 {
+		t.Fatalf("expected 4 orders without tenant scope, got %d", count)
+	}
+}
+
+func TestTenantScopeWithOr(t *testing.T) {
+	orders := newTenantRepo(t)
+	ctx1 := tenant.NewContext(context.Background(), int64(1))
+	ctx2 := tenant.NewContext(context.Background(), int64(2))
+
+	if err := orders.Create(ctx1, &tenantOrder{Status: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := orders.Create(ctx2, &tenantOrder{Status: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不分组时会生成 status = 1 OR status = 2 AND tenant_id = 1，查询到租户2的记录
+	models, err := orders.Get(ctx1, cnd.Eq("status", 1).Or("status = ?", 2))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(models) != 1 || models[0].TenantID != 1 {
+		t.Fatalf("expected only the order of tenant 1, got %+v", models)
+	}
+
+	// 更新同样不能影响其他租户
+	if err = orders.UpdateColumn(ctx1, cnd.Eq("status", 1).Or("status = ?", 2), "status", 3); err != nil {
+		t.Fatal(err)
+	}
+	other, err := orders.First(ctx2, cnd.NewQueryBuilder())
+	if err != nil {
+		t.Fatal(err)
+	} else if other.Status != 1 {
+		t.Fatalf("expected the order of tenant 2 not to be updated, got status %d", other.Status)
+	}
+}
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/clause"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/tenant"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/job"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"time"
+)
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// getSoftDeleteField 获取软删除字段（gorm.DeletedAt类型），不存在返回nil
+func (repo *Repository[T]) getSoftDeleteField() *schema.Field {
+	sch, err := repo.getSchema()
+	if err != nil {
+		return nil
+	}
+	for _, field := range sch.Fields {
+		if field.FieldType == deletedAtType {
+			return field
+		}
+	}
+	return nil
+}
+
+// Restore 恢复符合query的软删除记录，每条记录触发Restoring、Restored事件
+// example: repo.Restore(ctx, cnd.Eq("id", 1))
+func (repo *Repository[T]) Restore(ctx context.Context, query *cnd.QueryBuilder) error {
+	tableName := repo.modelCreator().TableName()
+	field := repo.getSoftDeleteField()
+	if field == nil {
+		return errors.Errorf("repo Restore method of table \"%s\" failed: model is not soft deletable", tableName)
+	}
+	sch, _ := repo.getSchema()
+	primary := sch.PrioritizedPrimaryField
+	if primary == nil {
+		return errors.Errorf("repo Restore method of table \"%s\" failed: primary key is required", tableName)
+	}
+
+	var models []T
+	if err := query.Clone().OnlyTrashed().Build(repo.GetDB(ctx)).Find(&models).Error; err != nil {
+		return errors.Wrapf(err, "repo Restore method of table \"%s\" failed", tableName)
+	} else if len(models) == 0 {
+		return nil
+	}
+
+	orm := repo.GetDB(ctx).Model(repo.modelCreator())
+	ids := make([]any, 0, len(models))
+	for _, model := range models {
+		if err := db.FireModelEvent(ctx, orm, model, event.Restoring); err != nil {
+			return err
+		}
+		id, _ := primary.ValueOf(ctx, reflect.ValueOf(model))
+		ids = append(ids, id)
+	}
+
+	// 跳过Updating/Updated事件，由Restoring/Restored代替；跳过hooks后gorm不会自动更新updated_at，需要手动设置
+	attributes := map[string]any{field.DBName: nil}
+	now := repo.db.NowFunc()
+	for _, f := range sch.Fields {
+		switch {
+		case f.AutoUpdateTime == 0:
+		case f.AutoUpdateTime == schema.UnixNanosecond:
+			attributes[f.DBName] = now.UnixNano()
+		case f.AutoUpdateTime == schema.UnixMillisecond:
+			attributes[f.DBName] = now.UnixMilli()
+		case f.GORMDataType == schema.Time:
+			attributes[f.DBName] = now
+		default:
+			attributes[f.DBName] = now.Unix()
+		}
+	}
+	if err := repo.GetDB(ctx).Session(&gorm.Session{SkipHooks: true}).Unscoped().
+		Model(repo.modelCreator()).
+		Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: primary.DBName}, Value: ids}).
+		Updates(attributes).Error; err != nil {
+		return errors.Wrapf(err, "repo Restore method of table \"%s\" failed", tableName)
+	}
+
+	for _, model := range models {
+		_ = field.Set(ctx, reflect.ValueOf(model), gorm.DeletedAt{})
+		if err := db.FireModelEvent(ctx, orm, model, event.Restored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForceDelete 永久删除资源（忽略软删除）。如果没有主键，为了避免批量删除，会返回ErrMissingWhereClause
+// example: repo.ForceDelete(ctx, &User{ID: 1}, &User{ID: 2})
+// T必须为指针类型
+func (repo *Repository[T]) ForceDelete(ctx context.Context, models ...T) error {
+	return repo.GetDB(ctx).Unscoped().Delete(models).Error
+}
+
+// ForceDeleteWithBuilder 使用query永久删除资源（包括已软删除的记录），事件同DeleteWithBuilder
+func (repo *Repository[T]) ForceDeleteWithBuilder(ctx context.Context, query *cnd.QueryBuilder) error {
+	return repo.DeleteWithBuilder(ctx, query.Clone().WithTrash())
+}
+
+// PurgeTrashed 永久删除软删除超过retention的记录（跨租户），返回删除的数量。不会触发Deleting/Deleted事件，只触发BatchDeleted事件
+func (repo *Repository[T]) PurgeTrashed(ctx context.Context, retention time.Duration) (int64, error) {
+	tableName := repo.modelCreator().TableName()
+	field := repo.getSoftDeleteField()
+	if field == nil {
+		return 0, errors.Errorf("repo PurgeTrashed method of table \"%s\" failed: model is not soft deletable", tableName)
+	}
+
+	ctx = tenant.WithoutScope(ctx)
+	query := cnd.NewQueryBuilder().WithTrash().Lt(field.DBName, time.Now().Add(-retention))
+	orm := repo.GetDB(ctx).Session(&gorm.Session{SkipHooks: true})
+	result := query.Build(orm).Delete(repo.modelCreator())
+	if result.Error != nil {
+		return 0, errors.Wrapf(result.Error, "repo PurgeTrashed method of table \"%s\" failed", tableName)
+	}
+
+	return result.RowsAffected, repo.fireBatchEvent(ctx, orm, event.BatchDeleted, &BatchDeletedPayload{
+		Query:        query,
+		RowsAffected: result.RowsAffected,
+	})
+}
+
+// PurgeTrashedJob 返回定时永久删除软删除记录的job
+// example: worker.OnceForCluster("users:purge").CronWith(userRepo.PurgeTrashedJob(30 * 24 * time.Hour)).DailyAt("04:00")
+func (repo *Repository[T]) PurgeTrashedJob(retention time.Duration) job.Job {
+	return func(ctx context.Context) {
+		tableName := repo.modelCreator().TableName()
+		if n, err := repo.PurgeTrashed(ctx, retention); err != nil {
+			repo.logger.WithContext(ctx).Errorf("purge trashed records of table \"%s\" failed: %v", tableName, err)
+		} else {
+			repo.logger.WithContext(ctx).Infof("purged %d trashed records of table \"%s\" before %s", n, tableName, retention)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo/repotest"
+)
+
+type post struct {
+	db.SoftDeleteModel
+	Title string
+}
+
+func (post) TableName() string {
+	return "posts"
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	orm := repotest.NewDB(t, &post{})
+	posts := repotest.NewRepository(t, orm, func() *post { return &post{} })
+	ctx := context.Background()
+
+	a, b, c := &post{Title: "a"}, &post{Title: "b"}, &post{Title: "c"}
+	if err := posts.Create(ctx, a, b, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := posts.Delete(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := posts.DeleteWithBuilder(ctx, cnd.Eq("title", "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCount := func(query *cnd.QueryBuilder, expected int64) {
+		t.Helper()
+		count, err := posts.Count(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		} else if count != expected {
+			t.Fatalf("expected %d posts, got %d", expected, count)
+		}
+	}
+	assertCount(cnd.NewQueryBuilder(), 1)
+	assertCount(cnd.NewQueryBuilder().WithTrash(), 3)
+	assertCount(cnd.NewQueryBuilder().OnlyTrashed(), 2)
+
+	if err := posts.Restore(ctx, cnd.Eq("id", a.ID)); err != nil {
+		t.Fatal(err)
+	}
+	assertCount(cnd.NewQueryBuilder(), 2)
+	if _, err := posts.FindOrFail(ctx, a.ID); err != nil {
+		t.Fatalf("expected post a to be restored, got %v", err)
+	}
+
+	if err := posts.ForceDeleteWithBuilder(ctx, cnd.Eq("id", b.ID)); err != nil {
+		t.Fatal(err)
+	}
+	assertCount(cnd.NewQueryBuilder().WithTrash(), 2)
+	assertCount(cnd.NewQueryBuilder().OnlyTrashed(), 0)
+}
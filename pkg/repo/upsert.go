@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/clause"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/event"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Upsert 批量插入，冲突时更新updateColumns（为空则更新所有非主键字段）。
+// MySQL使用ON DUPLICATE KEY UPDATE（会忽略conflictColumns），PgSQL/SQLite使用ON CONFLICT(conflictColumns)。
+// 会触发Saving/Creating/Created/Saved事件（数据库无法区分每行是插入还是更新）
+// example: repo.Upsert(ctx, users, []string{"email"}, []string{"name"})
+// T必须为指针类型
+func (repo *Repository[T]) Upsert(ctx context.Context, models []T, conflictColumns []string, updateColumns []string) error {
+	if len(models) == 0 {
+		return nil
+	} else if err := repo.stampTenant(ctx, models...); err != nil {
+		return err
+	}
+
+	onConflict := clause.OnConflict{}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+
+	if len(updateColumns) == 0 {
+		onConflict.UpdateAll = true
+	} else {
+		sch, err := repo.getSchema()
+		if err != nil {
+			return errors.Wrapf(err, "repo Upsert method of table \"%s\" parse schema failed", repo.modelCreator().TableName())
+		}
+		// 和UpdateAll的行为一致，自动更新updated_at
+		for _, field := range sch.Fields {
+			if field.AutoUpdateTime > 0 && !slices.Contains(updateColumns, field.DBName) {
+				updateColumns = append(slices.Clone(updateColumns), field.DBName)
+			}
+		}
+		onConflict.DoUpdates = clause.AssignmentColumns(updateColumns)
+	}
+
+	if err := repo.GetDB(ctx).Clauses(onConflict).CreateInBatches(models, repo.batchSize).Error; err != nil {
+		return errors.Wrapf(err, "repo Upsert method of table \"%s\" failed", repo.modelCreator().TableName())
+	}
+	return nil
+}
+
+// FirstOrCreate 查询符合attributes的第一条记录，不存在则使用attributes+values创建。
+// 存在时触发Found事件，创建时触发Saving/Creating/Created/Saved事件
+// example: repo.FirstOrCreate(ctx, repo.Columns{"email": "tom@a.com"}, repo.Columns{"name": "tom"})
+func (repo *Repository[T]) FirstOrCreate(ctx context.Context, attributes Columns, values Columns) (T, error) {
+	model := repo.modelCreator()
+	var nilModel T
+
+	orm := repo.GetDB(ctx).Where(map[string]any(attributes))
+	if len(values) > 0 {
+		orm = orm.Attrs(map[string]any(values))
+	}
+	if err := orm.FirstOrCreate(model).Error; err != nil {
+		return nilModel, errors.Wrapf(err, "repo FirstOrCreate method of table \"%s\" failed", repo.modelCreator().TableName())
+	}
+	return model, nil
+}
+
+// UpdateOrCreate 查询符合attributes的第一条记录，存在则使用values更新，不存在则使用attributes+values创建。
+// 更新时触发Found、Saving/Updating/Updated/Saved事件，创建时触发Saving/Creating/Created/Saved事件
+// example: repo.UpdateOrCreate(ctx, repo.Columns{"email": "tom@a.com"}, repo.Columns{"name": "tom"})
+func (repo *Repository[T]) UpdateOrCreate(ctx context.Context, attributes Columns, values Columns) (T, error) {
+	model := repo.modelCreator()
+	var nilModel T
+
+	orm := repo.GetDB(ctx).Where(map[string]any(attributes))
+	if len(values) > 0 {
+		orm = orm.Assign(map[string]any(values))
+	}
+	if err := orm.FirstOrCreate(model).Error; err != nil {
+		return nilModel, errors.Wrapf(err, "repo UpdateOrCreate method of table \"%s\" failed", repo.modelCreator().TableName())
+	}
+	return model, nil
+}
+
+// UpdateMany 使用一条UPDATE ... CASE WHEN语句（按batchSize分批）将每个model各自的columns值更新到数据库，零值【会】更新。
+// model必须有主键，每批更新后触发BatchUpdated事件，参数为BatchUpdatedPayload（主键条件的Query、Columns）
+// example: repo.UpdateMany(ctx, []*User{{ID: 1, Score: 10}, {ID: 2, Score: 20}}, "score")
+// 生成：UPDATE users SET score = CASE id WHEN 1 THEN 10 WHEN 2 THEN 20 ELSE score END, updated_at = ? WHERE id IN (1, 2)
+func (repo *Repository[T]) UpdateMany(ctx context.Context, models []T, columns ...string) error {
+	if len(models) == 0 || len(columns) == 0 {
+		return nil
+	}
+
+	tableName := repo.modelCreator().TableName()
+	sch, err := repo.getSchema()
+	if err != nil {
+		return errors.Wrapf(err, "repo UpdateMany method of table \"%s\" parse schema failed", tableName)
+	}
+	primary := sch.PrioritizedPrimaryField
+	if primary == nil {
+		return errors.Errorf("repo UpdateMany method of table \"%s\" failed: primary key is required", tableName)
+	}
+
+	fields := make([]*schema.Field, 0, len(columns))
+	for _, column := range columns {
+		field := sch.LookUpField(column)
+		if field == nil {
+			return errors.Errorf("repo UpdateMany method of table \"%s\" failed: unknown column \"%s\"", tableName, column)
+		}
+		fields = append(fields, field)
+	}
+
+	for start := 0; start < len(models); start += repo.batchSize {
+		batch := models[start:min(start+repo.batchSize, len(models))]
+
+		ids := make([]any, 0, len(batch))
+		values := make([]reflect.Value, 0, len(batch))
+		for _, model := range batch {
+			value := reflect.ValueOf(model)
+			id, zero := primary.ValueOf(ctx, value)
+			if zero {
+				return errors.Wrapf(db.ErrMissingWhereClause, "repo UpdateMany method of table \"%s\" failed: primary key is empty", tableName)
+			}
+			ids = append(ids, id)
+			values = append(values, value)
+		}
+
+		attributes := make(Columns, len(fields))
+		for _, field := range fields {
+			var sql strings.Builder
+			args := make([]any, 0, len(batch)*2+2)
+			sql.WriteString("CASE ?")
+			args = append(args, clause.Column{Name: primary.DBName})
+			for i, value := range values {
+				v, _ := field.ValueOf(ctx, value)
+				sql.WriteString(" WHEN ? THEN ?")
+				args = append(args, ids[i], v)
+			}
+			// ELSE使用原字段：PgSQL的CASE只有参数时结果类型为text，无法赋值给其它类型的字段，加上原字段后会推导为字段的类型
+			sql.WriteString(" ELSE ? END")
+			args = append(args, clause.Column{Name: field.DBName})
+			attributes[field.DBName] = db.Expr(sql.String(), args...)
+		}
+		// 乐观锁：递增version（不校验版本）
+		if versionField := repo.getVersionField(); versionField != nil {
+			if _, ok := attributes[versionField.DBName]; !ok {
+				attributes[versionField.DBName] = db.Expr("? + 1", clause.Column{Name: versionField.DBName})
+			}
+		}
+
+		query := cnd.NewQueryBuilder().In(primary.DBName, ids)
+		orm := repo.GetDB(ctx).Model(repo.modelCreator())
+		if err = query.Build(orm).Updates(map[string]any(attributes)).Error; err != nil {
+			return errors.Wrapf(err, "repo UpdateMany method of table \"%s\" failed", tableName)
+		}
+
+		if err = repo.fireBatchEvent(ctx, orm, event.BatchUpdated, &BatchUpdatedPayload{Query: query, Columns: columns}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
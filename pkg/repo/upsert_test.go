@@ -0,0 +1,92 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo/repotest"
+)
+
+type account struct {
+	ID    int64  `gorm:"primaryKey"`
+	Email string `gorm:"uniqueIndex"`
+	Name  string
+	Score int
+}
+
+func (account) TableName() string {
+	return "accounts"
+}
+
+func newAccountRepo(t *testing.T) *repo.Repository[*account] {
+	orm := repotest.NewDB(t, &account{})
+	return repotest.NewRepository(t, orm, func() *account { return &account{} })
+}
+
+func TestUpsert(t *testing.T) {
+	accounts := newAccountRepo(t)
+	ctx := context.Background()
+
+	if err := accounts.Create(ctx, &account{Email: "tom@a.com", Name: "tom", Score: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 冲突时只更新name
+	err := accounts.Upsert(ctx, []*account{
+		{Email: "tom@a.com", Name: "tommy", Score: 10},
+		{Email: "jerry@a.com", Name: "jerry", Score: 20},
+	}, []string{"email"}, []string{"name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := accounts.Get(ctx, cnd.NewQueryBuilder().Asc("email"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(models) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(models))
+	}
+	if jerry := models[0]; jerry.Name != "jerry" || jerry.Score != 20 {
+		t.Fatalf("expected jerry to be inserted, got %+v", jerry)
+	}
+	if tom := models[1]; tom.Name != "tommy" || tom.Score != 1 {
+		t.Fatalf("expected only the name of tom to be updated, got %+v", tom)
+	}
+}
+
+func TestUpdateMany(t *testing.T) {
+	accounts := newAccountRepo(t)
+	ctx := context.Background()
+
+	models := []*account{
+		{Email: "a@a.com", Name: "a", Score: 1},
+		{Email: "b@a.com", Name: "b", Score: 2},
+		{Email: "c@a.com", Name: "c", Score: 3},
+	}
+	if err := accounts.Create(ctx, models...); err != nil {
+		t.Fatal(err)
+	}
+
+	// 零值也会更新，没有传入的记录不受影响
+	models[0].Score, models[0].Name = 0, "x"
+	models[1].Score = 20
+	if err := accounts.UpdateMany(ctx, models[:2], "score"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int64]account{
+		models[0].ID: {Name: "a", Score: 0},
+		models[1].ID: {Name: "b", Score: 20},
+		models[2].ID: {Name: "c", Score: 3},
+	}
+	for id, want := range expected {
+		got, err := accounts.FindOrFail(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		} else if got.Name != want.Name || got.Score != want.Score {
+			t.Fatalf("expected account %d to be %+v, got %+v", id, want, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+// getVersionField 获取乐观锁字段（数据库字段名为version的整数字段），model没有实现db.IVersionedModel或字段不存在时返回nil
+func (repo *Repository[T]) getVersionField() *schema.Field {
+	if _, ok := any(repo.modelCreator()).(db.IVersionedModel); !ok {
+		return nil
+	}
+	sch, err := repo.getSchema()
+	if err != nil {
+		return nil
+	}
+	field := sch.LookUpField(db.VersionColumn)
+	if field == nil {
+		return nil
+	}
+	switch field.FieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field
+	}
+	return nil
+}
+
+// updateVersioned 带乐观锁更新model：WHERE version = 当前版本，并将版本+1。没有更新到记录时返回ErrStaleModel，并还原model的版本
+// all为true时更新所有字段（Save的行为），否则只更新非零值字段（Update的行为）
+func (repo *Repository[T]) updateVersioned(ctx context.Context, model T, field *schema.Field, all bool) error {
+	value := reflect.ValueOf(model)
+	current, _ := field.ValueOf(ctx, value)
+	version := reflect.ValueOf(current).Int()
+	if err := field.Set(ctx, value, version+1); err != nil {
+		return err
+	}
+
+	orm := repo.GetDB(ctx).Model(model).
+		Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: version})
+	if all {
+		orm = orm.Select("*")
+	}
+
+	result := orm.Updates(model)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = errors.Wrapf(db.ErrStaleModel, "repo update of table \"%s\" with version %d failed", repo.modelCreator().TableName(), version)
+	}
+	if result.Error != nil {
+		_ = field.Set(ctx, value, version)
+		return result.Error
+	}
+	return nil
+}
+
+// RetryOnConflict 执行fn，如果返回ErrStaleModel（乐观锁冲突），则重试，最多执行attempts次。
+// fn中需要重新读取记录再修改，否则重试依然会冲突
+// example:
+//
+//	err := repo.RetryOnConflict(ctx, 3, func(ctx context.Context) error {
+//		user, err := userRepo.FindOrFail(ctx, id)
+//		if err != nil {
+//			return err
+//		}
+//		user.Score += 10
+//		return userRepo.Update(ctx, user)
+//	})
+func RetryOnConflict(ctx context.Context, attempts int, fn func(ctx context.Context) error) error {
+	var err error
+	for i := 0; i < max(attempts, 1); i++ {
+		if err = fn(ctx); err == nil || !errors.Is(err, db.ErrStaleModel) {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package repo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/db/cnd"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/repo/repotest"
+)
+
+type wallet struct {
+	db.VersionedModel
+	Balance int
+}
+
+func (wallet) TableName() string {
+	return "wallets"
+}
+
+// counter 有version字段，但没有实现db.IVersionedModel，不启用乐观锁
+type counter struct {
+	ID      int64 `gorm:"primaryKey"`
+	Version int64
+	Value   int
+}
+
+func (counter) TableName() string {
+	return "counters"
+}
+
+func TestVersionedUpdate(t *testing.T) {
+	orm := repotest.NewDB(t, &wallet{})
+	wallets := repotest.NewRepository(t, orm, func() *wallet { return &wallet{} })
+	ctx := context.Background()
+
+	w := &wallet{Balance: 10}
+	if err := wallets.Create(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := wallets.FindOrFail(ctx, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Balance = 20
+	if err = wallets.Update(ctx, w); err != nil {
+		t.Fatal(err)
+	} else if w.Version != 1 {
+		t.Fatalf("expected version 1, got %d", w.Version)
+	}
+
+	stale.Balance = 30
+	if err = wallets.Update(ctx, stale); !errors.Is(err, db.ErrStaleModel) {
+		t.Fatalf("expected ErrStaleModel, got %v", err)
+	} else if stale.Version != 0 {
+		t.Fatalf("expected the version of the stale model to be restored, got %d", stale.Version)
+	}
+
+	// RetryOnConflict 重新读取后更新成功
+	err = repo.RetryOnConflict(ctx, 3, func(ctx context.Context) error {
+		latest, err := wallets.FindOrFail(ctx, w.ID)
+		if err != nil {
+			return err
+		}
+		latest.Balance += 5
+		return wallets.Update(ctx, latest)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := wallets.FindOrFail(ctx, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if latest.Balance != 25 || latest.Version != 2 {
+		t.Fatalf("expected balance 25 with version 2, got %d with version %d", latest.Balance, latest.Version)
+	}
+}
+
+func TestVersionedUpdateColumns(t *testing.T) {
+	orm := repotest.NewDB(t, &wallet{})
+	wallets := repotest.NewRepository(t, orm, func() *wallet { return &wallet{} })
+	ctx := context.Background()
+
+	a, b := &wallet{Balance: 1}, &wallet{Balance: 2}
+	if err := wallets.Create(ctx, a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不包含version时不校验、也不修改version
+	if err := wallets.UpdateColumns(ctx, cnd.Eq("id", a.ID), repo.Columns{"balance": 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.UpdateColumns(ctx, cnd.Eq("id", a.ID), repo.Columns{"balance": 20, "version": 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Or条件不能绕过版本校验：a的版本已经是1，不分组时会生成 id = a OR id = b AND version = ?，a也会被更新
+	query := cnd.Eq("id", a.ID).Or("id = ?", b.ID)
+	if err := wallets.UpdateColumns(ctx, query, repo.Columns{"balance": 100, "version": 5}); !errors.Is(err, db.ErrStaleModel) {
+		t.Fatalf("expected ErrStaleModel, got %v", err)
+	}
+	if err := wallets.UpdateColumns(ctx, query, repo.Columns{"balance": 100, "version": 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := wallets.Get(ctx, cnd.NewQueryBuilder().Asc("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models[0].Balance != 20 || models[0].Version != 1 {
+		t.Fatalf("expected wallet a to be balance 20 with version 1, got %+v", models[0])
+	}
+	if models[1].Balance != 100 || models[1].Version != 1 {
+		t.Fatalf("expected wallet b to be balance 100 with version 1, got %+v", models[1])
+	}
+}
+
+func TestUnversionedModel(t *testing.T) {
+	orm := repotest.NewDB(t, &counter{})
+	counters := repotest.NewRepository(t, orm, func() *counter { return &counter{} })
+	ctx := context.Background()
+
+	c := &counter{Version: 5, Value: 1}
+	if err := counters.Create(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	// version只是普通字段，按传入的值更新
+	if err := counters.UpdateColumns(ctx, cnd.Eq("id", c.ID), repo.Columns{"value": 2, "version": 3}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := counters.FindOrFail(ctx, c.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if got.Value != 2 || got.Version != 3 {
+		t.Fatalf("expected value 2 with version 3, got %+v", got)
+	}
+}
+
+func TestVersionedUpdateMany(t *testing.T) {
+	orm := repotest.NewDB(t, &wallet{})
+	wallets := repotest.NewRepository(t, orm, func() *wallet { return &wallet{} })
+	ctx := context.Background()
+
+	a, b := &wallet{Balance: 1}, &wallet{Balance: 2}
+	if err := wallets.Create(ctx, a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// UpdateMany 不校验版本，但会递增version
+	a.Balance, b.Balance = 10, 20
+	if err := wallets.UpdateMany(ctx, []*wallet{a, b}, "balance"); err != nil {
+		t.Fatal(err)
+	}
+	models, err := wallets.Get(ctx, cnd.NewQueryBuilder().Asc("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []int{10, 20} {
+		if models[i].Balance != expected || models[i].Version != 1 {
+			t.Fatalf("expected balance %d with version 1, got %+v", expected, models[i])
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package job
+
+import (
+	"context"
+	"sync"
+)
+
+type nameKey struct{}
+
+// NewContext 将job的名称写入ctx，Logging、Metrics、SkipIfStillRunning等wrapper会使用该名称
+func NewContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, nameKey{}, name)
+}
+
+// NameFromContext 从ctx获取job的名称，没有时返回空字符串
+func NameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(nameKey{}).(string)
+	return name
+}
+
+// Named 设置job的名称，需要在其它wrapper之前（外层）执行
+// example: w.Wrap(job.Named("reindex")).Submit(func(ctx){...})
+func Named(name string) JobWrapper {
+	return func(j Job) Job {
+		return func(ctx context.Context) {
+			j(NewContext(ctx, name))
+		}
+	}
+}
+
+type errorKey struct{}
+
+type errorHolder struct {
+	mu  sync.Mutex
+	err error
+}
+
+// NewErrorContext 返回可以使用ReportError报告错误的ctx，以及获取最后一次报告的错误的函数
+func NewErrorContext(ctx context.Context) (context.Context, func() error) {
+	holder := &errorHolder{}
+	return context.WithValue(ctx, errorKey{}, holder), func() error {
+		holder.mu.Lock()
+		defer holder.mu.Unlock()
+		return holder.err
+	}
+}
+
+// ReportError 报告job执行的错误（Job没有返回值），比如cron任务会记录为最后一次的错误
+func ReportError(ctx context.Context, err error) {
+	if holder, ok := ctx.Value(errorKey{}).(*errorHolder); ok {
+		holder.mu.Lock()
+		defer holder.mu.Unlock()
+		holder.err = err
+	}
+}
@@ -0,0 +1,156 @@
+package job
+
+import (
+	"context"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/metrics"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/requestid"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// anonymous 没有使用Named设置名称的job
+const anonymous = "anonymous"
+
+// nameOf 获取job的名称，没有时返回anonymous
+func nameOf(ctx context.Context) string {
+	if name := NameFromContext(ctx); name != "" {
+		return name
+	}
+	return anonymous
+}
+
+// Recover 捕获job的panic，并记录堆栈，避免panic导致进程退出
+func Recover(logger log.Logger) JobWrapper {
+	helper := log.NewModuleHelper(logger, "job")
+	return func(j Job) Job {
+		return func(ctx context.Context) {
+			defer func() {
+				if r := recover(); r != nil {
+					helper.WithContext(ctx).Errorf("[Job]job \"%s\" panic: %v\n%s", nameOf(ctx), r, debug.Stack())
+				}
+			}()
+			j(ctx)
+		}
+	}
+}
+
+// Logging 记录job的开始、结束以及耗时
+func Logging(logger log.Logger) JobWrapper {
+	helper := log.NewModuleHelper(logger, "job")
+	return func(j Job) Job {
+		return func(ctx context.Context) {
+			name := nameOf(ctx)
+			helper.WithContext(ctx).Infof("[Job]job \"%s\" started", name)
+			start := time.Now()
+			defer func() {
+				helper.WithContext(ctx).Infof("[Job]job \"%s\" finished, duration: %s", name, time.Since(start))
+			}()
+			j(ctx)
+		}
+	}
+}
+
+// Metrics 采集job的Prometheus指标：
+//   - job_duration_sec{name}：执行耗时直方图
+//   - job_running{name}：执行中的数量
+//   - job_panics_total{name}：panic数（panic会继续向外抛出，需要配合外层的Recover）
+func Metrics(reg *metrics.Metrics) JobWrapper {
+	reg = reg.WithSubsystem("job")
+	duration := reg.WithHelp("job execution duration(sec).").
+		RegisterHistogramVec("duration_sec", []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}, "name")
+	running := reg.WithHelp("The number of running jobs").
+		RegisterGaugeVec("running", "name")
+	panics := reg.WithHelp("The total number of panicked jobs").
+		RegisterCounterVec("panics_total", "name")
+
+	return func(j Job) Job {
+		return func(ctx context.Context) {
+			name := nameOf(ctx)
+			running.WithLabelValues(name).Inc()
+			start := time.Now()
+			defer func() {
+				running.WithLabelValues(name).Dec()
+				duration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+				if r := recover(); r != nil {
+					panics.WithLabelValues(name).Inc()
+					panic(r)
+				}
+			}()
+			j(ctx)
+		}
+	}
+}
+
+// SkipIfStillRunning 同名的job（Named）还在执行时，跳过本次执行。
+// 状态保存在返回的wrapper中，多次Submit需要使用同一个wrapper
+// example:
+//
+//	skip := job.SkipIfStillRunning(logger)
+//	w.Wrap(job.Named("reindex"), skip).Submit(func(ctx){...})
+func SkipIfStillRunning(logger log.Logger) JobWrapper {
+	helper := log.NewModuleHelper(logger, "job")
+	var running sync.Map
+	return func(j Job) Job {
+		return func(ctx context.Context) {
+			name := nameOf(ctx)
+			if _, loaded := running.LoadOrStore(name, struct{}{}); loaded {
+				helper.WithContext(ctx).Infof("[Job]job \"%s\" is still running, skip", name)
+				return
+			}
+			defer running.Delete(name)
+			j(ctx)
+		}
+	}
+}
+
+// DelayIfStillRunning 同名的job（Named）还在执行时，等待其执行完毕后再执行（串行执行）。
+// 状态保存在返回的wrapper中，多次Submit需要使用同一个wrapper
+func DelayIfStillRunning(logger log.Logger) JobWrapper {
+	helper := log.NewModuleHelper(logger, "job")
+	var mutexes sync.Map
+	return func(j Job) Job {
+		return func(ctx context.Context) {
+			name := nameOf(ctx)
+			mu, _ := mutexes.LoadOrStore(name, &sync.Mutex{})
+			start := time.Now()
+			mu.(*sync.Mutex).Lock()
+			defer mu.(*sync.Mutex).Unlock()
+			if delay := time.Since(start); delay > time.Minute {
+				helper.WithContext(ctx).Infof("[Job]job \"%s\" delayed %s", name, delay)
+			}
+			j(ctx)
+		}
+	}
+}
+
+// RequestID 保证job的ctx中有request id：提交job时的ctx中已有则沿用，否则生成新的，便于关联job的日志
+func RequestID() JobWrapper {
+	return func(j Job) Job {
+		return func(ctx context.Context) {
+			if requestid.FromContext(ctx) == "" {
+				ctx = requestid.NewContext(ctx, requestid.GenerateRequestId())
+			}
+			j(ctx)
+		}
+	}
+}
+
+// LimitConcurrency 按key限制job的并发数，超过limit时等待。key为nil时使用job的名称（Named）
+// 状态保存在返回的wrapper中，多次Submit需要使用同一个wrapper
+// example: job.LimitConcurrency(2, func(ctx context.Context) string { id, _ := tenant.FromContext(ctx); return fmt.Sprint(id) })
+func LimitConcurrency(limit int, key func(ctx context.Context) string) JobWrapper {
+	if key == nil {
+		key = nameOf
+	}
+	var semaphores sync.Map
+	return func(j Job) Job {
+		return func(ctx context.Context) {
+			sem, _ := semaphores.LoadOrStore(key(ctx), make(chan struct{}, max(limit, 1)))
+			sem.(chan struct{}) <- struct{}{}
+			defer func() { <-sem.(chan struct{}) }()
+			j(ctx)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultParser 与worker相同的解析器，秒是可选的：[秒] 分 时 日 月 周
+var DefaultParser = cron.NewParser(SecondOptional | Minute | Hour | Dom | Month | Dow | Descriptor)
+
+// maxSearch 查找下次执行时间的最大范围，超过则认为永远不会执行（与cron.SpecSchedule一致）
+const maxSearch = 5 * 365 * 24 * time.Hour
+
+// Filter 返回true表示该时间点可以执行
+type Filter func(t time.Time) bool
+
+// calendarSchedule 在cron.Schedule的基础上，按时区过滤执行时间，并增加固定的随机延迟
+type calendarSchedule struct {
+	schedule cron.Schedule
+	location *time.Location
+	filters  []Filter
+	// jitter 最大的随机延迟，每个执行时间点的延迟根据seed计算，集群中的节点是一致的
+	jitter time.Duration
+	seed   uint64
+	desc   string
+}
+
+var _ cron.Schedule = (*calendarSchedule)(nil)
+
+// Next 返回t之后第一个满足所有filter的执行时间（加上随机延迟）
+func (s *calendarSchedule) Next(t time.Time) time.Time {
+	// 上一个执行时间点的延迟可能还没到，所以从t - jitter开始查找
+	next := t.Add(-s.jitter)
+	for limit := t.Add(maxSearch); ; {
+		next = s.schedule.Next(next)
+		if next.IsZero() || next.After(limit) {
+			return time.Time{}
+		}
+		if !s.accept(next) {
+			continue
+		}
+		if at := next.Add(s.delay(next)); at.After(t) {
+			return at
+		}
+	}
+}
+
+func (s *calendarSchedule) accept(t time.Time) bool {
+	t = t.In(s.location)
+	for _, filter := range s.filters {
+		if !filter(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// delay 执行时间点的随机延迟，相同的seed和时间点得到相同的延迟
+func (s *calendarSchedule) delay(t time.Time) time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%d:%d", s.seed, t.Unix())
+	return time.Duration(h.Sum64() % uint64(s.jitter)).Truncate(time.Millisecond)
+}
+
+func (s *calendarSchedule) String() string {
+	return s.desc
+}
+
+// newSeed 根据字符串计算seed
+func newSeed(str string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(str))
+	return h.Sum64()
+}
+
+// withTimezone 给cron表达式加上时区，已经设置了时区的表达式不修改
+func withTimezone(expr string, loc *time.Location) string {
+	if loc == nil || strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "@every ") {
+		return expr
+	}
+	return "CRON_TZ=" + loc.String() + " " + expr
+}
+
+// parseClock 解析"时:分[:秒]"，返回当天的秒数
+func parseClock(clock string) (int, error) {
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, errors.Errorf("invalid clock \"%s\", must be HH:MM or HH:MM:SS", clock)
+	}
+	limits := []int{24, 60, 60}
+	var seconds int
+	for i, limit := range limits {
+		var n int
+		if i < len(parts) {
+			var err error
+			if n, err = strconv.Atoi(parts[i]); err != nil || n < 0 || n >= limit {
+				return 0, errors.Errorf("invalid clock \"%s\", must be HH:MM or HH:MM:SS", clock)
+			}
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, nil
+}
+
+// secondsOfDay 返回t是当天的第几秒
+func secondsOfDay(t time.Time) int {
+	return t.Hour()*3600 + t.Minute()*60 + t.Second()
+}
+
+// WeekdaysFilter 周一至周五
+func WeekdaysFilter(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// WeekendsFilter 周六、周日
+func WeekendsFilter(t time.Time) bool {
+	return !WeekdaysFilter(t)
+}
+
+// BetweenFilter 每天的start至end之间（包含），start大于end时表示跨越午夜，比如BetweenFilter("22:00", "06:00")
+func BetweenFilter(start, end string) (Filter, error) {
+	from, err := parseClock(start)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseClock(end)
+	if err != nil {
+		return nil, err
+	}
+	return func(t time.Time) bool {
+		sec := secondsOfDay(t)
+		if from <= to {
+			return sec >= from && sec <= to
+		}
+		return sec >= from || sec <= to
+	}, nil
+}
@@ -2,9 +2,13 @@ package schedule
 
 import (
 	"fmt"
+	"github.com/pkg/errors"
 	"github.com/robfig/cron/v3"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/job"
 	"math"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
 	"time"
 )
@@ -31,6 +35,13 @@ type Spec interface {
 	DailyAt(t string) (cron.EntryID, error)
 	Weekly() (cron.EntryID, error)
 	Monthly() (cron.EntryID, error)
+
+	InTimezone(loc *time.Location) Spec
+	Weekdays() Spec
+	Weekends() Spec
+	Between(start, end string) Spec
+	Skip(skip func(t time.Time) bool) Spec
+	Jitter(maxDelay time.Duration) Spec
 }
 
 const (
@@ -71,6 +82,14 @@ type cronCaller func(spec any, job job.Job) (cron.EntryID, error)
 type spec struct {
 	job    job.Job
 	runner cronCaller
+
+	// location 计算执行时间使用的时区，nil表示使用time.Local
+	location *time.Location
+	filters  []Filter
+	// modifiers filter、jitter的描述
+	modifiers []string
+	jitter    time.Duration
+	err       error
 }
 
 // NewSpec 实例化cron表达式
@@ -81,15 +100,123 @@ func NewSpec(runner cronCaller, job job.Job) *spec {
 	}
 }
 
-// Cron 自定义cron表达式运行job
+// Cron 自定义cron表达式运行job，会应用InTimezone、Weekdays、Between、Skip、Jitter等设置
 // 支持表达式：https://pkg.go.dev/github.com/robfig/cron/v3#hdr-Special_Characters
 func (s spec) Cron(spec any) (cron.EntryID, error) {
-	return s.runner(spec, s.job)
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	// 没有filter、jitter时，字符串表达式直接交给worker解析（只需要加上时区）
+	expr, isString := spec.(string)
+	if isString && len(s.filters) == 0 && s.jitter <= 0 {
+		return s.runner(withTimezone(expr, s.location), s.job)
+	}
+
+	schedule, desc, err := s.parse(spec)
+	if err != nil {
+		return 0, err
+	}
+	if len(s.filters) == 0 && s.jitter <= 0 {
+		return s.runner(schedule, s.job)
+	}
+
+	location := s.location
+	if location == nil {
+		location = time.Local
+	}
+	modifiers := s.modifiers
+	if !isString && s.location != nil {
+		modifiers = append([]string{s.location.String()}, modifiers...)
+	}
+	desc += " (" + strings.Join(modifiers, ", ") + ")"
+	return s.runner(&calendarSchedule{
+		schedule: schedule,
+		location: location,
+		filters:  s.filters,
+		jitter:   s.jitter,
+		// 集群中的节点使用相同的seed，保证每次执行的延迟相同（OnceForCluster依赖于一致的执行时间）
+		seed: newSeed(desc + runtime.FuncForPC(reflect.ValueOf(s.job).Pointer()).Name()),
+		desc: desc,
+	}, s.job)
+}
+
+// parse 将表达式转换为cron.Schedule（应用时区），并返回其描述
+func (s spec) parse(spec any) (cron.Schedule, string, error) {
+	switch expr := spec.(type) {
+	case string:
+		expr = withTimezone(expr, s.location)
+		schedule, err := DefaultParser.Parse(expr)
+		return schedule, expr, err
+	case *cron.SpecSchedule:
+		if s.location != nil {
+			// 复制一份，不修改传入的schedule
+			_expr := *expr
+			_expr.Location = s.location
+			expr = &_expr
+		}
+		return expr, fmt.Sprintf("%T", expr), nil
+	case cron.ConstantDelaySchedule:
+		return expr, "@every " + expr.Delay.String(), nil
+	case cron.Schedule:
+		return expr, fmt.Sprintf("%T", expr), nil
+	default:
+		return nil, "", errors.Errorf("不支持此类型的解析表达式, %v", spec)
+	}
+}
+
+// InTimezone 在loc时区计算执行时间，比如：InTimezone(time.UTC).DailyAt("08:00") 表示UTC的8点
+// 同时也是Weekdays、Between、Skip判断时使用的时区
+func (s spec) InTimezone(loc *time.Location) Spec {
+	s.location = loc
+	return &s
 }
 
-// Every 传入time.Duration运行job，精度为秒
+// Weekdays 只在周一至周五运行，比如：Weekdays().DailyAt("09:00")
+func (s spec) Weekdays() Spec {
+	return s.filter("weekdays", WeekdaysFilter)
+}
+
+// Weekends 只在周六、周日运行
+func (s spec) Weekends() Spec {
+	return s.filter("weekends", WeekendsFilter)
+}
+
+// Between 只在每天的start至end之间（包含）运行，格式为"HH:MM"或"HH:MM:SS"，
+// start大于end时表示跨越午夜，比如：Between("22:00", "06:00").EveryTenMinutes()
+func (s spec) Between(start, end string) Spec {
+	filter, err := BetweenFilter(start, end)
+	if err != nil {
+		s.err = err
+		return &s
+	}
+	return s.filter("between "+start+"-"+end, filter)
+}
+
+// Skip 跳过skip返回true的执行时间，比如节假日：Skip(holidays.Contains).DailyAt("09:00")
+func (s spec) Skip(skip func(t time.Time) bool) Spec {
+	return s.filter("skip", func(t time.Time) bool {
+		return !skip(t)
+	})
+}
+
+// Jitter 每次执行时随机延迟[0, maxDelay)，用于分散集群中同一时间点执行的任务。
+// 延迟根据任务和执行时间点计算，所以所有节点的延迟是相同的。maxDelay需要小于执行间隔
+func (s spec) Jitter(maxDelay time.Duration) Spec {
+	s.jitter = maxDelay
+	s.modifiers = append(slices.Clone(s.modifiers), "jitter "+maxDelay.String())
+	return &s
+}
+
+func (s spec) filter(desc string, filter Filter) Spec {
+	s.filters = append(slices.Clone(s.filters), filter)
+	s.modifiers = append(slices.Clone(s.modifiers), desc)
+	return &s
+}
+
+// Every 传入time.Duration运行job，精度为秒，会应用Weekdays、Between、Skip、Jitter等设置
 func (s spec) Every(duration time.Duration) (cron.EntryID, error) {
-	return s.runner(cron.Every(duration), s.job)
+	return s.Cron(cron.Every(duration))
 }
 
 // EverySeconds 每秒运行job
@@ -167,8 +294,12 @@ func (s spec) Hourly() (cron.EntryID, error) {
 }
 
 // HourlyAt 每小时的某分钟运行job
+// HourlyAt(15) 每小时的15分运行
 func (s spec) HourlyAt(offset int) (cron.EntryID, error) {
-	return s.Cron(fmt.Sprintf("@every 1h%dm", offset))
+	if offset < 0 || offset > 59 {
+		return 0, errors.Errorf("invalid minute %d, must be 0-59", offset)
+	}
+	return s.Cron(fmt.Sprintf("0 %d * * * *", offset))
 }
 
 // Daily 每天运行job
@@ -177,13 +308,16 @@ func (s spec) Daily() (cron.EntryID, error) {
 }
 
 // DailyAt 每天某时某分运行job
-// DailyAt("12:21") 每天12点21分钟运行
+// DailyAt("12:21") 每天12点21分钟运行，DailyAt("12") 每天12点运行，DailyAt("12:21:30") 每天12点21分30秒运行
 func (s spec) DailyAt(t string) (cron.EntryID, error) {
-	tt := strings.Split(t, ":")
-	if len(tt) < 2 {
-		tt = append(tt, "00")
+	if !strings.Contains(t, ":") {
+		t += ":00"
+	}
+	seconds, err := parseClock(t)
+	if err != nil {
+		return 0, err
 	}
-	return s.Cron(fmt.Sprintf("%s %s * * *", tt[0], tt[1]))
+	return s.Cron(fmt.Sprintf("%d %d %d * * *", seconds%60, seconds/60%60, seconds/3600))
 }
 
 // Weekly 每周运行job
@@ -0,0 +1,357 @@
+package worker
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"strconv"
+	"time"
+)
+
+// ErrBatchNotFound 批次不存在（或者已经超过保留时间）
+var ErrBatchNotFound = errors.New("batch not found")
+
+// PendingJob 待分发的任务，用于Batch、Chain
+type PendingJob struct {
+	Name    string
+	Payload any
+}
+
+// NewJob 返回待分发的任务，name需要通过Register注册
+func NewJob(name string, payload any) PendingJob {
+	return PendingJob{Name: name, Payload: payload}
+}
+
+// Batch 批次的信息和进度
+type Batch struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Total         int    `json:"total"`
+	Pending       int    `json:"pending"`
+	Succeeded     int    `json:"succeeded"`
+	Failed        int    `json:"failed"`
+	Cancelled     int    `json:"cancelled"`
+	AllowFailures bool   `json:"allow_failures"`
+
+	CreatedAt   time.Time `json:"created_at"`
+	CancelledAt time.Time `json:"cancelled_at,omitempty"`
+	FinishedAt  time.Time `json:"finished_at,omitempty"`
+}
+
+// Progress 已完成（成功、失败、取消）的任务的百分比
+func (b *Batch) Progress() int {
+	if b.Total == 0 {
+		return 100
+	}
+	return (b.Total - b.Pending) * 100 / b.Total
+}
+
+// Finished 所有任务都已完成
+func (b *Batch) Finished() bool {
+	return !b.FinishedAt.IsZero()
+}
+
+// IsCancelled 批次已取消
+func (b *Batch) IsCancelled() bool {
+	return !b.CancelledAt.IsZero()
+}
+
+type batchJobResult string
+
+const (
+	batchJobSucceeded batchJobResult = "succeeded"
+	batchJobFailed    batchJobResult = "failed"
+	batchJobCancelled batchJobResult = "cancelled"
+)
+
+// batchRecordScript 记录批次中一个任务的结果，不允许失败的批次在第一个任务失败时取消。
+// 任务ID记录在集合中，同一个任务只记录一次（可见性超时后重复执行、ack失败后再次执行的任务不会重复计数）。
+// 所有任务完成时记录完成时间，并设置过期时间为ttl，否则将过期时间延长为idle_ttl
+//
+//	KEYS: batch, recorded
+//	ARGV: job_id, result, now(ms), ttl(ms), idle_ttl(ms)
+//	返回: {pending, failed, cancelled(0/1)}，批次不存在时pending为-1，任务已经记录过时pending为-2
+const batchRecordScript = `if redis.call('exists', KEYS[1]) == 0 then
+	return {-1, 0, 0}
+end
+if redis.call('sadd', KEYS[2], ARGV[1]) == 0 then
+	return {-2, 0, 0}
+end
+redis.call('hincrby', KEYS[1], ARGV[2], 1)
+local pending = redis.call('hincrby', KEYS[1], 'pending', -1)
+local failed = tonumber(redis.call('hget', KEYS[1], 'failed') or '0')
+if ARGV[2] == 'failed' and redis.call('hget', KEYS[1], 'allow_failures') ~= '1' then
+	redis.call('hsetnx', KEYS[1], 'cancelled_at', ARGV[3])
+end
+local ttl = ARGV[5]
+if pending == 0 then
+	redis.call('hset', KEYS[1], 'finished_at', ARGV[3])
+	ttl = ARGV[4]
+end
+redis.call('pexpire', KEYS[1], ttl)
+redis.call('pexpire', KEYS[2], ttl)
+local cancelled = 0
+if redis.call('hexists', KEYS[1], 'cancelled_at') == 1 then
+	cancelled = 1
+end
+return {pending, failed, cancelled}
+`
+
+// batchCancelScript 取消未完成的批次
+//
+//	KEYS: batch
+//	ARGV: now(ms)
+const batchCancelScript = `if redis.call('exists', KEYS[1]) == 0 then
+	return -1
+end
+return redis.call('hsetnx', KEYS[1], 'cancelled_at', ARGV[1])
+`
+
+// BatchBuilder 通过Queue.Batch创建，设置回调后调用Dispatch
+type BatchBuilder struct {
+	queue         *Queue
+	name          string
+	jobs          []PendingJob
+	allowFailures bool
+	then          string
+	catch         string
+	finally       string
+}
+
+// Batch 创建一个批次，批次中的任务并发执行，全部完成后执行回调。
+// 回调是通过Register注册的任务（payload为*Batch），这样批次的状态保存在redis中，节点重启后可以继续执行。
+//
+//	worker.Register(queue, "import:done", func(ctx context.Context, b *worker.Batch) error {...})
+//	batch, err := queue.Batch(jobs...).Name("import").Then("import:done").Catch("import:failed").Dispatch(ctx)
+func (q *Queue) Batch(jobs ...PendingJob) *BatchBuilder {
+	return &BatchBuilder{queue: q, jobs: jobs}
+}
+
+// Name 设置批次的名称，用于展示
+func (b *BatchBuilder) Name(name string) *BatchBuilder {
+	b.name = name
+	return b
+}
+
+// Add 添加任务
+func (b *BatchBuilder) Add(jobs ...PendingJob) *BatchBuilder {
+	b.jobs = append(b.jobs, jobs...)
+	return b
+}
+
+// AllowFailures 任务失败时不取消批次（默认第一个任务失败后，取消批次中其余未执行的任务）
+func (b *BatchBuilder) AllowFailures() *BatchBuilder {
+	b.allowFailures = true
+	return b
+}
+
+// Then 所有任务都执行成功（批次没有取消）后，分发名为callback的任务
+func (b *BatchBuilder) Then(callback string) *BatchBuilder {
+	b.then = callback
+	return b
+}
+
+// Catch 第一个任务失败时，分发名为callback的任务
+func (b *BatchBuilder) Catch(callback string) *BatchBuilder {
+	b.catch = callback
+	return b
+}
+
+// Finally 所有任务都完成（不论成功、失败、取消）后，分发名为callback的任务
+func (b *BatchBuilder) Finally(callback string) *BatchBuilder {
+	b.finally = callback
+	return b
+}
+
+// Dispatch 保存批次，并将所有任务写入队列
+func (b *BatchBuilder) Dispatch(ctx context.Context) (*Batch, error) {
+	q := b.queue
+
+	// 先编码所有payload，避免写入部分任务后才发现错误
+	jobs := make([]*QueuedJob, 0, len(b.jobs))
+	for _, pending := range b.jobs {
+		data, err := q.marshalPayload(pending.Name, pending.Payload)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, q.newJob(ctx, pending.Name, data))
+	}
+
+	batch := &Batch{
+		ID:            uuid.New().String(),
+		Name:          b.name,
+		Total:         len(jobs),
+		Pending:       len(jobs),
+		AllowFailures: b.allowFailures,
+		CreatedAt:     time.Now(),
+	}
+	allowFailures := "0"
+	if b.allowFailures {
+		allowFailures = "1"
+	}
+	if _, err := q.cache.HMSet(ctx, q.batchKey(batch.ID), map[string]any{
+		"id":             batch.ID,
+		"name":           batch.Name,
+		"total":          batch.Total,
+		"pending":        batch.Pending,
+		"succeeded":      0,
+		"failed":         0,
+		"cancelled":      0,
+		"allow_failures": allowFailures,
+		"then":           b.then,
+		"catch":          b.catch,
+		"finally":        b.finally,
+		"created_at":     batch.CreatedAt.UnixMilli(),
+	}); err != nil {
+		return nil, errors.Wrapf(err, "save batch \"%s\" of queue \"%s\" failed", batch.Name, q.name)
+	}
+
+	if len(jobs) == 0 {
+		// 没有任务，直接完成
+		batch.FinishedAt = time.Now()
+		if _, err := q.cache.HSet(ctx, q.batchKey(batch.ID), "finished_at", batch.FinishedAt.UnixMilli()); err != nil {
+			return nil, err
+		}
+		if _, err := q.cache.PExpire(ctx, q.batchKey(batch.ID), q.batchTTL()); err != nil {
+			return nil, err
+		}
+		q.finishBatch(ctx, batch.ID, b.then, b.finally, true)
+		return batch, nil
+	}
+	// 未完成的批次在batchIdleTTL内没有任务完成时过期，避免节点异常导致批次永远不会完成而一直保留
+	if _, err := q.cache.PExpire(ctx, q.batchKey(batch.ID), batchIdleTTL); err != nil {
+		return nil, errors.Wrapf(err, "save batch \"%s\" of queue \"%s\" failed", batch.Name, q.name)
+	}
+
+	for _, job := range jobs {
+		job.BatchID = batch.ID
+		if err := q.dispatch(ctx, job); err != nil {
+			// 已经写入的任务会继续执行，取消批次使其跳过
+			_ = q.CancelBatch(ctx, batch.ID)
+			return nil, errors.Wrapf(err, "dispatch batch \"%s\" of queue \"%s\" failed", batch.Name, q.name)
+		}
+	}
+	return batch, nil
+}
+
+// batchIdleTTL 未完成的批次的保留时间，每个任务完成时重新计算
+const batchIdleTTL = 7 * 24 * time.Hour
+
+// batchKey 批次的redis key
+func (q *Queue) batchKey(id string) string {
+	return q.key("batch:" + id)
+}
+
+// batchRecordedKey 批次中已经记录了结果的任务ID的集合
+func (q *Queue) batchRecordedKey(id string) string {
+	return q.key("batch:" + id + ":recorded")
+}
+
+// batchTTL 批次完成后的保留时间
+func (q *Queue) batchTTL() time.Duration {
+	if q.statusTTL > 0 {
+		return q.statusTTL
+	}
+	return 24 * time.Hour
+}
+
+// FindBatch 查询批次的信息和进度，不存在时返回ErrBatchNotFound
+func (q *Queue) FindBatch(ctx context.Context, id string) (*Batch, error) {
+	fields, err := q.cache.HGetAll(ctx, q.batchKey(id), nil)
+	if err != nil {
+		return nil, err
+	} else if len(fields) == 0 {
+		return nil, errors.Wrapf(ErrBatchNotFound, "id: %s", id)
+	}
+
+	batch := &Batch{
+		ID:            fields["id"],
+		Name:          fields["name"],
+		AllowFailures: fields["allow_failures"] == "1",
+		CreatedAt:     parseUnixMilli(fields["created_at"]),
+		CancelledAt:   parseUnixMilli(fields["cancelled_at"]),
+		FinishedAt:    parseUnixMilli(fields["finished_at"]),
+	}
+	batch.Total, _ = strconv.Atoi(fields["total"])
+	batch.Pending, _ = strconv.Atoi(fields["pending"])
+	batch.Succeeded, _ = strconv.Atoi(fields["succeeded"])
+	batch.Failed, _ = strconv.Atoi(fields["failed"])
+	batch.Cancelled, _ = strconv.Atoi(fields["cancelled"])
+	return batch, nil
+}
+
+// CancelBatch 取消批次，未执行的任务会被跳过，执行中的任务不受影响
+func (q *Queue) CancelBatch(ctx context.Context, id string) error {
+	res, err := q.cache.Script(batchCancelScript).Run(ctx,
+		[]string{q.batchKey(id)},
+		time.Now().UnixMilli()).Int()
+	if err != nil {
+		return err
+	} else if res < 0 {
+		return errors.Wrapf(ErrBatchNotFound, "id: %s", id)
+	}
+	return nil
+}
+
+// batchCancelled 批次是否已经取消，redis报错时不跳过执行，只记录日志
+func (q *Queue) batchCancelled(ctx context.Context, id string) bool {
+	ok, err := q.cache.HExists(ctx, q.batchKey(id), "cancelled_at")
+	if err != nil {
+		q.logger.WithContext(ctx).Errorf("[Queue]check batch \"%s\" of queue \"%s\" cancelled failed: %v", id, q.name, err)
+		return false
+	}
+	return ok
+}
+
+// recordBatch 记录批次中任务的结果，并按需分发回调
+func (q *Queue) recordBatch(ctx context.Context, job *QueuedJob, result batchJobResult) {
+	logger := q.logger.WithContext(ctx)
+	res, err := q.cache.Script(batchRecordScript).Run(ctx,
+		[]string{q.batchKey(job.BatchID), q.batchRecordedKey(job.BatchID)},
+		job.ID, string(result), time.Now().UnixMilli(), q.batchTTL().Milliseconds(), batchIdleTTL.Milliseconds()).Int64Slice()
+	if err != nil {
+		logger.Errorf("[Queue]record job \"%s\"(%s) of batch \"%s\" failed: %v", job.Name, job.ID, job.BatchID, err)
+		return
+	} else if len(res) != 3 || res[0] == -1 {
+		logger.Warnf("[Queue]batch \"%s\" of job \"%s\"(%s) is not found", job.BatchID, job.Name, job.ID)
+		return
+	} else if res[0] < 0 {
+		logger.Infof("[Queue]job \"%s\"(%s) of batch \"%s\" is already recorded", job.Name, job.ID, job.BatchID)
+		return
+	}
+	pending, failed, cancelled := res[0], res[1], res[2] == 1
+
+	fields, err := q.cache.HMGet(ctx, q.batchKey(job.BatchID), nil, "then", "catch", "finally")
+	if err != nil || len(fields) != 3 {
+		logger.Errorf("[Queue]get callbacks of batch \"%s\" failed: %v", job.BatchID, err)
+		return
+	}
+	// 第一个失败的任务分发catch
+	if result == batchJobFailed && failed == 1 && fields[1] != "" {
+		q.dispatchBatchCallback(ctx, job.BatchID, fields[1])
+	}
+	if pending == 0 {
+		q.finishBatch(ctx, job.BatchID, fields[0], fields[2], failed == 0 && !cancelled)
+	}
+}
+
+// finishBatch 批次完成，succeeded时分发then，并分发finally
+func (q *Queue) finishBatch(ctx context.Context, id, then, finally string, succeeded bool) {
+	if succeeded && then != "" {
+		q.dispatchBatchCallback(ctx, id, then)
+	}
+	if finally != "" {
+		q.dispatchBatchCallback(ctx, id, finally)
+	}
+}
+
+// dispatchBatchCallback 分发批次的回调，payload为批次当前的信息
+func (q *Queue) dispatchBatchCallback(ctx context.Context, id, callback string) {
+	batch, err := q.FindBatch(ctx, id)
+	if err == nil {
+		_, err = q.Dispatch(ctx, callback, batch)
+	}
+	if err != nil {
+		q.logger.WithContext(ctx).Errorf("[Queue]dispatch callback \"%s\" of batch \"%s\" failed: %v", callback, id, err)
+	}
+}
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestBatch(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	Register(q, "part", func(ctx context.Context, payload string) error {
+		if payload == "bad" {
+			return errors.New("bad part")
+		}
+		return nil
+	})
+	callbacks := map[string]int{}
+	for _, name := range []string{"then", "catch", "finally"} {
+		name := name
+		Register(q, name, func(ctx context.Context, b *Batch) error {
+			callbacks[name]++
+			return nil
+		})
+	}
+
+	// 允许失败的批次中有两个任务失败，catch只在第一个任务失败时分发
+	batch, err := q.Batch(NewJob("part", "ok"), NewJob("part", "bad"), NewJob("part", "bad")).
+		AllowFailures().Then("then").Catch("catch").Finally("finally").Dispatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []*QueuedJob
+	for _, job := range drain(t, q) {
+		if job.Name == "part" {
+			parts = append(parts, job)
+		}
+	}
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts are executed, got %d", len(parts))
+	}
+	if callbacks["catch"] != 1 || callbacks["finally"] != 1 || callbacks["then"] != 0 {
+		t.Fatalf("unexpected callbacks %v", callbacks)
+	}
+
+	b, err := q.FindBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if b.Total != 3 || b.Pending != 0 || b.Succeeded != 1 || b.Failed != 2 || !b.Finished() {
+		t.Fatalf("unexpected batch %+v", b)
+	}
+
+	// 同一个任务再次记录（比如可见性超时后重复执行）不会重复计数，也不会再次分发回调
+	for _, job := range parts {
+		q.recordBatch(ctx, job, batchJobSucceeded)
+		q.recordBatch(ctx, job, batchJobFailed)
+	}
+	if again, err := q.FindBatch(ctx, batch.ID); err != nil {
+		t.Fatal(err)
+	} else if again.Succeeded != 1 || again.Failed != 2 || again.Pending != 0 {
+		t.Fatalf("expected the batch is not changed, got %+v", again)
+	}
+	drain(t, q)
+	if callbacks["catch"] != 1 || callbacks["finally"] != 1 {
+		t.Fatalf("unexpected callbacks %v", callbacks)
+	}
+}
+
+func TestBatchCancelOnFailure(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	var executed []string
+	Register(q, "part", func(ctx context.Context, payload string) error {
+		executed = append(executed, payload)
+		if payload == "bad" {
+			return errors.New("bad part")
+		}
+		return nil
+	})
+	var catches int
+	Register(q, "catch", func(ctx context.Context, b *Batch) error {
+		catches++
+		return nil
+	})
+
+	// 不允许失败的批次在第一个任务失败后取消，其余任务被跳过
+	batch, err := q.Batch(NewJob("part", "bad"), NewJob("part", "ok")).Catch("catch").Dispatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drain(t, q)
+	if len(executed) != 1 || executed[0] != "bad" || catches != 1 {
+		t.Fatalf("unexpected executed %v, catches %d", executed, catches)
+	}
+	b, err := q.FindBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if !b.IsCancelled() || b.Failed != 1 || b.Cancelled != 1 || !b.Finished() {
+		t.Fatalf("unexpected batch %+v", b)
+	}
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+// ChainedJob 链条中等待执行的任务，Payload为空时使用上一个任务的结果（RegisterWithResult）作为payload
+type ChainedJob struct {
+	Name    string `json:"name"`
+	Payload []byte `json:"payload,omitempty"`
+}
+
+// Chain 按顺序依次执行任务，上一个任务执行成功后才会分发下一个任务，任务失败（超过最大执行次数）时链条中止。
+// 下一个任务分发失败时，当前任务按失败处理（按重试策略再次执行）。
+// 剩余的任务保存在当前任务的数据中，所以节点重启后可以继续执行。
+// PendingJob的Payload为nil时，使用上一个任务的结果作为payload（结果为json编码，所以该任务的codec需要是json），
+// 比如：queue.Chain(ctx, worker.NewJob("fetch", url), worker.NewJob("parse", nil), worker.NewJob("save", nil))
+// 返回第一个任务的ID
+func (q *Queue) Chain(ctx context.Context, jobs ...PendingJob) (string, error) {
+	if len(jobs) == 0 {
+		return "", errors.New("chain is empty")
+	}
+
+	chain := make([]ChainedJob, 0, len(jobs))
+	for _, pending := range jobs {
+		var data []byte
+		if pending.Payload != nil {
+			var err error
+			if data, err = q.marshalPayload(pending.Name, pending.Payload); err != nil {
+				return "", err
+			}
+		}
+		chain = append(chain, ChainedJob{Name: pending.Name, Payload: data})
+	}
+
+	job := q.newJob(ctx, chain[0].Name, chain[0].Payload)
+	job.Chain = chain[1:]
+	if err := q.dispatch(ctx, job); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// dispatchNextInChain 分发链条中的下一个任务
+func (q *Queue) dispatchNextInChain(ctx context.Context, job *QueuedJob, result []byte) error {
+	next := job.Chain[0]
+	payload := next.Payload
+	if payload == nil {
+		payload = result
+	}
+
+	nextJob := q.newJob(ctx, next.Name, payload)
+	nextJob.Chain = job.Chain[1:]
+	if err := q.dispatch(ctx, nextJob); err != nil {
+		return errors.Wrapf(err, "dispatch next job \"%s\" in chain after \"%s\"(%s) failed", next.Name, job.Name, job.ID)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestChain(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	RegisterWithResult(q, "length", func(ctx context.Context, payload string) (int, error) {
+		return len(payload), nil
+	})
+	RegisterWithResult(q, "double", func(ctx context.Context, payload int) (int, error) {
+		return payload * 2, nil
+	})
+	var saved []int
+	Register(q, "save", func(ctx context.Context, payload int) error {
+		saved = append(saved, payload)
+		return nil
+	})
+
+	// Payload为nil的任务使用上一个任务的结果，指定了Payload的任务使用自己的payload
+	id, err := q.Chain(ctx, NewJob("length", "hello"), NewJob("double", nil), NewJob("save", nil), NewJob("save", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := drain(t, q); len(jobs) != 4 {
+		t.Fatalf("expected 4 jobs are executed, got %d", len(jobs))
+	}
+	if len(saved) != 2 || saved[0] != 10 || saved[1] != 1 {
+		t.Fatalf("unexpected saved %v", saved)
+	}
+	if result, _, err := JobResult[int](ctx, q, id); err != nil || result != 5 {
+		t.Fatalf("expected the result of the first job is 5, got %d %v", result, err)
+	}
+}
+
+func TestChainAbort(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	RegisterWithResult(q, "fail", func(ctx context.Context, payload string) (string, error) {
+		return "", errors.New("boom")
+	})
+	var calls int
+	Register(q, "next", func(ctx context.Context, payload string) error {
+		calls++
+		return nil
+	})
+
+	// 任务失败时链条中止
+	if _, err := q.Chain(ctx, NewJob("fail", "a"), NewJob("next", nil)); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, q)
+	if calls != 0 {
+		t.Fatal("expected the chain is aborted")
+	}
+	if _, total, _ := q.FailedJobs(ctx, 0, 10); total != 1 {
+		t.Fatalf("expected 1 failed job, got %d", total)
+	}
+}
@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/cache"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// serverClockRefreshInterval redis服务器时间差的刷新间隔
+const serverClockRefreshInterval = time.Minute
+
+// serverClock 以redis服务器时间为准的时钟，避免节点之间的时钟误差。
+// 缓存redis服务器与本地时间的差值，每隔refreshInterval刷新一次，而不是每次操作都执行一次TIME命令
+type serverClock struct {
+	cache           *cache.Cache
+	refreshInterval time.Duration
+
+	mu          sync.Mutex
+	delta       atomic.Int64
+	refreshedAt atomic.Int64
+}
+
+func newServerClock(cache *cache.Cache, refreshInterval time.Duration) *serverClock {
+	return &serverClock{cache: cache, refreshInterval: refreshInterval}
+}
+
+// Now 返回redis服务器的当前时间
+func (c *serverClock) Now(ctx context.Context) time.Time {
+	return time.Now().Add(c.Delta(ctx))
+}
+
+// Delta 返回redis服务器时间与本地时间的差值，过期时刷新（同时只有一个协程刷新，其它协程使用旧的差值）
+func (c *serverClock) Delta(ctx context.Context) time.Duration {
+	refreshedAt := c.refreshedAt.Load()
+	if refreshedAt != 0 && time.Since(time.Unix(0, refreshedAt)) < c.refreshInterval {
+		return time.Duration(c.delta.Load())
+	}
+
+	// 第一次获取时需要等待刷新完成
+	if refreshedAt == 0 {
+		c.mu.Lock()
+	} else if !c.mu.TryLock() {
+		return time.Duration(c.delta.Load())
+	}
+	defer c.mu.Unlock()
+
+	if refreshedAt = c.refreshedAt.Load(); refreshedAt == 0 || time.Since(time.Unix(0, refreshedAt)) >= c.refreshInterval {
+		c.delta.Store(int64(c.cache.ServerTimeDelta(ctx)))
+		c.refreshedAt.Store(time.Now().UnixNano())
+	}
+	return time.Duration(c.delta.Load())
+}
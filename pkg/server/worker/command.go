@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/command"
+	"strings"
+	"text/tabwriter"
+)
+
+// NewQueueCmd 队列失败任务的管理命令：queue failed、queue retry <id>... | --all、queue forget <id>...、queue flush
+func NewQueueCmd(q *Queue) command.ICmder {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Manage the failed jobs of queue \"" + q.name + "\"",
+	}
+
+	failed := &cobra.Command{
+		Use:   "failed",
+		Short: "List the failed jobs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			offset, _ := cmd.Flags().GetInt("offset")
+			limit, _ := cmd.Flags().GetInt("limit")
+			jobs, total, err := q.FailedJobs(cmd.Context(), offset, limit)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "ID\tNAME\tATTEMPTS\tFAILED AT\tERROR")
+			for _, job := range jobs {
+				// 错误信息只显示第一行
+				message, _, _ := strings.Cut(job.Error, "\n")
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", job.ID, job.Name, job.Attempts, job.FailedAt.Format("2006-01-02 15:04:05"), message)
+			}
+			if err = w.Flush(); err != nil {
+				return err
+			}
+			cmd.Printf("%d of %d failed job(s)\n", len(jobs), total)
+			return nil
+		},
+	}
+	failed.Flags().Int("offset", 0, "skip the first N failed jobs")
+	failed.Flags().Int("limit", 20, "number of failed jobs to list")
+
+	retry := &cobra.Command{
+		Use:   "retry [id...]",
+		Short: "Push the failed jobs back onto the queue",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all, _ := cmd.Flags().GetBool("all"); all {
+				count, err := q.RetryAllFailed(cmd.Context())
+				cmd.Printf("retried %d failed job(s)\n", count)
+				return err
+			} else if len(args) == 0 {
+				return errors.New("specify the ids of failed jobs, or use --all")
+			}
+			if err := q.RetryFailed(cmd.Context(), args...); err != nil {
+				return err
+			}
+			cmd.Printf("retried %d failed job(s)\n", len(args))
+			return nil
+		},
+	}
+	retry.Flags().Bool("all", false, "retry all the failed jobs")
+
+	forget := &cobra.Command{
+		Use:   "forget <id>...",
+		Short: "Delete the failed jobs",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := q.ForgetFailed(cmd.Context(), args...); err != nil {
+				return err
+			}
+			cmd.Printf("forgot %d failed job(s)\n", len(args))
+			return nil
+		},
+	}
+
+	flush := &cobra.Command{
+		Use:   "flush",
+		Short: "Delete all the failed jobs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := q.FlushFailed(cmd.Context()); err != nil {
+				return err
+			}
+			cmd.Println("flushed all the failed jobs")
+			return nil
+		},
+	}
+
+	cmd.AddCommand(failed, retry, forget, flush)
+	return command.NewBaseCmd(cmd)
+}
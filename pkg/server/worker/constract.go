@@ -11,6 +11,12 @@ import (
 type IWorker interface {
 	WithContext(ctx context.Context) IWorker
 	OnceForCluster(key string, options ...onceOption) IWorker
+	Unique(key string, ttl time.Duration, options ...onceOption) IWorker
+	Debounce(key string, wait time.Duration, options ...onceOption) IWorker
+	Throttle(key string, interval time.Duration, options ...onceOption) IWorker
+	Wrap(wrappers ...job.JobWrapper) IWorker
+	Named(name string) IWorker
+	OnPool(name string) IWorker
 	Submit(job job.Job)
 	SubmitWait(job job.Job)
 	SubmitAfter(delay time.Duration, job job.Job)
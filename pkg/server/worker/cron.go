@@ -0,0 +1,448 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/cache"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/job"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCronNotFound cron任务没有登记（没有使用Named设置名称）
+	ErrCronNotFound = errors.New("cron not found")
+	// ErrCronDuplicated 同名的cron任务已经登记
+	ErrCronDuplicated = errors.New("cron is duplicated")
+)
+
+const (
+	// cronPausedKey 暂停的cron任务，hash field为任务名称
+	cronPausedKey = "cron:paused"
+	// cronTriggersKey 手动触发的cron任务，hash field为任务名称，由集群中第一个取到的节点执行
+	cronTriggersKey = "cron:triggers"
+	// cronTriggerChannel 手动触发时发布任务名称的频道，节点收到后立即尝试取出触发标记
+	cronTriggerChannel = "cron:triggered"
+	// cronRegistryKey 集群中登记的cron任务，hash field为任务名称，值为最后一次续期的时间（unix ms）
+	cronRegistryKey = "cron:registry"
+
+	// cronRegistryInterval 节点续期登记的cron任务的间隔，同时也是检查触发标记的间隔（频道的消息丢失时兜底）
+	cronRegistryInterval = 30 * time.Second
+	// cronRegistryTTL 超过该时间没有节点续期的cron任务视为已经删除
+	cronRegistryTTL = 3 * cronRegistryInterval
+)
+
+// CronEntry cron任务的信息
+type CronEntry struct {
+	Name    string       `json:"name"`
+	Spec    string       `json:"spec"`
+	EntryID cron.EntryID `json:"entry_id"`
+	// OnceKey OnceForCluster的key，为空表示每个节点都会执行
+	OnceKey string    `json:"once_key,omitempty"`
+	Next    time.Time `json:"next"`
+	Prev    time.Time `json:"prev"`
+	Paused  bool      `json:"paused"`
+	// Running 当前节点执行中的数量
+	Running int `json:"running"`
+	// 当前节点最后一次执行的信息
+	LastRunAt    time.Time     `json:"last_run_at"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+	// Holder 集群中最后一次执行的节点（app id），仅OnceForCluster的任务有
+	Holder      string    `json:"holder,omitempty"`
+	HolderRunAt time.Time `json:"holder_run_at,omitempty"`
+}
+
+type cronRecord struct {
+	name      string
+	spec      string
+	entryID   cron.EntryID
+	onceKey   string
+	onceCache *cache.Cache
+	ctx       context.Context
+	// poolName 手动触发时执行的pool（OnPool）
+	poolName string
+	// trigger 手动触发时执行的job（不检查OnceForCluster）
+	trigger job.Job
+
+	mu           sync.Mutex
+	running      int
+	lastRunAt    time.Time
+	lastDuration time.Duration
+	lastError    string
+}
+
+// track 记录job的执行状态，panic或job.ReportError的错误会记录为LastError
+func (r *cronRecord) track(j job.Job) job.Job {
+	return func(ctx context.Context) {
+		start := time.Now()
+		r.mu.Lock()
+		r.running++
+		r.lastRunAt = start
+		r.mu.Unlock()
+
+		ctx, getError := job.NewErrorContext(ctx)
+		defer func() {
+			rec := recover()
+			r.mu.Lock()
+			r.running--
+			r.lastDuration = time.Since(start)
+			r.lastError = ""
+			if rec != nil {
+				r.lastError = fmt.Sprintf("panic: %v", rec)
+			} else if err := getError(); err != nil {
+				r.lastError = err.Error()
+			}
+			r.mu.Unlock()
+			if rec != nil {
+				panic(rec)
+			}
+		}()
+		j(ctx)
+	}
+}
+
+type cronRegistry struct {
+	// flags 保存暂停、触发标记的cache（不设置过期时间）
+	flags *cache.Cache
+
+	mu      sync.RWMutex
+	records map[string]*cronRecord
+	names   []string
+}
+
+func newCronRegistry(flags *cache.Cache) *cronRegistry {
+	return &cronRegistry{
+		flags:   flags,
+		records: map[string]*cronRecord{},
+	}
+}
+
+func (r *cronRegistry) add(record *cronRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.records[record.name]; ok {
+		return errors.Wrapf(ErrCronDuplicated, "name: %s", record.name)
+	}
+	r.records[record.name] = record
+	r.names = append(r.names, record.name)
+	return nil
+}
+
+// remove 删除名为name的cron任务，返回被删除的记录
+func (r *cronRegistry) remove(name string) (*cronRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[name]
+	if !ok {
+		return nil, false
+	}
+	delete(r.records, name)
+	r.names = slices.DeleteFunc(r.names, func(n string) bool { return n == name })
+	return record, true
+}
+
+func (r *cronRegistry) get(name string) (*cronRecord, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	record, ok := r.records[name]
+	return record, ok
+}
+
+func (r *cronRegistry) list() []*cronRecord {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	records := make([]*cronRecord, 0, len(r.names))
+	for _, name := range r.names {
+		records = append(records, r.records[name])
+	}
+	return records
+}
+
+// describeSchedule 返回cron表达式的描述
+func describeSchedule(spec any, schedule cron.Schedule) string {
+	if s, ok := schedule.(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch s := spec.(type) {
+	case string:
+		return s
+	case cron.ConstantDelaySchedule:
+		return "@every " + s.Delay.String()
+	}
+	return fmt.Sprintf("%T", schedule)
+}
+
+// skipIfPaused 任务被暂停时（集群中任意节点调用PauseCron）跳过执行
+func (w *Worker) skipIfPaused(name string, j job.Job) job.Job {
+	return func(ctx context.Context) {
+		paused, err := w.registry.flags.HExists(ctx, cronPausedKey, name)
+		if err != nil { // redis报错时不跳过执行，只记录日志
+			w.logger.WithContext(ctx).Errorf("[Cron]check paused of cron \"%s\" failed: %v", name, err)
+		} else if paused {
+			w.logger.WithContext(ctx).Infof("[Cron]cron \"%s\" is paused, skip", name)
+			return
+		}
+		j(ctx)
+	}
+}
+
+// addCron 添加cron任务，onceWrapper不为空时使用其保证集群中只执行一次。
+// 设置了名称（Named）的任务会登记到registry中，可以通过CronEntries查看，PauseCron、ResumeCron、TriggerCron操作
+func (w *Worker) addCron(spec any, j job.Job, onceKey string, onceWrapper func(cron.Schedule, job.Job) job.Job) (cron.EntryID, error) {
+	expr, err := w.parseSchedule(spec)
+	if err != nil {
+		w.logger.Errorf("[Cron]parse schedule %v failed: %v", spec, err)
+		return 0, err
+	}
+
+	if w.name == "" {
+		if onceWrapper != nil {
+			j = onceWrapper(expr, j)
+		}
+		j = w.wrap(j)
+		return w.schedule.Schedule(expr, cron.FuncJob(func() {
+			j(w.ctx)
+		})), nil
+	}
+
+	record := &cronRecord{
+		name:      w.name,
+		spec:      describeSchedule(spec, expr),
+		onceKey:   onceKey,
+		onceCache: w.cache,
+		ctx:       w.ctx,
+		poolName:  w.poolName,
+	}
+	if err = w.registry.add(record); err != nil {
+		return 0, err
+	}
+	w.registerCrons(w.ctx, record)
+
+	tracked := record.track(j)
+	record.trigger = w.wrap(tracked)
+	if onceWrapper != nil {
+		tracked = onceWrapper(expr, tracked)
+	}
+	scheduled := w.wrap(w.skipIfPaused(record.name, tracked))
+	record.entryID = w.schedule.Schedule(expr, cron.FuncJob(func() {
+		scheduled(w.ctx)
+	}))
+	return record.entryID, nil
+}
+
+// CronEntries returns the information of the named cron jobs.
+//
+//	CronEntries 返回所有登记的（Named）cron任务的信息，包括下次执行时间、最后一次的耗时和错误、暂停状态、集群中最后执行的节点等
+func (w *Worker) CronEntries(ctx context.Context) ([]CronEntry, error) {
+	paused, err := w.registry.flags.HKeys(ctx, cronPausedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "get paused crons failed")
+	}
+
+	records := w.registry.list()
+	entries := make([]CronEntry, 0, len(records))
+	for _, record := range records {
+		entry := w.schedule.Entry(record.entryID)
+		record.mu.Lock()
+		e := CronEntry{
+			Name:         record.name,
+			Spec:         record.spec,
+			EntryID:      record.entryID,
+			OnceKey:      record.onceKey,
+			Next:         entry.Next,
+			Prev:         entry.Prev,
+			Paused:       slices.Contains(paused, record.name),
+			Running:      record.running,
+			LastRunAt:    record.lastRunAt,
+			LastDuration: record.lastDuration,
+			LastError:    record.lastError,
+		}
+		record.mu.Unlock()
+
+		if record.onceKey != "" {
+			e.Holder, e.HolderRunAt = w.cronHolder(ctx, record)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// cronHolder 从onceCronRedisScript的key中读取集群中最后一次执行的节点
+func (w *Worker) cronHolder(ctx context.Context, record *cronRecord) (string, time.Time) {
+	val, err := record.onceCache.Get(ctx, record.onceKey, nil)
+	if err != nil || val == "" {
+		return "", time.Time{}
+	}
+	var last struct {
+		LastAt float64 `json:"last_at"`
+		AppID  string  `json:"app_id"`
+	}
+	if err = json.Unmarshal([]byte(val), &last); err != nil {
+		return "", time.Time{}
+	}
+	return last.AppID, time.Unix(0, int64(last.LastAt))
+}
+
+// RemoveCron 删除当前节点名为name的cron任务，并从集群的登记中删除。
+// 其它节点仍然有该任务时，会在下一次续期时重新登记
+func (w *Worker) RemoveCron(ctx context.Context, name string) error {
+	record, ok := w.registry.remove(name)
+	if !ok {
+		return errors.Wrapf(ErrCronNotFound, "name: %s", name)
+	}
+	w.schedule.Remove(record.entryID)
+	_, err := w.registry.flags.HDel(ctx, cronRegistryKey, name)
+	return err
+}
+
+// checkCron 检查集群中是否有节点登记了名为name的cron任务（不要求当前节点有该任务）
+func (w *Worker) checkCron(ctx context.Context, name string) error {
+	val, err := w.registry.flags.HGet(ctx, cronRegistryKey, name, nil)
+	if err != nil {
+		return errors.Wrapf(err, "get cron \"%s\" failed", name)
+	}
+	// 不存在时val为空
+	seenAt, _ := strconv.ParseInt(val, 10, 64)
+	if time.Since(time.UnixMilli(seenAt)) > cronRegistryTTL {
+		return errors.Wrapf(ErrCronNotFound, "name: %s", name)
+	}
+	return nil
+}
+
+// PauseCron 在整个集群中暂停cron任务，直到ResumeCron
+func (w *Worker) PauseCron(ctx context.Context, name string) error {
+	if err := w.checkCron(ctx, name); err != nil {
+		return err
+	}
+	_, err := w.registry.flags.HSet(ctx, cronPausedKey, name, time.Now().Format(time.RFC3339))
+	return err
+}
+
+// ResumeCron 在整个集群中恢复cron任务
+func (w *Worker) ResumeCron(ctx context.Context, name string) error {
+	if err := w.checkCron(ctx, name); err != nil {
+		return err
+	}
+	_, err := w.registry.flags.HDel(ctx, cronPausedKey, name)
+	return err
+}
+
+// TriggerCron 立即执行一次cron任务（不受暂停、OnceForCluster的限制），由集群中第一个取到触发标记的节点执行
+func (w *Worker) TriggerCron(ctx context.Context, name string) error {
+	if err := w.checkCron(ctx, name); err != nil {
+		return err
+	}
+	if _, err := w.registry.flags.HSet(ctx, cronTriggersKey, name, time.Now().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	// 发布失败时，由定时的检查兜底执行
+	if _, err := w.registry.flags.Publish(ctx, cronTriggerChannel, name); err != nil {
+		w.logger.WithContext(ctx).Errorf("[Cron]publish trigger of cron \"%s\" failed: %v", name, err)
+	}
+	return nil
+}
+
+// registerCrons 在集群中登记（续期）cron任务
+func (w *Worker) registerCrons(ctx context.Context, records ...*cronRecord) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	for _, record := range records {
+		if _, err := w.registry.flags.HSet(ctx, cronRegistryKey, record.name, now); err != nil {
+			w.logger.WithContext(ctx).Errorf("[Cron]register cron \"%s\" failed: %v", record.name, err)
+		}
+	}
+}
+
+// pruneCrons 删除超过cronRegistryTTL没有续期的登记（所有节点都已经删除或下线），
+// 以及没有节点登记的任务的触发标记（没有节点会取出，否则会一直保留）
+func (w *Worker) pruneCrons(ctx context.Context) {
+	registered, err := w.registry.flags.HGetAll(ctx, cronRegistryKey, nil)
+	if err != nil {
+		w.logger.WithContext(ctx).Errorf("[Cron]get registered crons failed: %v", err)
+		return
+	}
+	var expired []string
+	for name, val := range registered {
+		seenAt, _ := strconv.ParseInt(val, 10, 64)
+		if time.Since(time.UnixMilli(seenAt)) > cronRegistryTTL {
+			expired = append(expired, name)
+			delete(registered, name)
+		}
+	}
+	if len(expired) > 0 {
+		if _, err = w.registry.flags.HDel(ctx, cronRegistryKey, expired...); err != nil {
+			w.logger.WithContext(ctx).Errorf("[Cron]prune crons %v failed: %v", expired, err)
+		}
+	}
+
+	triggers, err := w.registry.flags.HKeys(ctx, cronTriggersKey)
+	if err != nil {
+		w.logger.WithContext(ctx).Errorf("[Cron]get triggers failed: %v", err)
+		return
+	}
+	var orphans []string
+	for _, name := range triggers {
+		if _, ok := registered[name]; !ok {
+			orphans = append(orphans, name)
+		}
+	}
+	if len(orphans) > 0 {
+		if _, err = w.registry.flags.HDel(ctx, cronTriggersKey, orphans...); err != nil {
+			w.logger.WithContext(ctx).Errorf("[Cron]prune triggers %v failed: %v", orphans, err)
+		}
+	}
+}
+
+// maintainCrons 续期当前节点的cron任务、清理过期的登记，并检查触发标记（频道的消息丢失时兜底）
+func (w *Worker) maintainCrons() {
+	records := w.registry.list()
+	if len(records) == 0 {
+		return
+	}
+
+	ctx := w.ctx
+	w.registerCrons(ctx, records...)
+	w.pruneCrons(ctx)
+
+	names, err := w.registry.flags.HKeys(ctx, cronTriggersKey)
+	if err != nil {
+		w.logger.WithContext(ctx).Errorf("[Cron]get triggers failed: %v", err)
+		return
+	}
+	for _, name := range names {
+		w.runCronTrigger(ctx, name)
+	}
+}
+
+// subscribeCronTriggers 订阅手动触发的频道，收到任务名称后立即尝试执行，返回取消订阅的函数
+func (w *Worker) subscribeCronTriggers(ctx context.Context) func() {
+	sub := w.registry.flags.Subscribe(ctx, cronTriggerChannel)
+	go func() {
+		for msg := range sub.Channel() {
+			w.runCronTrigger(ctx, msg.Payload)
+		}
+	}()
+	return func() {
+		_ = sub.Close()
+	}
+}
+
+// runCronTrigger 当前节点有该任务时，删除触发标记，删除成功（取到标记）的节点执行任务
+func (w *Worker) runCronTrigger(ctx context.Context, name string) {
+	record, ok := w.registry.get(name)
+	if !ok {
+		return
+	}
+	if n, err := w.registry.flags.HDel(ctx, cronTriggersKey, name); err != nil || n == 0 {
+		return
+	}
+	w.logger.WithContext(ctx).Infof("[Cron]cron \"%s\" is triggered manually", name)
+	w.pool.Submit(record.poolName, func() {
+		record.trigger(record.ctx)
+	})
+}
@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	pHttp "gopkg.in/go-mixed/kratos-packages.v2/pkg/http"
+	"net/http"
+	"strings"
+)
+
+type cronHandler struct {
+	worker     *Worker
+	authorizer func(r *http.Request) error
+}
+
+type CronHandlerOption func(*cronHandler)
+
+// WithCronAuthorizer 设置鉴权函数，每个请求执行之前调用，返回error时响应403，不再执行操作。
+// 可以根据r.Method区分查看（GET）和暂停、恢复、触发（POST）的权限
+// example: worker.NewCronHandler(w, worker.WithCronAuthorizer(func(r *http.Request) error { return checkAdmin(r) }))
+func WithCronAuthorizer(authorizer func(r *http.Request) error) CronHandlerOption {
+	return func(h *cronHandler) {
+		h.authorizer = authorizer
+	}
+}
+
+// NewCronHandler cron任务的管理接口，挂载在任意前缀下：
+//   - GET  {prefix}                  列出所有登记的cron任务（CronEntries）
+//   - POST {prefix}/{name}/pause     在集群中暂停
+//   - POST {prefix}/{name}/resume    在集群中恢复
+//   - POST {prefix}/{name}/trigger   立即执行一次
+//
+// example: httpServer.HandlePrefix("/admin/crons", worker.NewCronHandler(w, worker.WithCronAuthorizer(authorizer)))
+// 注意：没有设置WithCronAuthorizer时该接口没有鉴权，请在外层添加鉴权的中间件，或只在内网暴露
+func NewCronHandler(w *Worker, options ...CronHandlerOption) http.Handler {
+	h := &cronHandler{worker: w}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+func (h *cronHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if h.authorizer != nil {
+		if err := h.authorizer(r); err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	if r.Method == http.MethodGet {
+		entries, err := h.worker.CronEntries(ctx)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pHttp.CommonResponseT[[]CronEntry]{Data: entries})
+		return
+	} else if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s is not allowed", r.Method))
+		return
+	} else if len(segments) < 2 {
+		writeError(w, http.StatusNotFound, errors.Errorf("path %s is not found", r.URL.Path))
+		return
+	}
+
+	name, action := segments[len(segments)-2], segments[len(segments)-1]
+	var err error
+	switch action {
+	case "pause":
+		err = h.worker.PauseCron(ctx, name)
+	case "resume":
+		err = h.worker.ResumeCron(ctx, name)
+	case "trigger":
+		err = h.worker.TriggerCron(ctx, name)
+	default:
+		writeError(w, http.StatusNotFound, errors.Errorf("action %s is not supported", action))
+		return
+	}
+
+	if errors.Is(err, ErrCronNotFound) {
+		writeError(w, http.StatusNotFound, err)
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+	} else {
+		writeJSON(w, http.StatusOK, pHttp.BaseResponse{})
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, pHttp.BaseResponse{Code: status, Message: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+)
+
+func newTestWorker(t *testing.T, options ...Option) *Worker {
+	t.Helper()
+	c, _ := newTestCache(t)
+	return NewWorker(testApp, log.New(context.Background()), c, 4, options...)
+}
+
+func TestCronTriggerPool(t *testing.T) {
+	w := newTestWorker(t)
+	ctx := context.Background()
+
+	done := make(chan struct{}, 1)
+	if _, err := w.OnPool("reports").Named("report").Cron("@every 1h", func(ctx context.Context) {
+		done <- struct{}{}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.TriggerCron(ctx, "report"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 手动触发的任务在添加cron任务时的pool中执行
+	w.maintainCrons()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the cron is triggered")
+	}
+	if err := w.pool.StopWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, stats := range w.PoolStats() {
+		if stats.Name == "reports" && stats.Completed != 1 {
+			t.Fatalf("expected the trigger runs in the reports pool, got %+v", stats)
+		} else if stats.Name == DefaultPool && stats.Submitted != 0 {
+			t.Fatalf("expected nothing runs in the default pool, got %+v", stats)
+		}
+	}
+	if exists, _ := w.registry.flags.HExists(ctx, cronTriggersKey, "report"); exists {
+		t.Fatal("expected the trigger flag is taken")
+	}
+}
+
+func TestPruneCrons(t *testing.T) {
+	w := newTestWorker(t)
+	ctx := context.Background()
+
+	if _, err := w.Named("live").Cron("@every 1h", func(ctx context.Context) {}); err != nil {
+		t.Fatal(err)
+	}
+	// 其它节点登记的任务：remote仍然在续期，expired已经超过cronRegistryTTL没有续期
+	expiredAt := strconv.FormatInt(time.Now().Add(-2*cronRegistryTTL).UnixMilli(), 10)
+	if _, err := w.registry.flags.HMSet(ctx, cronRegistryKey, map[string]any{
+		"remote":  strconv.FormatInt(time.Now().UnixMilli(), 10),
+		"expired": expiredAt,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.registry.flags.HMSet(ctx, cronTriggersKey, map[string]any{
+		"remote":  "x",
+		"expired": "x",
+		"ghost":   "x",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	w.maintainCrons()
+	registered, err := w.registry.flags.HKeys(ctx, cronRegistryKey)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(registered) != 2 {
+		t.Fatalf("expected live and remote are registered, got %v", registered)
+	}
+	// 没有节点登记的任务的触发标记被删除，其它节点的任务保留，等待其执行
+	triggers, err := w.registry.flags.HKeys(ctx, cronTriggersKey)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(triggers) != 1 || triggers[0] != "remote" {
+		t.Fatalf("expected only the trigger of remote is kept, got %v", triggers)
+	}
+}
@@ -0,0 +1,256 @@
+package worker
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/job"
+	"time"
+)
+
+type onceMode int
+
+const (
+	// onceModeCluster OnceForCluster：key存在期间只执行一次
+	onceModeCluster onceMode = iota
+	// onceModeUnique Unique：job等待或执行中时，丢弃重复提交的job
+	onceModeUnique
+	// onceModeDebounce Debounce：最后一次提交后wait时间内没有新的提交才执行
+	onceModeDebounce
+	// onceModeThrottle Throttle：每个interval最多执行一次
+	onceModeThrottle
+)
+
+// onceAcquireScript 设置key（不存在时），返回是否设置成功
+//
+//	KEYS: key
+//	ARGV: token, ttl(ms)
+const onceAcquireScript = `if redis.call('set', KEYS[1], ARGV[1], 'nx', 'px', ARGV[2]) then
+	return 1
+end
+return 0
+`
+
+// onceReleaseScript 删除key，只删除值为token的key（避免删除其它提交设置的key）
+//
+//	KEYS: key
+//	ARGV: token
+const onceReleaseScript = `if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('del', KEYS[1])
+end
+return 0
+`
+
+// onceDebounceScript 设置最后一次提交的token
+//
+//	KEYS: key
+//	ARGV: token, ttl(ms)
+const onceDebounceScript = `redis.call('set', KEYS[1], ARGV[1], 'px', ARGV[2])
+return 1
+`
+
+// onceThrottleScript 在interval内第一次提交时返回0（立即执行）；
+// trailing为1时，之后第一次提交返回key的剩余时间（ms），在key过期后执行一次；其余的提交返回-1（丢弃）
+//
+//	KEYS: key
+//	ARGV: interval(ms), trailing(0/1)
+const onceThrottleScript = `if redis.call('exists', KEYS[1]) == 0 then
+	redis.call('hset', KEYS[1], 'trailing', 0)
+	redis.call('pexpire', KEYS[1], ARGV[1])
+	return 0
+end
+if ARGV[2] == '1' and redis.call('hget', KEYS[1], 'trailing') == '0' then
+	redis.call('hset', KEYS[1], 'trailing', 1)
+	local ttl = redis.call('pttl', KEYS[1])
+	if ttl <= 0 then
+		ttl = tonumber(ARGV[1])
+	end
+	return ttl
+end
+return -1
+`
+
+// Unique drops the duplicated jobs while a job with the same key is queued or running in the cluster.
+//
+//	Unique 表示在集群中该key的job等待或执行期间，重复提交的job会被丢弃；job执行完毕后可以再次提交。
+//	ttl为key的最长保留时间，避免节点宕机后key无法释放，需要大于job的等待和执行时间。
+//	如果是cron任务，表示上一次执行还没有结束（集群中任意节点）时跳过本次执行。
+//	比如：w.Unique("export:"+userID, time.Hour).Submit(func(ctx){...})
+func (w *Worker) Unique(key string, ttl time.Duration, options ...onceOption) IWorker {
+	return w.newOnceWorker(key, onceModeUnique, ttl, options...)
+}
+
+// Debounce coalesces the jobs with the same key in the cluster, only the last one is executed after wait.
+//
+//	Debounce 表示在集群中该key的job，在最后一次提交后wait时间内没有新的提交时才执行（执行的是最后一次提交的job）。
+//	适用于合并突发的事件，比如模型的Updated事件触发重建索引：w.Debounce("reindex:"+id, 5*time.Second).Submit(reindex)
+//	SubmitWait、SubmitWithError不会等待job执行。如果是cron任务，与Throttle相同
+func (w *Worker) Debounce(key string, wait time.Duration, options ...onceOption) IWorker {
+	return w.newOnceWorker(key, onceModeDebounce, wait, options...)
+}
+
+// Throttle executes the jobs with the same key at most once per interval in the cluster.
+//
+//	Throttle 表示在集群中该key的job每个interval最多执行一次：interval内第一次提交立即执行，
+//	之后的提交合并为一次，在interval结束后执行（保证最后的事件也会被处理），其余的提交被丢弃。
+//	比如：w.Throttle("stats:refresh", time.Minute).Submit(refresh)
+//	SubmitWait、SubmitWithError不会等待job执行。如果是cron任务，表示每个interval最多执行一次
+func (w *Worker) Throttle(key string, interval time.Duration, options ...onceOption) IWorker {
+	return w.newOnceWorker(key, onceModeThrottle, interval, options...)
+}
+
+func (w *Worker) newOnceWorker(key string, mode onceMode, interval time.Duration, options ...onceOption) *onceWorker {
+	ow := &onceWorker{
+		key:      key,
+		worker:   w.clone(),
+		mode:     mode,
+		interval: interval,
+	}
+	for _, option := range options {
+		option(ow)
+	}
+	return ow
+}
+
+// newToken 区分不同提交的token
+func (w *onceWorker) newToken() string {
+	return w.worker.app.ID() + ":" + uuid.NewString()
+}
+
+// dispatch 按Unique、Debounce、Throttle去重后，异步执行job
+func (w *onceWorker) dispatch(j job.Job) {
+	switch w.mode {
+	case onceModeUnique:
+		if release, ok := w.acquireUnique(w.interval); ok {
+			w.worker.Submit(w.wrapperUniqueJob(release, j))
+		}
+	case onceModeDebounce:
+		w.debounce(j)
+	case onceModeThrottle:
+		w.throttle(j)
+	default:
+		w.worker.Submit(w.wrapperOnceJob(w.key, j))
+	}
+}
+
+// acquireUnique 占用key，返回释放key的函数。key已经被占用时返回false
+func (w *onceWorker) acquireUnique(ttl time.Duration) (func(ctx context.Context), bool) {
+	return w.acquireUniqueWithContext(w.worker.ctx, ttl)
+}
+
+func (w *onceWorker) acquireUniqueWithContext(ctx context.Context, ttl time.Duration) (func(ctx context.Context), bool) {
+	token := w.newToken()
+	ok, err := w.worker.cache.Script(onceAcquireScript).Run(ctx,
+		[]string{w.key},
+		token, ttl.Milliseconds()).Int()
+	if err != nil { // 不能因为redis报错而跳过执行，只记录日志。
+		w.worker.logger.WithContext(ctx).Errorf("[UniqueJob]acquire %s failed: %v", w.key, err)
+		return func(context.Context) {}, true
+	} else if ok != 1 {
+		w.worker.logger.WithContext(ctx).Infof("[UniqueJob]job of key %s is queued or running, skip the job", w.key)
+		return nil, false
+	}
+
+	return func(ctx context.Context) {
+		if err := w.worker.cache.Script(onceReleaseScript).Run(context.WithoutCancel(ctx),
+			[]string{w.key},
+			token).Err(); err != nil {
+			w.worker.logger.WithContext(ctx).Errorf("[UniqueJob]release %s failed: %v", w.key, err)
+		}
+	}, true
+}
+
+// wrapperUniqueJob job执行完毕（包括panic）后释放key
+func (w *onceWorker) wrapperUniqueJob(release func(ctx context.Context), j job.Job) job.Job {
+	return func(ctx context.Context) {
+		defer release(ctx)
+		j(ctx)
+	}
+}
+
+// wrapperUniqueCronJob 每次cron触发时占用key，上一次执行还没有结束时跳过
+func (w *onceWorker) wrapperUniqueCronJob(j job.Job) job.Job {
+	return func(ctx context.Context) {
+		release, ok := w.acquireUniqueWithContext(ctx, w.interval)
+		if !ok {
+			return
+		}
+		defer release(ctx)
+		j(ctx)
+	}
+}
+
+// wrapperThrottleCronJob 每个interval最多执行一次
+func (w *onceWorker) wrapperThrottleCronJob(j job.Job) job.Job {
+	return func(ctx context.Context) {
+		if w.tryThrottle(ctx) {
+			j(ctx)
+		}
+	}
+}
+
+// tryThrottle 占用interval的执行机会，redis报错时也返回true
+func (w *onceWorker) tryThrottle(ctx context.Context) bool {
+	res, err := w.worker.cache.Script(onceThrottleScript).Run(ctx,
+		[]string{w.key},
+		w.interval.Milliseconds(), 0).Int()
+	if err != nil { // 不能因为redis报错而跳过执行，只记录日志。
+		w.worker.logger.WithContext(ctx).Errorf("[ThrottleJob]acquire %s failed: %v", w.key, err)
+		return true
+	} else if res != 0 {
+		w.worker.logger.WithContext(ctx).Infof("[ThrottleJob]job of key %s is throttled, skip the job", w.key)
+		return false
+	}
+	return true
+}
+
+// debounce 记录最后一次提交的token，wait之后token仍然是自己时才执行
+func (w *onceWorker) debounce(j job.Job) {
+	ctx := w.worker.ctx
+	token := w.newToken()
+	// 双倍过期时间，避免在检查之前过期
+	err := w.worker.cache.Script(onceDebounceScript).Run(ctx,
+		[]string{w.key},
+		token, (w.interval * 2).Milliseconds()).Err()
+	if err != nil { // 不能因为redis报错而跳过执行，只记录日志。
+		w.worker.logger.WithContext(ctx).Errorf("[DebounceJob]set %s failed: %v", w.key, err)
+		w.worker.SubmitAfter(w.interval, j)
+		return
+	}
+
+	w.worker.SubmitAfter(w.interval, func(ctx context.Context) {
+		n, err := w.worker.cache.Script(onceReleaseScript).Run(ctx,
+			[]string{w.key},
+			token).Int()
+		if err != nil {
+			w.worker.logger.WithContext(ctx).Errorf("[DebounceJob]check %s failed: %v", w.key, err)
+		} else if n != 1 {
+			w.worker.logger.WithContext(ctx).Infof("[DebounceJob]job of key %s is superseded, skip the job", w.key)
+			return
+		}
+		j(ctx)
+	})
+}
+
+// throttle interval内第一次提交立即执行，之后的提交合并为一次在interval结束后执行
+func (w *onceWorker) throttle(j job.Job) {
+	ctx := w.worker.ctx
+	res, err := w.worker.cache.Script(onceThrottleScript).Run(ctx,
+		[]string{w.key},
+		w.interval.Milliseconds(), 1).Int64()
+	switch {
+	case err != nil: // 不能因为redis报错而跳过执行，只记录日志。
+		w.worker.logger.WithContext(ctx).Errorf("[ThrottleJob]run script of %s failed: %v", w.key, err)
+		w.worker.Submit(j)
+	case res == 0:
+		w.worker.Submit(j)
+	case res > 0:
+		// interval结束后再执行一次，如果其它节点已经先执行了（占用了新的interval），则跳过
+		w.worker.SubmitAfter(time.Duration(res)*time.Millisecond, func(ctx context.Context) {
+			if w.tryThrottle(ctx) {
+				j(ctx)
+			}
+		})
+	default:
+		w.worker.logger.WithContext(ctx).Infof("[ThrottleJob]job of key %s is throttled, skip the job", w.key)
+	}
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestThrottleScript(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+	interval := time.Minute
+
+	throttle := func(key string, trailing int) int64 {
+		t.Helper()
+		res, err := c.Script(onceThrottleScript).Run(ctx, []string{key}, interval.Milliseconds(), trailing).Int64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// 没有trailing：interval内第一次立即执行，其余丢弃
+	if throttle("leading", 0) != 0 {
+		t.Fatal("expected the first submit runs immediately")
+	}
+	for i := 0; i < 3; i++ {
+		if res := throttle("leading", 0); res != -1 {
+			t.Fatalf("expected the submit is dropped, got %d", res)
+		}
+	}
+	mr.FastForward(interval)
+	if throttle("leading", 0) != 0 {
+		t.Fatal("expected the first submit of the next interval runs immediately")
+	}
+
+	// trailing：之后第一次提交返回剩余时间，在interval结束后执行，其余丢弃
+	if throttle("trailing", 1) != 0 {
+		t.Fatal("expected the first submit runs immediately")
+	}
+	mr.FastForward(20 * time.Second)
+	if res := throttle("trailing", 1); res != (40 * time.Second).Milliseconds() {
+		t.Fatalf("expected the trailing submit runs after 40s, got %dms", res)
+	}
+	if res := throttle("trailing", 1); res != -1 {
+		t.Fatalf("expected only one trailing submit, got %d", res)
+	}
+	mr.FastForward(40 * time.Second)
+	// trailing执行时占用新的interval（tryThrottle），之后的提交重新开始计算trailing
+	if throttle("trailing", 0) != 0 {
+		t.Fatal("expected the trailing job acquires the next interval")
+	}
+	if res := throttle("trailing", 1); res <= 0 {
+		t.Fatalf("expected a new trailing submit, got %d", res)
+	}
+}
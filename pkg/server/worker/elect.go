@@ -0,0 +1,248 @@
+package worker
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/job"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrElectionDuplicated 同名的选举已经存在
+var ErrElectionDuplicated = errors.New("election is duplicated")
+
+// electCampaignScript 竞选或续约：租约属于自己时续期，不存在时获取。LUA可以保证原子性。
+//
+//	KEYS: lease
+//	ARGV: token, ttl(ms)
+const electCampaignScript = `if redis.call('get', KEYS[1]) == ARGV[1] then
+	redis.call('pexpire', KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call('set', KEYS[1], ARGV[1], 'nx', 'px', ARGV[2]) then
+	return 1
+end
+return 0
+`
+
+// electResignScript 释放租约，只删除属于自己的租约
+//
+//	KEYS: lease
+//	ARGV: token
+const electResignScript = `if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('del', KEYS[1])
+end
+return 0
+`
+
+type election struct {
+	name string
+	fn   job.Job
+	ctx  context.Context
+	// token 租约的值，区分不同的节点（以及同一节点中的不同Worker）
+	token         string
+	ttl           time.Duration
+	retryInterval time.Duration
+
+	leader *atomic.Bool
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type electionRegistry struct {
+	mu        sync.Mutex
+	started   bool
+	elections map[string]*election
+}
+
+func newElectionRegistry() *electionRegistry {
+	return &electionRegistry{
+		elections: map[string]*election{},
+	}
+}
+
+// electionKey 租约的redis key
+func electionKey(name string) string {
+	return "election:" + name
+}
+
+// Elect runs fn on exactly one node of the cluster, the leader is elected by a redis lease.
+// The ctx of fn is cancelled when the leadership is lost, or the worker is stopped.
+//
+//	Elect 在集群中选举出一个leader执行fn，适用于需要长期运行、且集群中只能有一个实例的任务（比如消息转发、指标聚合）。
+//	基于redis租约实现：leader定期续约，续约失败（租约被抢占，或redis不可用超过租约时间的2/3）时，fn的ctx会被cancel；
+//	fn返回后会释放租约，并重新竞选（所以fn返回或panic后，会由集群中的某个节点重新执行）。
+//	Worker Stop时会cancel fn的ctx，等待fn返回后释放租约，其它节点可以立即接替。
+//	Start之前调用时，会在Start之后开始竞选。
+//	比如：w.Elect(ctx, "stream-relay", func(ctx context.Context) { for { select { case <-ctx.Done(): return; ... } } })
+func (w *Worker) Elect(ctx context.Context, name string, fn job.Job, options ...ElectOption) error {
+	e := &election{
+		name:   name,
+		fn:     w.wrap(fn),
+		ctx:    ctx,
+		token:  w.app.ID() + ":" + uuid.NewString(),
+		ttl:    15 * time.Second,
+		leader: &atomic.Bool{},
+	}
+	for _, option := range options {
+		option(e)
+	}
+	if e.retryInterval <= 0 {
+		e.retryInterval = e.ttl / 3
+	}
+
+	w.elections.mu.Lock()
+	defer w.elections.mu.Unlock()
+	if _, ok := w.elections.elections[name]; ok {
+		return errors.Wrapf(ErrElectionDuplicated, "name: %s", name)
+	}
+	w.elections.elections[name] = e
+	if w.elections.started {
+		w.startElection(e)
+	}
+	return nil
+}
+
+// IsLeader 当前节点是否是name的leader
+func (w *Worker) IsLeader(name string) bool {
+	w.elections.mu.Lock()
+	defer w.elections.mu.Unlock()
+	e, ok := w.elections.elections[name]
+	return ok && e.leader.Load()
+}
+
+func (w *Worker) startElection(e *election) {
+	ctx, cancel := context.WithCancel(e.ctx)
+	e.cancel = cancel
+	e.done = make(chan struct{})
+	go w.campaign(ctx, e)
+}
+
+// startElections 开始所有的竞选
+func (w *Worker) startElections() {
+	w.elections.mu.Lock()
+	defer w.elections.mu.Unlock()
+	if w.elections.started {
+		return
+	}
+	w.elections.started = true
+	for _, e := range w.elections.elections {
+		w.startElection(e)
+	}
+}
+
+// stopElections 停止所有的竞选，等待leader的fn返回并释放租约，ctx超时则不再等待
+func (w *Worker) stopElections(ctx context.Context) {
+	w.elections.mu.Lock()
+	if !w.elections.started {
+		w.elections.mu.Unlock()
+		return
+	}
+	w.elections.started = false
+	elections := make([]*election, 0, len(w.elections.elections))
+	for _, e := range w.elections.elections {
+		e.cancel()
+		elections = append(elections, e)
+	}
+	w.elections.mu.Unlock()
+
+	for _, e := range elections {
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			w.logger.WithContext(ctx).Warnf("[Elect]wait for the leader \"%s\" to resign timeout: %v", e.name, ctx.Err())
+			return
+		}
+	}
+}
+
+// campaign 循环竞选，成为leader后执行fn，直到ctx被cancel
+func (w *Worker) campaign(ctx context.Context, e *election) {
+	defer close(e.done)
+	for {
+		ok, err := w.renewLease(ctx, e)
+		if err != nil && ctx.Err() == nil {
+			w.logger.WithContext(ctx).Errorf("[Elect]campaign for \"%s\" failed: %v", e.name, err)
+		} else if ok {
+			w.lead(ctx, e)
+		}
+
+		timer := time.NewTimer(e.retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// renewLease 获取或续约租约，返回是否持有租约
+func (w *Worker) renewLease(ctx context.Context, e *election) (bool, error) {
+	res, err := w.cache.Script(electCampaignScript).Run(ctx,
+		[]string{electionKey(e.name)},
+		e.token, e.ttl.Milliseconds()).Int()
+	return res == 1, err
+}
+
+// lead 执行fn并定期续约，直到fn返回、失去leader或ctx被cancel，最后释放租约
+func (w *Worker) lead(ctx context.Context, e *election) {
+	logger := w.logger.WithContext(ctx)
+	logger.Infof("[Elect]became the leader of \"%s\"", e.name)
+	e.leader.Store(true)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		if err := callSafely(leaderCtx, func(ctx context.Context) error {
+			e.fn(ctx)
+			return nil
+		}); err != nil {
+			logger.Errorf("[Elect]leader of \"%s\" failed: %v", e.name, err)
+		}
+	}()
+
+	interval := e.ttl / 3
+	ticker := time.NewTicker(interval)
+	renewedAt := time.Now()
+loop:
+	for {
+		select {
+		case <-finished:
+			break loop
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			ok, err := w.renewLease(ctx, e)
+			if err == nil && !ok {
+				logger.Warnf("[Elect]lost the leadership of \"%s\"", e.name)
+				break loop
+			} else if err == nil {
+				renewedAt = time.Now()
+			} else if time.Since(renewedAt)+interval >= e.ttl {
+				// 无法确认租约，在租约过期（其它节点可以获取）之前退出
+				logger.Errorf("[Elect]renew the lease of \"%s\" failed, step down: %v", e.name, err)
+				break loop
+			} else {
+				logger.Warnf("[Elect]renew the lease of \"%s\" failed: %v", e.name, err)
+			}
+		}
+	}
+	ticker.Stop()
+
+	e.leader.Store(false)
+	cancel()
+	<-finished
+
+	// ctx可能已经被cancel，使用不会被cancel的context释放租约
+	if err := w.cache.Script(electResignScript).Run(context.WithoutCancel(ctx),
+		[]string{electionKey(e.name)},
+		e.token).Err(); err != nil {
+		logger.Errorf("[Elect]release the lease of \"%s\" failed: %v", e.name, err)
+		return
+	}
+	logger.Infof("[Elect]resigned the leader of \"%s\"", e.name)
+}
@@ -0,0 +1,24 @@
+package worker
+
+import "time"
+
+type ElectOption func(*election)
+
+// WithLeaseTTL 设置租约的过期时间（默认15s）。
+// leader每ttl/3续约一次，leader宕机后，其它节点最长需要等待ttl才能接替
+func WithLeaseTTL(ttl time.Duration) ElectOption {
+	return func(e *election) {
+		if ttl > 0 {
+			e.ttl = ttl
+		}
+	}
+}
+
+// WithRetryInterval 设置未成为leader时，重新竞选的间隔（默认ttl/3）
+func WithRetryInterval(interval time.Duration) ElectOption {
+	return func(e *election) {
+		if interval > 0 {
+			e.retryInterval = interval
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestElectCampaignScript(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+	key := electionKey("relay")
+
+	campaign := func(token string, ttl time.Duration) int {
+		t.Helper()
+		res, err := c.Script(electCampaignScript).Run(ctx, []string{key}, token, ttl.Milliseconds()).Int()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	if campaign("a", 10*time.Second) != 1 {
+		t.Fatal("expected a acquires the lease")
+	}
+	// 其它token不能续约、抢占
+	if campaign("b", 10*time.Second) != 0 {
+		t.Fatal("expected b can not acquire the lease of a")
+	}
+	if ttl := mr.TTL(key); ttl != 10*time.Second {
+		t.Fatalf("expected the lease is not renewed by b, got %s", ttl)
+	}
+
+	// 相同的token续约
+	mr.FastForward(5 * time.Second)
+	if campaign("a", 10*time.Second) != 1 {
+		t.Fatal("expected a renews the lease")
+	}
+	if ttl := mr.TTL(key); ttl != 10*time.Second {
+		t.Fatalf("expected the lease is renewed, got %s", ttl)
+	}
+
+	// 租约过期后其它节点可以获取，原来的leader不能再续约
+	mr.FastForward(10 * time.Second)
+	if campaign("b", 10*time.Second) != 1 {
+		t.Fatal("expected b acquires the expired lease")
+	}
+	if campaign("a", 10*time.Second) != 0 {
+		t.Fatal("expected a can not renew the lease of b")
+	}
+
+	// 只能释放自己的租约
+	if res, err := c.Script(electResignScript).Run(ctx, []string{key}, "a").Int(); err != nil || res != 0 {
+		t.Fatalf("expected a can not resign the lease of b, got %d %v", res, err)
+	}
+	if res, err := c.Script(electResignScript).Run(ctx, []string{key}, "b").Int(); err != nil || res != 1 {
+		t.Fatalf("expected b resigns the lease, got %d %v", res, err)
+	}
+	if mr.Exists(key) {
+		t.Fatal("expected the lease is deleted")
+	}
+}
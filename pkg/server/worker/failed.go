@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/cache"
+	"time"
+)
+
+// ErrFailedJobNotFound 失败的任务不存在
+var ErrFailedJobNotFound = errors.New("failed job not found")
+
+// FailedJob 超过最大执行次数的任务
+type FailedJob struct {
+	QueuedJob
+	Queue    string    `json:"queue"`
+	Error    string    `json:"error"`
+	Stack    string    `json:"stack,omitempty"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// FailedJobStore 失败任务的存储，可以自行实现为数据库存储
+type FailedJobStore interface {
+	// Add 保存失败的任务
+	Add(ctx context.Context, job *FailedJob) error
+	// List 按失败时间倒序返回失败的任务，以及总数
+	List(ctx context.Context, offset, limit int) ([]*FailedJob, int64, error)
+	// Find 查找失败的任务，不存在时返回ErrFailedJobNotFound
+	Find(ctx context.Context, id string) (*FailedJob, error)
+	// Forget 删除失败的任务
+	Forget(ctx context.Context, ids ...string) error
+	// Flush 删除所有失败的任务
+	Flush(ctx context.Context) error
+}
+
+type redisFailedJobStore struct {
+	cache    *cache.Cache
+	key      string
+	indexKey string
+}
+
+var _ FailedJobStore = (*redisFailedJobStore)(nil)
+
+// NewRedisFailedJobStore 使用redis保存失败的任务：hash保存任务数据，有序集合按失败时间排序
+func NewRedisFailedJobStore(cache *cache.Cache, queueName string) FailedJobStore {
+	return &redisFailedJobStore{
+		cache:    cache,
+		key:      "queue:{" + queueName + "}:failed",
+		indexKey: "queue:{" + queueName + "}:failed:index",
+	}
+}
+
+func (s *redisFailedJobStore) Add(ctx context.Context, job *FailedJob) error {
+	js, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrapf(err, "marshal failed job \"%s\" failed", job.ID)
+	}
+	return s.cache.Script(failedJobAddScript).Run(ctx,
+		[]string{s.key, s.indexKey},
+		job.ID, string(js), job.FailedAt.UnixMilli()).Err()
+}
+
+func (s *redisFailedJobStore) List(ctx context.Context, offset, limit int) ([]*FailedJob, int64, error) {
+	total, err := s.cache.ZCard(ctx, s.indexKey)
+	if err != nil || total == 0 {
+		return nil, total, err
+	}
+	ids, err := s.cache.ZRevRange(ctx, s.indexKey, int64(offset), int64(offset+limit-1))
+	if err != nil || len(ids) == 0 {
+		return nil, total, err
+	}
+
+	jobs := make([]*FailedJob, 0, len(ids))
+	for _, id := range ids {
+		job, err := s.Find(ctx, id)
+		if errors.Is(err, ErrFailedJobNotFound) {
+			continue
+		} else if err != nil {
+			return nil, total, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, total, nil
+}
+
+func (s *redisFailedJobStore) Find(ctx context.Context, id string) (*FailedJob, error) {
+	js, err := s.cache.HGet(ctx, s.key, id, nil)
+	if err != nil {
+		return nil, err
+	} else if js == "" {
+		return nil, errors.Wrapf(ErrFailedJobNotFound, "id: %s", id)
+	}
+
+	job := &FailedJob{}
+	if err = json.Unmarshal([]byte(js), job); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal failed job \"%s\" failed", id)
+	}
+	return job, nil
+}
+
+func (s *redisFailedJobStore) Forget(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	members := make([]any, 0, len(ids))
+	for _, id := range ids {
+		members = append(members, id)
+	}
+	if _, err := s.cache.HDel(ctx, s.key, ids...); err != nil {
+		return err
+	}
+	_, err := s.cache.ZRem(ctx, s.indexKey, members...)
+	return err
+}
+
+func (s *redisFailedJobStore) Flush(ctx context.Context) error {
+	_, err := s.cache.Del(ctx, s.key, s.indexKey)
+	return err
+}
+
+// failedJobAddScript 保存失败的任务
+//
+//	KEYS: failed, failed:index
+//	ARGV: id, data, failed_at(ms)
+const failedJobAddScript = `redis.call('hset', KEYS[1], ARGV[1], ARGV[2])
+redis.call('zadd', KEYS[2], ARGV[3], ARGV[1])
+return 1
+`
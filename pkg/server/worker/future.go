@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// Future 异步任务的结果，通过SubmitFuture创建
+type Future[T any] struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	result T
+	err    error
+}
+
+// SubmitFuture submits a job to the worker and returns a future of its result.
+// It's a NON-BLOCKING call, the job does not occupy the caller, use Wait to get the result.
+//
+//	SubmitFuture 提交一个有返回值的任务给worker执行，这是一个【异步】调用，返回的Future可以等待结果、取消任务。
+//	（与Submit一样，job使用的ctx从ctx中复制requestid等信息，但不会因为ctx的cancel而cancel，需要时可以调用Future.Cancel）
+//	fn panic时，Wait返回*PanicError
+//	比如：f := worker.SubmitFuture(w, ctx, func(ctx context.Context) (*Report, error) {...}); report, err := f.Wait(ctx)
+func SubmitFuture[T any](w *Worker, ctx context.Context, fn func(ctx context.Context) (T, error)) *Future[T] {
+	jobCtx, cancel := context.WithCancel(w.app.CloneContextFromBase(ctx))
+	f := &Future[T]{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	wrapped := w.wrap(func(ctx context.Context) {
+		var result T
+		var err error
+		// 在执行之前已经被取消
+		if err = ctx.Err(); err == nil {
+			err = callSafely(ctx, func(ctx context.Context) error {
+				result, err = fn(ctx)
+				return err
+			})
+		}
+		f.resolve(result, err)
+	})
+	if !w.pool.Submit(w.poolName, func() {
+		// middleware可能没有调用job（比如OnceForCluster跳过、限流等），此时结果为零值
+		defer f.resolve(*new(T), nil)
+		defer cancel()
+		wrapped(jobCtx)
+	}) {
+		cancel()
+		f.resolve(*new(T), ErrWorkerStopped)
+	}
+	return f
+}
+
+// resolve 设置结果，只有第一次调用有效
+func (f *Future[T]) resolve(result T, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.done:
+		return
+	default:
+	}
+	f.result, f.err = result, err
+	close(f.done)
+}
+
+// Done 任务完成（包括失败、取消）时关闭的channel
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待任务完成，并返回结果。ctx结束时返回ctx.Err()，但不会取消任务
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.Result()
+	case <-ctx.Done():
+		return *new(T), ctx.Err()
+	}
+}
+
+// Result 返回任务的结果，任务未完成时返回零值和nil
+func (f *Future[T]) Result() (T, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.result, f.err
+}
+
+// Cancel 取消任务：任务未开始时不再执行（结果为context.Canceled），执行中时cancel其ctx
+func (f *Future[T]) Cancel() {
+	f.cancel()
+}
@@ -11,6 +11,11 @@ import (
 type onceWorker struct {
 	key    string
 	worker *Worker
+
+	// mode 去重的方式，默认为OnceForCluster
+	mode onceMode
+	// interval Unique的ttl、Debounce的wait、Throttle的interval
+	interval time.Duration
 }
 
 var _ IWorker = (*onceWorker)(nil)
@@ -150,13 +155,20 @@ func (w *onceWorker) wrapperOnceCronJob(key string, spec cron.Schedule, job job.
 	}
 }
 
-func (w *onceWorker) WithContext(ctx context.Context) IWorker {
+// derive 返回使用worker的onceWorker，保留key和去重方式
+func (w *onceWorker) derive(worker *Worker) *onceWorker {
 	return &onceWorker{
-		key:    w.key,
-		worker: w.worker.WithContext(ctx).(*Worker),
+		key:      w.key,
+		worker:   worker,
+		mode:     w.mode,
+		interval: w.interval,
 	}
 }
 
+func (w *onceWorker) WithContext(ctx context.Context) IWorker {
+	return w.derive(w.worker.WithContext(ctx).(*Worker))
+}
+
 // OnceForCluster 表示在后面调用的job只会在集群中执行一次。
 // 如果是cron任务，表示在每次定时任务触发时只在一个节点执行。
 //
@@ -165,31 +177,104 @@ func (w *onceWorker) OnceForCluster(key string, options ...onceOption) IWorker {
 	return w.worker.OnceForCluster(key, options...)
 }
 
+func (w *onceWorker) Unique(key string, ttl time.Duration, options ...onceOption) IWorker {
+	return w.worker.Unique(key, ttl, options...)
+}
+
+func (w *onceWorker) Debounce(key string, wait time.Duration, options ...onceOption) IWorker {
+	return w.worker.Debounce(key, wait, options...)
+}
+
+func (w *onceWorker) Throttle(key string, interval time.Duration, options ...onceOption) IWorker {
+	return w.worker.Throttle(key, interval, options...)
+}
+
+func (w *onceWorker) Named(name string) IWorker {
+	return w.derive(w.worker.Named(name).(*Worker))
+}
+
+func (w *onceWorker) OnPool(name string) IWorker {
+	return w.derive(w.worker.OnPool(name).(*Worker))
+}
+
+func (w *onceWorker) Wrap(wrappers ...job.JobWrapper) IWorker {
+	return w.derive(w.worker.Wrap(wrappers...).(*Worker))
+}
+
 func (w *onceWorker) Submit(job job.Job) {
+	if w.mode != onceModeCluster {
+		w.dispatch(job)
+		return
+	}
 	w.worker.Submit(w.wrapperOnceJob(w.key, job))
 }
 
 func (w *onceWorker) SubmitWait(job job.Job) {
-	w.worker.SubmitWait(w.wrapperOnceJob(w.key, job))
+	switch w.mode {
+	case onceModeCluster:
+		w.worker.SubmitWait(w.wrapperOnceJob(w.key, job))
+	case onceModeUnique:
+		if release, ok := w.acquireUnique(w.interval); ok {
+			w.worker.SubmitWait(w.wrapperUniqueJob(release, job))
+		}
+	default: // Debounce、Throttle的job会延迟执行或被合并，不等待
+		w.dispatch(job)
+	}
 }
 
 func (w *onceWorker) SubmitAfter(delay time.Duration, job job.Job) {
-	w.worker.SubmitAfter(delay, w.wrapperOnceJob(w.key, job))
-}
-
-func (w *onceWorker) SubmitWithError(job job.JobWithError) error {
-	return w.worker.SubmitWithError(w.wrapperOnceJobWithError(w.key, job))
+	switch w.mode {
+	case onceModeCluster:
+		w.worker.SubmitAfter(delay, w.wrapperOnceJob(w.key, job))
+	case onceModeUnique:
+		// 提交时就占用key，延迟期间重复提交的job也会被丢弃
+		if release, ok := w.acquireUnique(delay + w.interval); ok {
+			w.worker.SubmitAfter(delay, w.wrapperUniqueJob(release, job))
+		}
+	default:
+		w.worker.timeWheel.AfterFunc(delay, func() {
+			w.dispatch(job)
+		})
+	}
 }
 
-func (w *onceWorker) Cron(spec any, job job.Job) (cron.EntryID, error) {
-	// 将spec转换为cron.Schedule
-	_schedule, err := w.worker.parseSchedule(spec)
-	if err != nil {
-		w.worker.logger.Errorf("[Cron]parse schedule %v failed: %v", spec, err)
-		return 0, err
+func (w *onceWorker) SubmitWithError(j job.JobWithError) error {
+	switch w.mode {
+	case onceModeCluster:
+		return w.worker.SubmitWithError(w.wrapperOnceJobWithError(w.key, j))
+	case onceModeUnique:
+		release, ok := w.acquireUnique(w.interval)
+		if !ok {
+			return nil
+		}
+		return w.worker.SubmitWithError(func(ctx context.Context) error {
+			defer release(ctx)
+			return j(ctx)
+		})
+	default: // Debounce、Throttle的job会延迟执行或被合并，不等待，error只记录日志
+		w.dispatch(func(ctx context.Context) {
+			if err := j(ctx); err != nil {
+				w.worker.logger.WithContext(ctx).Errorf("[JobWithError]job of key \"%s\" failed: %v", w.key, err)
+			}
+		})
+		return nil
 	}
+}
 
-	return w.worker.Cron(_schedule, w.wrapperOnceCronJob(w.key, _schedule, job))
+func (w *onceWorker) Cron(spec any, j job.Job) (cron.EntryID, error) {
+	return w.worker.addCron(spec, j, w.key, func(schedule cron.Schedule, j job.Job) job.Job {
+		switch w.mode {
+		case onceModeUnique:
+			// 上一次执行还没有结束（集群中任意节点）时跳过
+			return w.wrapperUniqueCronJob(j)
+		case onceModeDebounce, onceModeThrottle:
+			// 每个interval在集群中最多执行一次
+			return w.wrapperThrottleCronJob(j)
+		default:
+			// 使用wrapperOnceCronJob，保证在每次定时任务触发时只在一个节点执行
+			return w.wrapperOnceCronJob(w.key, schedule, j)
+		}
+	})
 }
 
 func (w *onceWorker) CronWith(job job.Job) schedule.Spec {
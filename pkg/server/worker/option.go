@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/metrics"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/job"
+)
+
+type Option func(*Worker)
+
+// WithMiddleware 设置所有job（Submit、SubmitAfter、Cron等）都会使用的wrapper，按顺序从外到内执行
+// example: worker.NewWorker(app, logger, cache, 100, worker.WithMiddleware(job.Recover(logger), job.RequestID(), job.Logging(logger)))
+func WithMiddleware(wrappers ...job.JobWrapper) Option {
+	return func(w *Worker) {
+		w.middleware = append(w.middleware, wrappers...)
+	}
+}
+
+// WithPool 设置pool的并发数上限（0表示只受总并发数限制）和权重，通过OnPool选择pool
+// example: worker.NewWorker(app, logger, cache, 100, worker.WithPool("critical", 0, 10), worker.WithPool("bulk", 20, 1))
+func WithPool(name string, concurrency, weight int) Option {
+	return func(w *Worker) {
+		w.pool.Configure(name, concurrency, weight)
+	}
+}
+
+// WithPoolMetrics 采集pool的Prometheus指标：
+//   - worker_pool_depth{pool}：等待执行的job数量
+//   - worker_pool_running{pool}：执行中的job数量
+//   - worker_pool_wait_sec{pool}：job从提交到开始执行的等待时间直方图
+func WithPoolMetrics(reg *metrics.Metrics) Option {
+	return func(w *Worker) {
+		reg = reg.WithSubsystem("worker_pool")
+		w.pool.mu.Lock()
+		defer w.pool.mu.Unlock()
+		w.pool.metrics = &poolMetrics{
+			depth: reg.WithHelp("The number of jobs waiting in the pool").
+				RegisterGaugeVec("depth", "pool"),
+			running: reg.WithHelp("The number of running jobs in the pool").
+				RegisterGaugeVec("running", "pool"),
+			wait: reg.WithHelp("job waiting duration(sec) in the pool.").
+				RegisterHistogramVec("wait_sec", []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60}, "pool"),
+		}
+	}
+}
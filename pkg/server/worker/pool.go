@@ -0,0 +1,301 @@
+package worker
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/metrics"
+	"sync"
+	"time"
+)
+
+// ErrWorkerStopped Worker已经停止，提交的job不会执行
+var ErrWorkerStopped = errors.New("worker is stopped")
+
+// DefaultPool 默认的pool名称，没有通过OnPool指定时使用
+const DefaultPool = "default"
+
+// PoolStats pool的统计信息
+type PoolStats struct {
+	Name string `json:"name"`
+	// Concurrency 并发数上限，0表示只受Worker总并发数的限制
+	Concurrency int `json:"concurrency"`
+	Weight      int `json:"weight"`
+	Running     int `json:"running"`
+	// Waiting 等待执行的任务数（队列深度）
+	Waiting   int    `json:"waiting"`
+	Submitted uint64 `json:"submitted"`
+	Completed uint64 `json:"completed"`
+	// AvgWait 任务从提交到开始执行的平均等待时间
+	AvgWait time.Duration `json:"avg_wait"`
+}
+
+// PoolConfig pool的配置，可以在配置变更时通过Worker.ApplyPoolConfig应用
+type PoolConfig struct {
+	// MaxWorkers Worker总的并发数，<= 0 表示不修改
+	MaxWorkers int               `yaml:"max_workers" validate:"min=0"`
+	Pools      []NamedPoolConfig `yaml:"pools"`
+}
+
+// NamedPoolConfig 单个pool的配置
+type NamedPoolConfig struct {
+	Name string `yaml:"name" validate:"required"`
+	// Concurrency 并发数上限，0表示只受Worker总并发数的限制
+	Concurrency int `yaml:"concurrency" validate:"min=0"`
+	// Weight 权重，有空闲协程时按权重比例选择等待中的pool，最小为1
+	Weight int `yaml:"weight" validate:"min=0"`
+}
+
+type poolTask struct {
+	fn         func()
+	enqueuedAt time.Time
+}
+
+type namedPool struct {
+	name        string
+	concurrency int
+	weight      int
+	// currentWeight 平滑加权轮询的当前权重
+	currentWeight int
+
+	tasks   []*poolTask
+	running int
+
+	submitted uint64
+	completed uint64
+	waitTotal time.Duration
+}
+
+type poolMetrics struct {
+	depth   *metrics.GaugeVec
+	running *metrics.GaugeVec
+	wait    *metrics.HistogramVec
+}
+
+// priorityPool 按名称划分的任务池：所有pool共享总并发数size，每个pool可以限制自己的并发数，
+// 有空闲的协程时，按权重（平滑加权轮询）从等待中的pool选择下一个任务，避免低优先级的任务占满协程。
+type priorityPool struct {
+	mu      sync.Mutex
+	size    int
+	running int
+	pools   map[string]*namedPool
+	names   []string
+	metrics *poolMetrics
+
+	// closed Close之后不再接受新的任务
+	closed bool
+	// drained Close之后，等待中和执行中的任务都执行完毕时关闭
+	drained chan struct{}
+}
+
+func newPriorityPool(size int) *priorityPool {
+	p := &priorityPool{
+		size:  max(size, 1),
+		pools: map[string]*namedPool{},
+	}
+	p.getPool(DefaultPool)
+	return p
+}
+
+// getPool 返回名为name的pool，不存在时创建（不限制并发数，权重为1）。需要持有锁
+func (p *priorityPool) getPool(name string) *namedPool {
+	if name == "" {
+		name = DefaultPool
+	}
+	np, ok := p.pools[name]
+	if !ok {
+		np = &namedPool{name: name, weight: 1}
+		p.pools[name] = np
+		p.names = append(p.names, name)
+	}
+	return np
+}
+
+// Size 总并发数
+func (p *priorityPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// Resize 修改总并发数，减小时执行中的任务不受影响
+func (p *priorityPool) Resize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.size = max(size, 1)
+	p.dispatch()
+}
+
+// Configure 设置pool的并发数（0表示只受总并发数限制）和权重（最小为1）
+func (p *priorityPool) Configure(name string, concurrency, weight int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	np := p.getPool(name)
+	np.concurrency = max(concurrency, 0)
+	np.weight = max(weight, 1)
+	p.dispatch()
+}
+
+// Submit 提交任务到名为name的pool，pool已经关闭时丢弃任务并返回false
+func (p *priorityPool) Submit(name string, fn func()) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	np := p.getPool(name)
+	np.tasks = append(np.tasks, &poolTask{fn: fn, enqueuedAt: time.Now()})
+	np.submitted++
+	if p.metrics != nil {
+		p.metrics.depth.WithLabelValues(np.name).Inc()
+	}
+	p.dispatch()
+	return true
+}
+
+// SubmitWait 提交任务到名为name的pool，并等待执行完毕，pool已经关闭时返回false
+func (p *priorityPool) SubmitWait(name string, fn func()) bool {
+	done := make(chan struct{})
+	if !p.Submit(name, func() {
+		defer close(done)
+		fn()
+	}) {
+		return false
+	}
+	<-done
+	return true
+}
+
+// Open 重新接受新的任务（Worker Stop之后再次Start）
+func (p *priorityPool) Open() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = false
+}
+
+// Close 不再接受新的任务，返回等待中和执行中的任务都执行完毕时关闭的channel
+func (p *priorityPool) Close() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.drained = make(chan struct{})
+		p.checkDrained()
+	}
+	return p.drained
+}
+
+// StopWait 不再接受新的任务，并等待所有任务（包括等待中的）执行完毕。ctx结束时不再等待，返回ctx.Err()
+func (p *priorityPool) StopWait(ctx context.Context) error {
+	select {
+	case <-p.Close():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// checkDrained 已经关闭，并且所有任务都执行完毕时关闭drained。需要持有锁
+func (p *priorityPool) checkDrained() {
+	if !p.closed || p.running > 0 || p.waiting() > 0 {
+		return
+	}
+	select {
+	case <-p.drained:
+	default:
+		close(p.drained)
+	}
+}
+
+// waiting 等待中的任务数。需要持有锁
+func (p *priorityPool) waiting() int {
+	var n int
+	for _, np := range p.pools {
+		n += len(np.tasks)
+	}
+	return n
+}
+
+// next 按平滑加权轮询选择下一个可以执行的pool，没有时返回nil。需要持有锁
+func (p *priorityPool) next() *namedPool {
+	var best *namedPool
+	var total int
+	for _, name := range p.names {
+		np := p.pools[name]
+		if len(np.tasks) == 0 || (np.concurrency > 0 && np.running >= np.concurrency) {
+			continue
+		}
+		np.currentWeight += np.weight
+		total += np.weight
+		if best == nil || np.currentWeight > best.currentWeight {
+			best = np
+		}
+	}
+	if best != nil {
+		best.currentWeight -= total
+	}
+	return best
+}
+
+// dispatch 有空闲的协程时执行等待中的任务。需要持有锁
+func (p *priorityPool) dispatch() {
+	for p.running < p.size {
+		np := p.next()
+		if np == nil {
+			return
+		}
+		task := np.tasks[0]
+		np.tasks[0] = nil
+		np.tasks = np.tasks[1:]
+
+		wait := time.Since(task.enqueuedAt)
+		np.waitTotal += wait
+		np.running++
+		p.running++
+		if p.metrics != nil {
+			p.metrics.depth.WithLabelValues(np.name).Dec()
+			p.metrics.running.WithLabelValues(np.name).Inc()
+			p.metrics.wait.WithLabelValues(np.name).Observe(wait.Seconds())
+		}
+		go p.run(np, task)
+	}
+}
+
+func (p *priorityPool) run(np *namedPool, task *poolTask) {
+	defer func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		np.running--
+		np.completed++
+		p.running--
+		if p.metrics != nil {
+			p.metrics.running.WithLabelValues(np.name).Dec()
+		}
+		p.dispatch()
+		p.checkDrained()
+	}()
+	task.fn()
+}
+
+// Stats 返回所有pool的统计信息
+func (p *priorityPool) Stats() []PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]PoolStats, 0, len(p.names))
+	for _, name := range p.names {
+		np := p.pools[name]
+		s := PoolStats{
+			Name:        np.name,
+			Concurrency: np.concurrency,
+			Weight:      np.weight,
+			Running:     np.running,
+			Waiting:     len(np.tasks),
+			Submitted:   np.submitted,
+			Completed:   np.completed,
+		}
+		if started := np.submitted - uint64(len(np.tasks)); started > 0 {
+			s.AvgWait = np.waitTotal / time.Duration(started)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pick 不执行任务，按next的选择依次取出n个任务，返回选中的pool名称
+func pick(p *priorityPool, n int) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var names []string
+	for i := 0; i < n; i++ {
+		np := p.next()
+		if np == nil {
+			break
+		}
+		np.tasks = np.tasks[1:]
+		names = append(names, np.name)
+	}
+	return names
+}
+
+// enqueue 直接添加n个等待中的任务，不触发dispatch
+func enqueue(p *priorityPool, name string, n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	np := p.getPool(name)
+	for i := 0; i < n; i++ {
+		np.tasks = append(np.tasks, &poolTask{fn: func() {}, enqueuedAt: time.Now()})
+	}
+}
+
+func TestPriorityPoolNext(t *testing.T) {
+	p := newPriorityPool(1)
+	p.Configure("high", 0, 3)
+	p.Configure("low", 0, 1)
+	enqueue(p, "high", 100)
+	enqueue(p, "low", 100)
+
+	// 平滑加权轮询：high:low = 3:1，并且low不会连续等待3次以上
+	names := pick(p, 8)
+	expected := []string{"high", "high", "low", "high", "high", "high", "low", "high"}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, names)
+		}
+	}
+
+	// 只有一个pool有任务时，一直选择该pool
+	p = newPriorityPool(1)
+	p.Configure("high", 0, 3)
+	enqueue(p, "low", 3)
+	if names = pick(p, 5); len(names) != 3 || names[2] != "low" {
+		t.Fatalf("expected 3 low tasks, got %v", names)
+	}
+}
+
+func TestPriorityPoolNextConcurrency(t *testing.T) {
+	p := newPriorityPool(10)
+	p.Configure("high", 1, 10)
+	enqueue(p, "high", 3)
+	enqueue(p, "low", 3)
+
+	// high达到并发数上限时跳过，即使权重更高
+	p.mu.Lock()
+	p.pools["high"].running = 1
+	p.mu.Unlock()
+	if names := pick(p, 5); len(names) != 3 || names[0] != "low" || names[2] != "low" {
+		t.Fatalf("expected only low tasks, got %v", names)
+	}
+
+	p.mu.Lock()
+	p.pools["high"].running = 0
+	p.mu.Unlock()
+	if names := pick(p, 1); len(names) != 1 || names[0] != "high" {
+		t.Fatalf("expected high is picked after it is idle, got %v", names)
+	}
+}
+
+func TestPriorityPoolSubmit(t *testing.T) {
+	p := newPriorityPool(4)
+	p.Configure("limited", 1, 1)
+
+	var running, maxRunning atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		p.Submit("limited", func() {
+			defer wg.Done()
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+		})
+	}
+	wg.Wait()
+	if maxRunning.Load() != 1 {
+		t.Fatalf("expected at most 1 running task of the limited pool, got %d", maxRunning.Load())
+	}
+
+	if err := p.StopWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, stats := range p.Stats() {
+		if stats.Name == "limited" && (stats.Submitted != 10 || stats.Completed != 10 || stats.Waiting != 0) {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+	}
+}
+
+func TestPriorityPoolStopWait(t *testing.T) {
+	p := newPriorityPool(1)
+	release := make(chan struct{})
+	var executed atomic.Int32
+	for i := 0; i < 2; i++ {
+		if !p.Submit(DefaultPool, func() {
+			<-release
+			executed.Add(1)
+		}) {
+			t.Fatal("expected the task is accepted")
+		}
+	}
+
+	// 关闭后丢弃新的任务，ctx结束时不再等待
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.StopWait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if p.Submit(DefaultPool, func() {}) || p.SubmitWait(DefaultPool, func() {}) {
+		t.Fatal("expected the task is rejected after closed")
+	}
+
+	// 等待中的任务仍然会执行完毕
+	close(release)
+	if err := p.StopWait(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if executed.Load() != 2 {
+		t.Fatalf("expected 2 tasks are executed, got %d", executed.Load())
+	}
+
+	p.Open()
+	if !p.SubmitWait(DefaultPool, func() {}) {
+		t.Fatal("expected the task is accepted after reopened")
+	}
+}
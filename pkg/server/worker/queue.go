@@ -0,0 +1,621 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-kratos/kratos/v2/encoding"
+	_ "github.com/go-kratos/kratos/v2/encoding/json"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/app"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/cache"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/redis"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/requestid"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnknownJob 任务没有注册
+var ErrUnknownJob = errors.New("unknown job")
+
+// QueuedJob 持久化在redis中的任务
+type QueuedJob struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Payload []byte `json:"payload"`
+	// 已取出执行的次数（包含本次）
+	Attempts int `json:"attempts"`
+	// Deferred 因为取出的节点没有注册该任务而放回的次数（不计入Attempts）
+	Deferred     int       `json:"deferred,omitempty"`
+	DispatchedAt time.Time `json:"dispatched_at"`
+	RequestID    string    `json:"request_id,omitempty"`
+	// BatchID 所属的批次（Queue.Batch）
+	BatchID string `json:"batch_id,omitempty"`
+	// Chain 执行成功后依次执行的任务（Queue.Chain）
+	Chain []ChainedJob `json:"chain,omitempty"`
+}
+
+type queueHandler struct {
+	codec       encoding.Codec
+	maxAttempts int
+	backoff     Backoff
+	timeout     time.Duration
+	// handler 返回json编码的结果（Register注册的任务为nil）
+	handler func(ctx context.Context, payload []byte) ([]byte, error)
+}
+
+// defaultBackoff 默认的重试等待时间
+var defaultBackoff = WithJitter(ExponentialBackoff(time.Second, 10*time.Minute), 0.5)
+
+// Queue 基于redis的持久化任务队列。
+// 任务在Dispatch时写入redis，延迟任务存放在有序集合中，到期后由调度协程移动到就绪列表，集群中任意节点都可以取出执行。
+// 任务执行期间会定时延长可见性超时，超过可见性超时没有延长（比如节点宕机、重启），会重新放回就绪列表，所以任务至少执行一次，handler需要保证幂等。
+// 任务返回error或panic时，按注册时的重试策略放回延迟集合，超过最大执行次数后保存到FailedJobStore。
+//
+//	queue := worker.NewQueue(app, logger, cache)
+//	worker.Register(queue, "send_email", func(ctx context.Context, p *EmailPayload) error {...})
+//	queue.Dispatch(ctx, "send_email", &EmailPayload{...}, worker.Delay(10*time.Minute))
+type Queue struct {
+	app    *app.App
+	logger *log.Helper
+	cache  *cache.Cache
+	clock  *serverClock
+
+	name              string
+	concurrency       int
+	pollInterval      time.Duration
+	visibilityTimeout time.Duration
+	codec             encoding.Codec
+	failedStore       FailedJobStore
+	// statusTTL 任务状态的保留时间，<= 0 表示不记录
+	statusTTL time.Duration
+	// unknownJobAttempts 没有注册的任务最多执行的次数，<= 0 表示一直放回延迟集合等待注册
+	unknownJobAttempts int
+
+	mu       sync.RWMutex
+	handlers map[string]*queueHandler
+
+	running *atomic.Bool
+	stop    chan struct{}
+	// done 所有协程退出后关闭
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+var _ transport.Server = (*Queue)(nil)
+
+func NewQueue(
+	app *app.App,
+	logger log.Logger,
+	cache *cache.Cache,
+
+	options ...QueueOption,
+) *Queue {
+	q := &Queue{
+		app:    app,
+		logger: log.NewModuleHelper(logger, "worker/queue"),
+		cache:  cache,
+
+		name:              "default",
+		concurrency:       10,
+		pollInterval:      time.Second,
+		visibilityTimeout: 5 * time.Minute,
+		codec:             encoding.GetCodec("json"),
+		statusTTL:         24 * time.Hour,
+
+		handlers: map[string]*queueHandler{},
+		running:  &atomic.Bool{},
+	}
+	for _, option := range options {
+		option(q)
+	}
+	if q.failedStore == nil {
+		q.failedStore = NewRedisFailedJobStore(q.cache, q.name)
+	}
+	q.clock = newServerClock(q.cache, serverClockRefreshInterval)
+	return q
+}
+
+// Register 注册名为name的任务，payload使用codec解码为P后调用handler。
+// 所有执行该队列的节点都需要注册，Dispatch的节点注册后会使用相同的codec编码
+// example: worker.Register(queue, "send_email", func(ctx context.Context, p *EmailPayload) error {...})
+func Register[P any](q *Queue, name string, handler func(ctx context.Context, payload P) error, options ...RegisterOption) {
+	RegisterWithResult(q, name, func(ctx context.Context, payload P) (any, error) {
+		return nil, handler(ctx, payload)
+	}, options...)
+}
+
+// RegisterWithResult 注册名为name的有返回值的任务，结果使用json编码后保存在任务状态中，
+// 可以通过Queue.Status、JobResult查询（结果的保留时间见WithStatusTTL）
+// example: worker.RegisterWithResult(queue, "export", func(ctx context.Context, p *ExportPayload) (*ExportResult, error) {...})
+func RegisterWithResult[P, R any](q *Queue, name string, handler func(ctx context.Context, payload P) (R, error), options ...RegisterOption) {
+	h := &queueHandler{codec: q.codec, maxAttempts: 1, backoff: defaultBackoff}
+	for _, option := range options {
+		option(h)
+	}
+
+	typ := reflect.TypeOf((*P)(nil)).Elem()
+	h.handler = func(ctx context.Context, data []byte) ([]byte, error) {
+		// P为指针时，需要新建其指向的对象
+		var payload P
+		var target any = &payload
+		if typ.Kind() == reflect.Ptr {
+			payload = reflect.New(typ.Elem()).Interface().(P)
+			target = payload
+		}
+		if len(data) > 0 {
+			if err := h.codec.Unmarshal(data, target); err != nil {
+				return nil, errors.Wrapf(err, "unmarshal payload of job \"%s\" failed", name)
+			}
+		}
+		result, err := handler(ctx, payload)
+		if err != nil {
+			return nil, err
+		} else if any(result) == nil {
+			return nil, nil
+		}
+		js, err := json.Marshal(result)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal result of job \"%s\" failed", name)
+		}
+		return js, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[name] = h
+}
+
+func (q *Queue) getHandler(name string) (*queueHandler, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	h, ok := q.handlers[name]
+	return h, ok
+}
+
+// key 队列相关的redis key，使用hash tag保证在redis集群中位于同一个slot（lua脚本要求）
+func (q *Queue) key(kind string) string {
+	return "queue:{" + q.name + "}:" + kind
+}
+
+// Dispatch 将任务写入队列，返回任务ID。payload使用注册时的codec编码（未注册则使用队列默认的codec）
+// example: queue.Dispatch(ctx, "send_email", &EmailPayload{...}, worker.Delay(10*time.Minute))
+func (q *Queue) Dispatch(ctx context.Context, name string, payload any, options ...DispatchOption) (string, error) {
+	data, err := q.marshalPayload(name, payload)
+	if err != nil {
+		return "", err
+	}
+	job := q.newJob(ctx, name, data)
+	if err = q.dispatch(ctx, job, options...); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// marshalPayload 使用注册时的codec编码payload（未注册则使用队列默认的codec）
+func (q *Queue) marshalPayload(name string, payload any) ([]byte, error) {
+	codec := q.codec
+	if h, ok := q.getHandler(name); ok {
+		codec = h.codec
+	}
+	data, err := codec.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "marshal payload of job \"%s\" failed", name)
+	}
+	return data, nil
+}
+
+func (q *Queue) newJob(ctx context.Context, name string, payload []byte) *QueuedJob {
+	return &QueuedJob{
+		ID:           uuid.New().String(),
+		Name:         name,
+		Payload:      payload,
+		DispatchedAt: time.Now(),
+		RequestID:    requestid.FromContext(ctx),
+	}
+}
+
+// dispatch 记录任务状态，并写入队列
+func (q *Queue) dispatch(ctx context.Context, job *QueuedJob, options ...DispatchOption) error {
+	opts := &dispatchOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	// 延迟任务的执行时间以redis服务器时间为准，避免节点之间的时钟误差
+	var runAt int64
+	var delay time.Duration
+	if opts.delay > 0 || !opts.runAt.IsZero() {
+		delta := q.clock.Delta(ctx)
+		if !opts.runAt.IsZero() {
+			runAt = opts.runAt.Add(delta).UnixMilli()
+			delay = time.Until(opts.runAt)
+		} else {
+			runAt = time.Now().Add(delta).Add(opts.delay).UnixMilli()
+			delay = opts.delay
+		}
+	}
+
+	// 先记录状态再写入队列，避免任务在记录之前就已经执行
+	q.setStatus(ctx, job, JobPending, q.statusTTL+max(delay, 0))
+	if err := q.push(ctx, job, runAt); err != nil {
+		return errors.Wrapf(err, "dispatch job \"%s\" to queue \"%s\" failed", job.Name, q.name)
+	}
+	return nil
+}
+
+// push 写入任务，runAt(ms) > 0 时放入延迟集合
+func (q *Queue) push(ctx context.Context, job *QueuedJob, runAt int64) error {
+	js, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrapf(err, "marshal job \"%s\" failed", job.Name)
+	}
+	return q.cache.Script(queuePushScript).Run(ctx,
+		[]string{q.key("jobs"), q.key("ready"), q.key("delayed")},
+		job.ID, string(js), runAt).Err()
+}
+
+// Size 返回就绪、延迟、执行中的任务数量
+func (q *Queue) Size(ctx context.Context) (ready, delayed, reserved int64, err error) {
+	if ready, err = q.cache.LLen(ctx, q.key("ready")); err != nil {
+		return
+	}
+	if delayed, err = q.cache.ZCard(ctx, q.key("delayed")); err != nil {
+		return
+	}
+	reserved, err = q.cache.ZCard(ctx, q.key("reserved"))
+	return
+}
+
+// migrate 将到期的延迟任务、可见性超时的任务移动到就绪列表
+func (q *Queue) migrate(ctx context.Context) {
+	now := q.clock.Now(ctx).UnixMilli()
+	for _, from := range []string{"delayed", "reserved"} {
+		moved, err := q.cache.Script(queueMigrateScript).Run(ctx,
+			[]string{q.key(from), q.key("ready")},
+			now, 100).Int()
+		if err != nil {
+			q.logger.WithContext(ctx).Errorf("[Queue]migrate %s jobs of queue \"%s\" failed: %v", from, q.name, err)
+		} else if moved > 0 && from == "reserved" {
+			q.logger.WithContext(ctx).Warnf("[Queue]%d jobs of queue \"%s\" exceeded the visibility timeout, released to ready", moved, q.name)
+		}
+	}
+}
+
+// reserve 从就绪列表取出一个任务，并在可见性超时之前标记为执行中。队列为空时返回nil
+func (q *Queue) reserve(ctx context.Context) (*QueuedJob, error) {
+	deadline := q.clock.Now(ctx).Add(q.visibilityTimeout).UnixMilli()
+	res, err := q.cache.Script(queueReserveScript).Run(ctx,
+		[]string{q.key("ready"), q.key("reserved"), q.key("jobs")},
+		deadline).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	job := &QueuedJob{}
+	if err = json.Unmarshal([]byte(res), job); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal job of queue \"%s\" failed", q.name)
+	}
+	return job, nil
+}
+
+// ack 任务完成，从队列中删除
+func (q *Queue) ack(ctx context.Context, job *QueuedJob) error {
+	return q.cache.Script(queueAckScript).Run(ctx,
+		[]string{q.key("reserved"), q.key("jobs")},
+		job.ID).Err()
+}
+
+// process 执行任务，失败时根据重试策略放回延迟集合，或者保存到FailedJobStore
+func (q *Queue) process(job *QueuedJob) {
+	ctx := requestid.NewContext(q.app.BaseContext(), job.RequestID)
+
+	ctx = context.WithValue(ctx, queueJobKey{}, &queueJob{queue: q, job: job})
+
+	h, ok := q.getHandler(job.Name)
+	if !ok {
+		q.deferUnknown(ctx, job)
+		return
+	}
+
+	// 批次已经取消（CancelBatch，或者不允许失败的批次中有任务失败）时跳过
+	if job.BatchID != "" && q.batchCancelled(ctx, job.BatchID) {
+		q.setStatus(ctx, job, JobCancelled, q.statusTTL)
+		q.recordBatch(ctx, job, batchJobCancelled)
+		q.ackOrLog(ctx, job)
+		return
+	}
+
+	// 执行期间定时延长可见性超时，避免执行时间较长的任务被重复执行
+	stopHeartbeat := q.heartbeat(ctx, job)
+	defer stopHeartbeat()
+
+	q.setStatus(ctx, job, JobRunning, q.statusTTL, "error", "")
+	result, err := q.handle(ctx, h, job)
+	if err != nil {
+		q.fail(ctx, h, job, err)
+		return
+	}
+	// 先写入下一个任务再ack，避免节点在两者之间宕机导致链条中断；写入失败时按失败处理，重试后再次写入
+	if len(job.Chain) > 0 {
+		if err = q.dispatchNextInChain(ctx, job, result); err != nil {
+			q.fail(ctx, h, job, err)
+			return
+		}
+	}
+	q.setStatus(ctx, job, JobSucceeded, q.statusTTL, "progress", 100, "result", string(result))
+	// 先记录批次再ack，节点在两者之间宕机时任务会再次执行，批次的记录是幂等的，不会重复计数
+	if job.BatchID != "" {
+		q.recordBatch(ctx, job, batchJobSucceeded)
+	}
+	q.ackOrLog(ctx, job)
+}
+
+// deferUnknown 当前节点没有注册该任务（比如滚动发布时，新任务先由新节点写入，被旧节点取出），
+// 按默认的重试策略放回延迟集合，等待注册了该任务的节点执行，不计入执行次数。
+// 设置了WithUnknownJobAttempts时，超过次数后按失败处理
+func (q *Queue) deferUnknown(ctx context.Context, job *QueuedJob) {
+	if q.unknownJobAttempts > 0 && job.Deferred+1 >= q.unknownJobAttempts {
+		q.fail(ctx, nil, job, errors.Wrapf(ErrUnknownJob, "job \"%s\"", job.Name))
+		return
+	}
+
+	delay := defaultBackoff(job.Deferred + 1)
+	runAt := q.clock.Now(ctx).Add(delay).UnixMilli()
+	if err := q.cache.Script(queueDeferScript).Run(ctx,
+		[]string{q.key("reserved"), q.key("delayed"), q.key("jobs")},
+		job.ID, runAt).Err(); err != nil {
+		q.logger.WithContext(ctx).Errorf("[Queue]defer unknown job \"%s\"(%s) of queue \"%s\" failed: %v", job.Name, job.ID, q.name, err)
+		return
+	}
+	q.logger.WithContext(ctx).Warnf("[Queue]job \"%s\"(%s) of queue \"%s\" is not registered on this node, retry after %s", job.Name, job.ID, q.name, delay)
+}
+
+// heartbeat 每隔可见性超时的1/3延长任务的可见性超时，返回停止的函数
+func (q *Queue) heartbeat(ctx context.Context, job *QueuedJob) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(max(q.visibilityTimeout/3, time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			deadline := q.clock.Now(ctx).Add(q.visibilityTimeout).UnixMilli()
+			extended, err := q.cache.Script(queueExtendScript).Run(ctx,
+				[]string{q.key("reserved")},
+				job.ID, deadline).Int()
+			if err != nil {
+				q.logger.WithContext(ctx).Errorf("[Queue]extend visibility timeout of job \"%s\"(%s) of queue \"%s\" failed: %v", job.Name, job.ID, q.name, err)
+			} else if extended == 0 {
+				// 已经被放回就绪列表，其它节点可能会再次执行
+				q.logger.WithContext(ctx).Warnf("[Queue]job \"%s\"(%s) of queue \"%s\" is no longer reserved", job.Name, job.ID, q.name)
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+func (q *Queue) ackOrLog(ctx context.Context, job *QueuedJob) {
+	if err := q.ack(ctx, job); err != nil {
+		q.logger.WithContext(ctx).Errorf("[Queue]ack job \"%s\"(%s) of queue \"%s\" failed: %v", job.Name, job.ID, q.name, err)
+	}
+}
+
+// handle 调用handler，设置了timeout时ctx会在超时后cancel，panic会转换为PanicError
+func (q *Queue) handle(ctx context.Context, h *queueHandler, job *QueuedJob) (result []byte, err error) {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	err = callSafely(ctx, func(ctx context.Context) error {
+		result, err = h.handler(ctx, job.Payload)
+		return err
+	})
+	return result, err
+}
+
+// fail 任务执行失败，未超过最大执行次数时放回延迟集合，否则保存到FailedJobStore并从队列中删除
+func (q *Queue) fail(ctx context.Context, h *queueHandler, job *QueuedJob, err error) {
+	logger := q.logger.WithContext(ctx)
+
+	var stack string
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		stack = string(panicErr.Stack)
+		logger.Errorf("[Queue]job \"%s\"(%s) of queue \"%s\" panic: %v\n%s", job.Name, job.ID, q.name, panicErr.Value, stack)
+	}
+
+	maxAttempts, backoff := 1, defaultBackoff
+	if h != nil {
+		maxAttempts, backoff = h.maxAttempts, h.backoff
+	}
+
+	if job.Attempts < maxAttempts {
+		delay := backoff(job.Attempts)
+		runAt := q.clock.Now(ctx).Add(delay).UnixMilli()
+		if e := q.cache.Script(queueReleaseScript).Run(ctx,
+			[]string{q.key("reserved"), q.key("delayed")},
+			job.ID, runAt).Err(); e != nil {
+			logger.Errorf("[Queue]release job \"%s\"(%s) of queue \"%s\" failed: %v", job.Name, job.ID, q.name, e)
+			return
+		}
+		logger.Warnf("[Queue]job \"%s\"(%s) of queue \"%s\" failed (attempt %d/%d), retry after %s: %v", job.Name, job.ID, q.name, job.Attempts, maxAttempts, delay, err)
+		q.setStatus(ctx, job, JobRetrying, q.statusTTL+delay, "error", err.Error())
+		return
+	}
+
+	logger.Errorf("[Queue]job \"%s\"(%s) of queue \"%s\" failed after %d attempt(s): %v", job.Name, job.ID, q.name, job.Attempts, err)
+	// 保存失败时不删除任务，可见性超时后会再次执行
+	if e := q.failedStore.Add(ctx, &FailedJob{
+		QueuedJob: *job,
+		Queue:     q.name,
+		Error:     err.Error(),
+		Stack:     stack,
+		FailedAt:  time.Now(),
+	}); e != nil {
+		logger.Errorf("[Queue]save failed job \"%s\"(%s) of queue \"%s\" failed: %v", job.Name, job.ID, q.name, e)
+		return
+	}
+	q.setStatus(ctx, job, JobFailed, q.statusTTL, "error", err.Error())
+	if job.BatchID != "" {
+		q.recordBatch(ctx, job, batchJobFailed)
+	}
+	q.ackOrLog(ctx, job)
+}
+
+// FailedJobs 按失败时间倒序返回失败的任务，以及总数
+func (q *Queue) FailedJobs(ctx context.Context, offset, limit int) ([]*FailedJob, int64, error) {
+	return q.failedStore.List(ctx, offset, limit)
+}
+
+// RetryFailed 将失败的任务重新放入就绪列表（执行次数清零），并从FailedJobStore中删除
+func (q *Queue) RetryFailed(ctx context.Context, ids ...string) error {
+	for _, id := range ids {
+		failed, err := q.failedStore.Find(ctx, id)
+		if err != nil {
+			return err
+		}
+		job := failed.QueuedJob
+		job.Attempts, job.Deferred = 0, 0
+		q.setStatus(ctx, &job, JobPending, q.statusTTL, "error", "")
+		if err = q.push(ctx, &job, 0); err != nil {
+			return errors.Wrapf(err, "retry failed job \"%s\"(%s) of queue \"%s\" failed", job.Name, job.ID, q.name)
+		}
+		if err = q.failedStore.Forget(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetryAllFailed 重试所有失败的任务，返回重试的数量
+func (q *Queue) RetryAllFailed(ctx context.Context) (int, error) {
+	var count int
+	for {
+		jobs, _, err := q.failedStore.List(ctx, 0, 100)
+		if err != nil || len(jobs) == 0 {
+			return count, err
+		}
+		for _, job := range jobs {
+			if err = q.RetryFailed(ctx, job.ID); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// ForgetFailed 删除失败的任务
+func (q *Queue) ForgetFailed(ctx context.Context, ids ...string) error {
+	return q.failedStore.Forget(ctx, ids...)
+}
+
+// FlushFailed 删除所有失败的任务
+func (q *Queue) FlushFailed(ctx context.Context) error {
+	return q.failedStore.Flush(ctx)
+}
+
+// wait 等待pollInterval，返回false表示队列已停止
+func (q *Queue) wait() bool {
+	timer := time.NewTimer(q.pollInterval)
+	defer timer.Stop()
+	select {
+	case <-q.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// consume 循环取出任务执行，直到队列停止
+func (q *Queue) consume(ctx context.Context) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		job, err := q.reserve(ctx)
+		if err != nil {
+			q.logger.WithContext(ctx).Errorf("[Queue]reserve job of queue \"%s\" failed: %v", q.name, err)
+		}
+		if job == nil {
+			if !q.wait() {
+				return
+			}
+			continue
+		}
+		q.process(job)
+	}
+}
+
+// schedule 定时移动到期的任务
+func (q *Queue) schedule(ctx context.Context) {
+	defer q.wg.Done()
+	for {
+		q.migrate(ctx)
+		if !q.wait() {
+			return
+		}
+	}
+}
+
+func (q *Queue) Start(ctx context.Context) error {
+	if !q.running.CompareAndSwap(false, true) {
+		return nil
+	}
+	q.stop = make(chan struct{})
+	q.done = make(chan struct{})
+
+	// redis的操作使用不会被cancel的context
+	ctx = context.WithoutCancel(ctx)
+	q.wg.Add(q.concurrency + 1)
+	go q.schedule(ctx)
+	for i := 0; i < q.concurrency; i++ {
+		go q.consume(ctx)
+	}
+	go func(done chan struct{}) {
+		q.wg.Wait()
+		close(done)
+	}(q.done)
+
+	q.logger.WithContext(ctx).Infof("queue \"%s\" started, concurrency: %d", q.name, q.concurrency)
+	return nil
+}
+
+// Stop 停止取出新的任务，并等待执行中的任务完成。
+// ctx结束时不再等待，返回ctx.Err()，未完成的任务在可见性超时后由其它节点执行
+func (q *Queue) Stop(ctx context.Context) error {
+	if !q.running.CompareAndSwap(true, false) {
+		return nil
+	}
+	close(q.stop)
+
+	select {
+	case <-q.done:
+	case <-ctx.Done():
+		q.logger.WithContext(ctx).Warnf("queue \"%s\" stop timeout, some jobs are still running", q.name)
+		return ctx.Err()
+	}
+
+	q.logger.WithContext(ctx).Infof("queue \"%s\" stopped", q.name)
+	return nil
+}
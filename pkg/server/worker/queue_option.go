@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"github.com/go-kratos/kratos/v2/encoding"
+	"time"
+)
+
+type QueueOption func(*Queue)
+
+// WithQueueName 设置队列名（默认default），不同队列的任务互不影响
+func WithQueueName(name string) QueueOption {
+	return func(q *Queue) {
+		q.name = name
+	}
+}
+
+// WithConcurrency 设置当前节点消费任务的协程数（默认10）
+func WithConcurrency(concurrency int) QueueOption {
+	return func(q *Queue) {
+		if concurrency > 0 {
+			q.concurrency = concurrency
+		}
+	}
+}
+
+// WithPollInterval 设置队列为空时的轮询间隔，以及延迟任务的调度间隔（默认1s）
+func WithPollInterval(interval time.Duration) QueueOption {
+	return func(q *Queue) {
+		if interval > 0 {
+			q.pollInterval = interval
+		}
+	}
+}
+
+// WithVisibilityTimeout 设置任务的可见性超时（默认5分钟）。
+// 任务执行期间每隔1/3的可见性超时会自动延长一次，节点宕机等原因导致超过该时间没有延长时，会重新放回队列，由其它节点执行
+func WithVisibilityTimeout(timeout time.Duration) QueueOption {
+	return func(q *Queue) {
+		if timeout > 0 {
+			q.visibilityTimeout = timeout
+		}
+	}
+}
+
+// WithQueueCodec 设置payload默认的编解码器（默认json）
+func WithQueueCodec(codec encoding.Codec) QueueOption {
+	return func(q *Queue) {
+		if codec != nil {
+			q.codec = codec
+		}
+	}
+}
+
+// WithFailedJobStore 设置失败任务的存储（默认使用redis存储）
+func WithFailedJobStore(store FailedJobStore) QueueOption {
+	return func(q *Queue) {
+		q.failedStore = store
+	}
+}
+
+// WithStatusTTL 设置任务状态、进度和结果的保留时间（默认24小时），<= 0 表示不记录任务状态
+func WithStatusTTL(ttl time.Duration) QueueOption {
+	return func(q *Queue) {
+		q.statusTTL = ttl
+	}
+}
+
+// WithUnknownJobAttempts 设置没有注册的任务最多取出的次数，超过后按失败处理（保存到FailedJobStore）。
+// 默认不限制：当前节点没有注册的任务（比如滚动发布时旧节点取出了新任务）会按默认的重试策略放回延迟集合，等待注册了该任务的节点执行
+func WithUnknownJobAttempts(attempts int) QueueOption {
+	return func(q *Queue) {
+		q.unknownJobAttempts = attempts
+	}
+}
+
+type RegisterOption func(*queueHandler)
+
+// WithMaxAttempts 设置任务最大的执行次数（默认1，即不重试），超过后保存到FailedJobStore
+func WithMaxAttempts(attempts int) RegisterOption {
+	return func(h *queueHandler) {
+		if attempts > 0 {
+			h.maxAttempts = attempts
+		}
+	}
+}
+
+// WithBackoff 设置任务失败后重试的等待时间（默认ExponentialBackoff(time.Second, 10*time.Minute)加50%的jitter）
+func WithBackoff(backoff Backoff) RegisterOption {
+	return func(h *queueHandler) {
+		if backoff != nil {
+			h.backoff = backoff
+		}
+	}
+}
+
+// WithTimeout 设置任务的执行超时，超时后handler的ctx会被cancel（默认不超时）
+func WithTimeout(timeout time.Duration) RegisterOption {
+	return func(h *queueHandler) {
+		h.timeout = timeout
+	}
+}
+
+// WithPayloadCodec 设置该任务payload的编解码器，Dispatch和执行时都会使用
+func WithPayloadCodec(codec encoding.Codec) RegisterOption {
+	return func(h *queueHandler) {
+		if codec != nil {
+			h.codec = codec
+		}
+	}
+}
+
+type dispatchOptions struct {
+	runAt time.Time
+	delay time.Duration
+}
+
+type DispatchOption func(*dispatchOptions)
+
+// Delay 延迟delay之后执行
+func Delay(delay time.Duration) DispatchOption {
+	return func(o *dispatchOptions) {
+		o.delay = delay
+	}
+}
+
+// At 在指定时间执行（以redis服务器时间为准）
+func At(runAt time.Time) DispatchOption {
+	return func(o *dispatchOptions) {
+		o.runAt = runAt
+	}
+}
@@ -0,0 +1,104 @@
+package worker
+
+// queuePushScript 写入任务数据，runAt > 0 时放入延迟集合，否则放入就绪列表
+//
+//	KEYS: jobs, ready, delayed
+//	ARGV: id, data, run_at(ms)
+const queuePushScript = `local id = ARGV[1]
+local run_at = tonumber(ARGV[3])
+redis.call('hset', KEYS[1], id, ARGV[2])
+if run_at > 0 then
+	redis.call('zadd', KEYS[3], run_at, id)
+else
+	redis.call('lpush', KEYS[2], id)
+end
+return 1
+`
+
+// queueMigrateScript 将有序集合中score <= now的任务移动到就绪列表，每次最多移动limit个
+//
+//	KEYS: from, ready
+//	ARGV: now(ms), limit
+const queueMigrateScript = `local ids = redis.call('zrangebyscore', KEYS[1], '-inf', ARGV[1], 'limit', 0, tonumber(ARGV[2]))
+for _, id in ipairs(ids) do
+	redis.call('zrem', KEYS[1], id)
+	redis.call('lpush', KEYS[2], id)
+end
+return #ids
+`
+
+// queueReserveScript 从就绪列表取出一个任务，递增attempts，并放入执行中集合（score为可见性超时的时间）
+//
+//	KEYS: ready, reserved, jobs
+//	ARGV: deadline(ms)
+const queueReserveScript = `while true do
+	local id = redis.call('rpop', KEYS[1])
+	if not id then
+		return false
+	end
+	local data = redis.call('hget', KEYS[3], id)
+	if data then -- 任务数据不存在（已被删除）时跳过
+		local job = cjson.decode(data)
+		job['attempts'] = (tonumber(job['attempts']) or 0) + 1
+		data = cjson.encode(job)
+		redis.call('hset', KEYS[3], id, data)
+		redis.call('zadd', KEYS[2], ARGV[1], id)
+		return data
+	end
+end
+`
+
+// queueReleaseScript 任务执行失败，从执行中集合移动到延迟集合，等待重试。
+// 如果任务已经因为可见性超时被放回就绪列表，则不处理
+//
+//	KEYS: reserved, delayed
+//	ARGV: id, run_at(ms)
+const queueReleaseScript = `if redis.call('zrem', KEYS[1], ARGV[1]) == 1 then
+	redis.call('zadd', KEYS[2], ARGV[2], ARGV[1])
+	return 1
+end
+return 0
+`
+
+// queueDeferScript 任务没有注册，从执行中集合移动到延迟集合，撤销本次取出时递增的attempts，并递增deferred。
+// 如果任务已经因为可见性超时被放回就绪列表，则不处理
+//
+//	KEYS: reserved, delayed, jobs
+//	ARGV: id, run_at(ms)
+const queueDeferScript = `if redis.call('zrem', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+local data = redis.call('hget', KEYS[3], ARGV[1])
+if data then
+	local job = cjson.decode(data)
+	job['attempts'] = math.max((tonumber(job['attempts']) or 1) - 1, 0)
+	job['deferred'] = (tonumber(job['deferred']) or 0) + 1
+	redis.call('hset', KEYS[3], ARGV[1], cjson.encode(job))
+end
+redis.call('zadd', KEYS[2], ARGV[2], ARGV[1])
+return 1
+`
+
+// queueAckScript 任务完成，从执行中集合删除，并删除任务数据。
+// 如果任务已经因为可见性超时被放回就绪列表，则保留任务数据，任务会再次执行
+//
+//	KEYS: reserved, jobs
+//	ARGV: id
+const queueAckScript = `if redis.call('zrem', KEYS[1], ARGV[1]) == 1 then
+	redis.call('hdel', KEYS[2], ARGV[1])
+	return 1
+end
+return 0
+`
+
+// queueExtendScript 任务执行中，延长可见性超时的时间。
+// 如果任务已经不在执行中集合（已完成、失败，或者已经因为可见性超时被放回就绪列表），则不处理
+//
+//	KEYS: reserved
+//	ARGV: id, deadline(ms)
+const queueExtendScript = `if redis.call('zscore', KEYS[1], ARGV[1]) then
+	redis.call('zadd', KEYS[1], ARGV[2], ARGV[1])
+	return 1
+end
+return 0
+`
@@ -0,0 +1,274 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	goRedis "github.com/redis/go-redis/v9"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/app"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/cache"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+)
+
+// testApp 每个进程只能创建一个App
+var testApp *app.App
+
+func TestMain(m *testing.M) {
+	testApp = app.NewApp("worker-test")
+	os.Exit(m.Run())
+}
+
+// newTestCache 返回连接到miniredis的Cache
+func newTestCache(t *testing.T) (*cache.Cache, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := goRedis.NewClient(&goRedis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return cache.NewCache(client, log.New(context.Background())), mr
+}
+
+// newTestQueue 返回没有启动的队列，测试中通过runNext手动取出执行
+func newTestQueue(t *testing.T, options ...QueueOption) *Queue {
+	t.Helper()
+	c, _ := newTestCache(t)
+	return NewQueue(testApp, log.New(context.Background()), c, options...)
+}
+
+// advance 将队列的时钟（redis服务器时间）向后调整d
+func advance(q *Queue, d time.Duration) {
+	q.clock.Delta(context.Background())
+	q.clock.delta.Add(int64(d))
+}
+
+// runNext 移动到期的任务，取出一个任务执行，队列为空时返回nil
+func runNext(t *testing.T, q *Queue) *QueuedJob {
+	t.Helper()
+	ctx := context.Background()
+	q.migrate(ctx)
+	job, err := q.reserve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if job != nil {
+		q.process(job)
+	}
+	return job
+}
+
+// drain 执行所有就绪的任务，返回执行的任务
+func drain(t *testing.T, q *Queue) []*QueuedJob {
+	t.Helper()
+	var jobs []*QueuedJob
+	for job := runNext(t, q); job != nil; job = runNext(t, q) {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func assertSize(t *testing.T, q *Queue, ready, delayed, reserved int64) {
+	t.Helper()
+	r, d, s, err := q.Size(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if r != ready || d != delayed || s != reserved {
+		t.Fatalf("expected ready/delayed/reserved %d/%d/%d, got %d/%d/%d", ready, delayed, reserved, r, d, s)
+	}
+}
+
+func assertState(t *testing.T, q *Queue, id string, state JobState) *JobStatus {
+	t.Helper()
+	status, err := q.Status(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	} else if status.State != state {
+		t.Fatalf("expected job %s is %s, got %s", id, state, status.State)
+	}
+	return status
+}
+
+func TestReserveAck(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	var received string
+	Register(q, "echo", func(ctx context.Context, payload string) error {
+		received = payload
+		return nil
+	})
+	id, err := q.Dispatch(ctx, "echo", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSize(t, q, 1, 0, 0)
+	assertState(t, q, id, JobPending)
+
+	job, err := q.reserve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if job == nil || job.ID != id || job.Attempts != 1 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	assertSize(t, q, 0, 0, 1)
+
+	// 执行成功后ack，删除任务数据
+	q.process(job)
+	if received != "hello" {
+		t.Fatalf("expected payload hello, got %q", received)
+	}
+	assertSize(t, q, 0, 0, 0)
+	if n, err := q.cache.HLen(ctx, q.key("jobs")); err != nil || n != 0 {
+		t.Fatalf("expected the job data is deleted, got %d %v", n, err)
+	}
+	assertState(t, q, id, JobSucceeded)
+}
+
+func TestVisibilityTimeout(t *testing.T) {
+	q := newTestQueue(t, WithVisibilityTimeout(time.Minute))
+	ctx := context.Background()
+
+	Register(q, "noop", func(ctx context.Context, payload string) error { return nil })
+	id, err := q.Dispatch(ctx, "noop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 取出后节点宕机，没有ack也没有延长可见性超时
+	if job, err := q.reserve(ctx); err != nil || job == nil {
+		t.Fatalf("expected the job is reserved, got %v %v", job, err)
+	}
+	q.migrate(ctx)
+	assertSize(t, q, 0, 0, 1)
+
+	// 超过可见性超时后放回就绪列表，再次取出时attempts递增
+	advance(q, time.Minute+time.Second)
+	q.migrate(ctx)
+	assertSize(t, q, 1, 0, 0)
+	job := runNext(t, q)
+	if job == nil || job.ID != id || job.Attempts != 2 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	assertSize(t, q, 0, 0, 0)
+}
+
+func TestRetryFailed(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	var succeed atomic.Bool
+	Register(q, "flaky", func(ctx context.Context, payload string) error {
+		if succeed.Load() {
+			return nil
+		}
+		return errors.New("boom")
+	}, WithMaxAttempts(2), WithBackoff(ConstantBackoff(time.Minute)))
+	id, err := q.Dispatch(ctx, "flaky", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次失败后按backoff放回延迟集合
+	runNext(t, q)
+	assertSize(t, q, 0, 1, 0)
+	assertState(t, q, id, JobRetrying)
+	if job := runNext(t, q); job != nil {
+		t.Fatalf("expected the job is delayed, got %+v", job)
+	}
+
+	// 超过最大执行次数后保存到FailedJobStore
+	advance(q, time.Minute+time.Second)
+	if job := runNext(t, q); job == nil || job.Attempts != 2 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	assertSize(t, q, 0, 0, 0)
+	assertState(t, q, id, JobFailed)
+	failed, total, err := q.FailedJobs(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	} else if total != 1 || len(failed) != 1 || failed[0].ID != id || failed[0].Attempts != 2 || failed[0].Error != "boom" {
+		t.Fatalf("unexpected failed jobs %d %+v", total, failed)
+	}
+
+	// 重试后执行次数清零
+	succeed.Store(true)
+	if err = q.RetryFailed(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ = q.FailedJobs(ctx, 0, 10); total != 0 {
+		t.Fatalf("expected the failed job is forgotten, got %d", total)
+	}
+	if job := runNext(t, q); job == nil || job.ID != id || job.Attempts != 1 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	assertState(t, q, id, JobSucceeded)
+}
+
+func TestUnknownJob(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	// 没有注册的任务放回延迟集合，不计入执行次数
+	id, err := q.Dispatch(ctx, "missing", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runNext(t, q)
+	assertSize(t, q, 0, 1, 0)
+	assertState(t, q, id, JobPending)
+
+	// 注册后由本节点执行
+	var calls atomic.Int32
+	Register(q, "missing", func(ctx context.Context, payload string) error {
+		calls.Add(1)
+		return nil
+	})
+	advance(q, time.Hour)
+	if job := runNext(t, q); job == nil || job.Attempts != 1 || job.Deferred != 1 {
+		t.Fatalf("unexpected job %+v", job)
+	} else if calls.Load() != 1 {
+		t.Fatal("expected the job is executed after registered")
+	}
+
+	// WithUnknownJobAttempts：超过次数后按失败处理
+	q = newTestQueue(t, WithUnknownJobAttempts(2))
+	if id, err = q.Dispatch(ctx, "missing", ""); err != nil {
+		t.Fatal(err)
+	}
+	runNext(t, q)
+	assertSize(t, q, 0, 1, 0)
+	advance(q, time.Hour)
+	runNext(t, q)
+	assertSize(t, q, 0, 0, 0)
+	if failed, err := q.failedStore.Find(ctx, id); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(failed.Error, ErrUnknownJob.Error()) {
+		t.Fatalf("unexpected error %s", failed.Error)
+	}
+}
+
+func TestServerClock(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	// redis服务器时间快1小时
+	mr.SetTime(time.Now().Add(time.Hour))
+	clock := newServerClock(c, time.Minute)
+	if delta := clock.Delta(ctx); delta < 59*time.Minute || delta > 61*time.Minute {
+		t.Fatalf("expected the delta is about 1h, got %s", delta)
+	}
+
+	// 刷新间隔内使用缓存的差值
+	mr.SetTime(time.Now())
+	if delta := clock.Delta(ctx); delta < 59*time.Minute {
+		t.Fatalf("expected the cached delta, got %s", delta)
+	}
+	clock.refreshedAt.Store(time.Now().Add(-time.Minute).UnixNano())
+	if delta := clock.Delta(ctx); delta > time.Minute {
+		t.Fatalf("expected the delta is refreshed, got %s", delta)
+	}
+}
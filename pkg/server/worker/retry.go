@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime/debug"
+	"time"
+)
+
+// PanicError job执行时发生了panic
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panic: %v", e.Value)
+}
+
+// callSafely 执行fn，panic时转换为PanicError返回
+func callSafely(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+// Backoff 返回第attempt次（从1开始）执行失败后，到下次重试的等待时间
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff 每次重试等待相同的时间
+func ConstantBackoff(delay time.Duration) Backoff {
+	return func(int) time.Duration {
+		return delay
+	}
+}
+
+// ExponentialBackoff 指数退避：base * 2^(attempt-1)，最大不超过maxDelay
+func ExponentialBackoff(base, maxDelay time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		delay := float64(base) * math.Pow(2, float64(max(attempt, 1)-1))
+		if delay > float64(maxDelay) {
+			return maxDelay
+		}
+		return time.Duration(delay)
+	}
+}
+
+// WithJitter 在backoff的基础上随机减少最多factor（0~1）比例的等待时间，避免大量任务在同一时刻重试
+// example: worker.WithJitter(worker.ExponentialBackoff(time.Second, time.Hour), 0.5)
+func WithJitter(backoff Backoff, factor float64) Backoff {
+	factor = math.Min(math.Max(factor, 0), 1)
+	return func(attempt int) time.Duration {
+		delay := backoff(attempt)
+		return delay - time.Duration(rand.Float64()*factor*float64(delay))
+	}
+}
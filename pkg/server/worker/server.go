@@ -4,14 +4,15 @@ import (
 	"context"
 	"fmt"
 	"github.com/RussellLuo/timingwheel"
-	"github.com/gammazero/workerpool"
 	"github.com/go-kratos/kratos/v2/transport"
 	"github.com/robfig/cron/v3"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/app"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/cache"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/log"
+	"gopkg.in/go-mixed/kratos-packages.v2/pkg/redis"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/job"
 	"gopkg.in/go-mixed/kratos-packages.v2/pkg/server/schedule"
+	"slices"
 	"sync/atomic"
 	"time"
 )
@@ -28,12 +29,29 @@ type Worker struct {
 	logger *log.Helper
 	cache  *cache.Cache
 
-	pool           *workerpool.WorkerPool
+	pool           *priorityPool
 	timeWheel      *timingwheel.TimingWheel
 	schedule       *cron.Cron
 	scheduleParser cron.Parser
 	stopped        *atomic.Bool
 	ctx            context.Context
+
+	// middleware 所有job都会使用的wrapper（NewWorker时设置）
+	middleware []job.JobWrapper
+	// wrappers 通过Wrap设置的wrapper，在middleware的外层
+	wrappers []job.JobWrapper
+
+	// name 通过Named设置的job名称
+	name string
+	// poolName 通过OnPool设置的pool名称
+	poolName string
+	// registry 登记的cron任务，所有clone共享
+	registry     *cronRegistry
+	triggerEntry cron.EntryID
+	// unsubscribe 取消订阅手动触发的频道
+	unsubscribe func()
+	// elections 通过Elect添加的选举，所有clone共享
+	elections *electionRegistry
 }
 
 var _ transport.Server = (*Worker)(nil)
@@ -45,14 +63,15 @@ func NewWorker(
 	cache *cache.Cache,
 
 	maxWorkers int,
+	options ...Option,
 ) *Worker {
-	scheduleParser := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
-	return &Worker{
+	scheduleParser := schedule.DefaultParser
+	w := &Worker{
 		app:    app,
 		logger: log.NewModuleHelper(logger, "worker"),
 		cache:  cache,
 
-		pool:      workerpool.New(maxWorkers),
+		pool:      newPriorityPool(maxWorkers),
 		timeWheel: timingwheel.NewTimingWheel(time.Millisecond, 20),
 		schedule: cron.New(
 			cron.WithParser(scheduleParser),
@@ -61,7 +80,14 @@ func NewWorker(
 		scheduleParser: scheduleParser,
 		stopped:        &atomic.Bool{},
 		ctx:            app.BaseContext(),
+
+		registry:  newCronRegistry(cache.WithExpiration(redis.KeepTTL)),
+		elections: newElectionRegistry(),
 	}
+	for _, option := range options {
+		option(w)
+	}
+	return w
 }
 
 // clone returns a new worker with the same configuration, which will not affect the execution of the task.
@@ -72,12 +98,41 @@ func (w *Worker) clone() *Worker {
 		logger: w.logger,
 		cache:  w.cache.Clone(),
 
-		pool:      w.pool,
-		timeWheel: w.timeWheel,
-		schedule:  w.schedule,
-		stopped:   w.stopped,
-		ctx:       w.ctx,
+		pool:           w.pool,
+		timeWheel:      w.timeWheel,
+		schedule:       w.schedule,
+		scheduleParser: w.scheduleParser,
+		stopped:        w.stopped,
+		ctx:            w.ctx,
+
+		middleware: w.middleware,
+		wrappers:   w.wrappers,
+
+		name:      w.name,
+		poolName:  w.poolName,
+		registry:  w.registry,
+		elections: w.elections,
+	}
+}
+
+// wrap 使用wrappers、middleware装饰job
+func (w *Worker) wrap(j job.Job) job.Job {
+	if len(w.wrappers) == 0 && len(w.middleware) == 0 {
+		return j
 	}
+	wrappers := make([]job.JobWrapper, 0, len(w.wrappers)+len(w.middleware))
+	wrappers = append(append(wrappers, w.wrappers...), w.middleware...)
+	return job.NewChain(wrappers...).Then(j)
+}
+
+// Wrap returns a new worker, the jobs submitted by it will be decorated with the wrappers (outside the middleware).
+//
+//	Wrap 返回一个新的worker，通过它提交的job会使用wrappers装饰（在WithMiddleware设置的middleware的外层）。
+//	比如：w.Wrap(job.Named("reindex"), skipIfStillRunning).Submit(func(ctx){...})
+func (w *Worker) Wrap(wrappers ...job.JobWrapper) IWorker {
+	_w := w.clone()
+	_w.wrappers = append(slices.Clone(w.wrappers), wrappers...)
+	return _w
 }
 
 // WithContext returns a new worker with the given context.
@@ -89,6 +144,62 @@ func (w *Worker) WithContext(ctx context.Context) IWorker {
 	return _w
 }
 
+// Named returns a new worker, the jobs submitted by it will be named (see job.Named),
+// and the cron jobs will be registered, which can be listed, paused, resumed and triggered.
+//
+//	Named 返回一个新的worker，通过它提交的job会设置名称（见job.Named），在middleware中可以通过job.NameFromContext获取。
+//	添加的cron任务会登记到registry中，可以通过CronEntries查看，PauseCron、ResumeCron、TriggerCron操作（名称不能重复）。
+//	比如：w.Named("reindex").Cron("@every 1m", func(ctx){...})、w.OnceForCluster("reindex").Named("reindex").CronWith(job).EveryMinute()
+func (w *Worker) Named(name string) IWorker {
+	_w := w.clone()
+	_w.name = name
+	// 名称需要在最外层设置，其它wrapper才能获取到
+	_w.wrappers = append([]job.JobWrapper{job.Named(name)}, w.wrappers...)
+	return _w
+}
+
+// OnPool returns a new worker, the jobs submitted by it will be executed in the named pool.
+//
+//	OnPool 返回一个新的worker，通过它提交的job会在名为name的pool中执行（默认为DefaultPool）。
+//	每个pool可以设置独立的并发数上限和权重（见WithPool、ConfigurePool），所有pool共享Worker总的并发数，
+//	有空闲的协程时按权重选择等待中的pool，这样大量低优先级的job不会阻塞高优先级的job。未设置的pool不限制并发数，权重为1。
+//	比如：w.OnPool("critical").Submit(func(ctx){...})
+func (w *Worker) OnPool(name string) IWorker {
+	_w := w.clone()
+	_w.poolName = name
+	return _w
+}
+
+// ResizePool 修改Worker总的并发数，减小时执行中的job不受影响
+func (w *Worker) ResizePool(maxWorkers int) {
+	w.pool.Resize(maxWorkers)
+	w.logger.Infof("worker pool resized to %d", maxWorkers)
+}
+
+// ConfigurePool 设置pool的并发数上限（0表示只受总并发数限制）和权重（最小为1），运行时也可以修改
+func (w *Worker) ConfigurePool(name string, concurrency, weight int) {
+	w.pool.Configure(name, concurrency, weight)
+	w.logger.Infof("pool \"%s\" configured, concurrency: %d, weight: %d", name, concurrency, weight)
+}
+
+// ApplyPoolConfig 应用pool的配置，可以在配置变更时调用
+func (w *Worker) ApplyPoolConfig(config *PoolConfig) {
+	if config == nil {
+		return
+	}
+	if config.MaxWorkers > 0 {
+		w.ResizePool(config.MaxWorkers)
+	}
+	for _, pool := range config.Pools {
+		w.ConfigurePool(pool.Name, pool.Concurrency, pool.Weight)
+	}
+}
+
+// PoolStats 返回所有pool的统计信息（并发数、执行中、等待中的job数量，平均等待时间等）
+func (w *Worker) PoolStats() []PoolStats {
+	return w.pool.Stats()
+}
+
 // OnceForCluster submits a task to be executed by a worker.
 // execute only once in the cluster. If it is a cron task, it means that only one node is executed at a time.
 // e.g.: OnceForCluster("key-123").Submit(func(ctx){...}) means that this key-123 job will only be executed once in the cluster.
@@ -121,9 +232,12 @@ func (w *Worker) OnceForCluster(key string, options ...onceOption) IWorker {
 //	（为了防止job在调用时会用到request canceled的ctx，将会context转换成一个不会cancel的context）
 func (w *Worker) Submit(job job.Job) {
 	ctx := w.app.CloneContextFromBase(w.ctx)
-	w.pool.Submit(func() {
+	job = w.wrap(job)
+	if !w.pool.Submit(w.poolName, func() {
 		job(ctx)
-	})
+	}) {
+		w.logger.WithContext(ctx).Warnf("worker is stopped, the job is dropped")
+	}
 }
 
 // SubmitWait submits a task to be executed by a worker.
@@ -134,9 +248,12 @@ func (w *Worker) Submit(job job.Job) {
 //	（job执行时使用的是app.BaseContext()，或者你可以通过WithContext自定义context）
 func (w *Worker) SubmitWait(job job.Job) {
 	// ctx := w.app.CloneContextFromBase(w.ctx)
-	w.pool.SubmitWait(func() {
+	job = w.wrap(job)
+	if !w.pool.SubmitWait(w.poolName, func() {
 		job(w.ctx)
-	})
+	}) {
+		w.logger.WithContext(w.ctx).Warnf("worker is stopped, the job is dropped")
+	}
 }
 
 // SubmitWithError submits a task to be executed by a worker and returns the error.
@@ -148,9 +265,14 @@ func (w *Worker) SubmitWait(job job.Job) {
 func (w *Worker) SubmitWithError(job job.JobWithError) error {
 	// ctx := w.app.CloneContextFromBase(w.ctx)
 	var err error
-	w.pool.SubmitWait(func() {
-		err = job(w.ctx)
+	wrapped := w.wrap(func(ctx context.Context) {
+		err = job(ctx)
 	})
+	if !w.pool.SubmitWait(w.poolName, func() {
+		wrapped(w.ctx)
+	}) {
+		return ErrWorkerStopped
+	}
 
 	return err
 }
@@ -163,10 +285,13 @@ func (w *Worker) SubmitWithError(job job.JobWithError) error {
 //	（为了防止job在调用时会用到request canceled的ctx，将会context转换成一个不会cancel的context）
 func (w *Worker) SubmitAfter(delay time.Duration, job job.Job) {
 	ctx := w.app.CloneContextFromBase(w.ctx)
+	job = w.wrap(job)
 	w.timeWheel.AfterFunc(delay, func() {
-		w.pool.Submit(func() {
+		if !w.pool.Submit(w.poolName, func() {
 			job(ctx)
-		})
+		}) {
+			w.logger.WithContext(ctx).Warnf("worker is stopped, the delayed job is dropped")
+		}
 	})
 }
 
@@ -189,14 +314,7 @@ func (w *Worker) parseSchedule(spec any) (cron.Schedule, error) {
 //	（job执行时使用的是app.BaseContext()，或者你可以通过WithContext自定义context）
 //	支持的表达式： https://pkg.go.dev/github.com/robfig/cron/v3#hdr-Special_Characters
 func (w *Worker) Cron(spec any, job job.Job) (cron.EntryID, error) {
-	expr, err := w.parseSchedule(spec)
-	if err != nil {
-		return 0, err
-	}
-
-	return w.schedule.Schedule(expr, cron.FuncJob(func() {
-		job(w.ctx)
-	})), nil
+	return w.addCron(spec, job, "", nil)
 }
 
 // CronWith add a cron job to the worker with a chain caller: w.CronWith(func(ctx){...}).Every(30 * time.Second)
@@ -213,18 +331,49 @@ func (w *Worker) Start(ctx context.Context) error {
 	w.ctx = ctx
 	w.stopped.Store(false)
 
+	w.pool.Open()
 	w.timeWheel.Start()
+	// 订阅手动触发的cron任务（TriggerCron），并定时续期登记的cron任务
+	if w.triggerEntry == 0 {
+		w.triggerEntry = w.schedule.Schedule(cron.Every(cronRegistryInterval), cron.FuncJob(w.maintainCrons))
+	}
+	if w.unsubscribe == nil {
+		w.unsubscribe = w.subscribeCronTriggers(context.WithoutCancel(ctx))
+	}
+	go w.maintainCrons()
 	w.schedule.Start()
+	w.startElections()
 	w.logger.WithContext(ctx).Infof("time wheel, schedule, worker pool(size=%d) started", w.pool.Size())
 	return nil
 }
 
+// Stop 按顺序停止：交出leader、停止cron和时间轮（不再产生新的job）、关闭pool（之后提交的job被丢弃），
+// 最后等待pool中等待和执行中的job完成。ctx结束时不再等待，返回ctx.Err()
 func (w *Worker) Stop(ctx context.Context) error {
 	w.ctx = ctx
-	w.pool.StopWait()
-	w.timeWheel.Stop()
-	w.schedule.Stop()
-	w.stopped.Store(true)
+	// 先交出leader，其它节点可以尽快接替
+	w.stopElections(ctx)
+	if w.unsubscribe != nil {
+		w.unsubscribe()
+		w.unsubscribe = nil
+	}
+	// 时间轮中未到期的job会被丢弃。等待超时后可以再次调用Stop继续等待，时间轮只能停止一次
+	if w.stopped.CompareAndSwap(false, true) {
+		w.timeWheel.Stop()
+	}
+	scheduleCtx := w.schedule.Stop()
+
+	if err := w.pool.StopWait(ctx); err != nil {
+		w.logger.WithContext(ctx).Warnf("worker pool stop timeout, some jobs are still running: %v", err)
+		return err
+	}
+	// 等待执行中的cron任务
+	select {
+	case <-scheduleCtx.Done():
+	case <-ctx.Done():
+		w.logger.WithContext(ctx).Warnf("schedule stop timeout, some cron jobs are still running: %v", ctx.Err())
+		return ctx.Err()
+	}
 
 	w.logger.WithContext(ctx).Infof("time wheel, schedule, worker pool server stop")
 	return nil
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestWorkerStop(t *testing.T) {
+	w := newTestWorker(t)
+	ctx := context.Background()
+	if err := w.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var executed atomic.Int32
+	release := make(chan struct{})
+	w.Submit(func(ctx context.Context) {
+		<-release
+		executed.Add(1)
+	})
+	// 时间轮中未到期的job在Stop时被丢弃
+	w.SubmitAfter(time.Hour, func(ctx context.Context) {
+		executed.Add(1)
+	})
+
+	// 执行中的job没有完成时，ctx结束后返回
+	stopCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := w.Stop(stopCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if !w.Stopped() {
+		t.Fatal("expected the worker is stopped")
+	}
+
+	// Stop之后提交的job被丢弃
+	w.Submit(func(ctx context.Context) {
+		executed.Add(1)
+	})
+	if err := w.SubmitWithError(func(ctx context.Context) error { return nil }); !errors.Is(err, ErrWorkerStopped) {
+		t.Fatalf("expected ErrWorkerStopped, got %v", err)
+	}
+	if _, err := SubmitFuture(w, ctx, func(ctx context.Context) (int, error) { return 1, nil }).Wait(ctx); !errors.Is(err, ErrWorkerStopped) {
+		t.Fatalf("expected ErrWorkerStopped, got %v", err)
+	}
+
+	close(release)
+	if err := w.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if executed.Load() != 1 {
+		t.Fatalf("expected only the running job is executed, got %d", executed.Load())
+	}
+}
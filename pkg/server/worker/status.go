@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strconv"
+	"time"
+)
+
+// ErrJobStatusNotFound 任务状态不存在（任务不存在，或者已经超过保留时间）
+var ErrJobStatusNotFound = errors.New("job status not found")
+
+// JobState 持久化任务的状态
+type JobState string
+
+const (
+	// JobPending 等待执行
+	JobPending JobState = "pending"
+	// JobRunning 执行中
+	JobRunning JobState = "running"
+	// JobRetrying 执行失败，等待重试
+	JobRetrying JobState = "retrying"
+	// JobSucceeded 执行成功
+	JobSucceeded JobState = "succeeded"
+	// JobFailed 超过最大执行次数，已保存到FailedJobStore
+	JobFailed JobState = "failed"
+	// JobCancelled 所属的批次已经取消，没有执行
+	JobCancelled JobState = "cancelled"
+)
+
+// JobStatus 持久化任务的状态、进度和结果，集群中任意节点都可以通过Queue.Status查询
+type JobStatus struct {
+	ID    string   `json:"id"`
+	Queue string   `json:"queue"`
+	Name  string   `json:"name"`
+	State JobState `json:"state"`
+	// Progress 进度（0-100），通过ReportProgress设置
+	Progress int    `json:"progress"`
+	Message  string `json:"message,omitempty"`
+	Attempts int    `json:"attempts"`
+	// Result 使用RegisterWithResult注册的任务的结果（json编码）
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	DispatchedAt time.Time       `json:"dispatched_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// jobStatusSetScript 设置任务状态的字段，并更新过期时间
+//
+//	KEYS: status
+//	ARGV: ttl(ms), field, value, field, value...
+const jobStatusSetScript = `redis.call('hset', KEYS[1], unpack(ARGV, 2))
+redis.call('pexpire', KEYS[1], ARGV[1])
+return 1
+`
+
+// jobStatusProgressScript 设置执行中任务的进度，任务状态不存在时不设置
+//
+//	KEYS: status
+//	ARGV: progress, message, updated_at
+const jobStatusProgressScript = `if redis.call('exists', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('hset', KEYS[1], 'progress', ARGV[1], 'message', ARGV[2], 'updated_at', ARGV[3])
+return 1
+`
+
+type queueJobKey struct{}
+
+type queueJob struct {
+	queue *Queue
+	job   *QueuedJob
+}
+
+// JobIDFromContext 返回执行中的持久化任务的ID，不是持久化任务时返回空字符串
+func JobIDFromContext(ctx context.Context) string {
+	if qj, ok := ctx.Value(queueJobKey{}).(*queueJob); ok {
+		return qj.job.ID
+	}
+	return ""
+}
+
+// BatchIDFromContext 返回执行中的持久化任务所属的批次ID，不属于批次时返回空字符串
+func BatchIDFromContext(ctx context.Context) string {
+	if qj, ok := ctx.Value(queueJobKey{}).(*queueJob); ok {
+		return qj.job.BatchID
+	}
+	return ""
+}
+
+// ReportProgress 报告执行中的持久化任务的进度（0-100）和说明，可以通过Queue.Status查询。
+// 不是持久化任务，或队列没有记录任务状态时不做任何操作
+func ReportProgress(ctx context.Context, progress int, message string) error {
+	qj, ok := ctx.Value(queueJobKey{}).(*queueJob)
+	if !ok || qj.queue.statusTTL <= 0 {
+		return nil
+	}
+	progress = min(max(progress, 0), 100)
+	return qj.queue.cache.Script(jobStatusProgressScript).Run(ctx,
+		[]string{qj.queue.statusKey(qj.job.ID)},
+		progress, message, time.Now().UnixMilli()).Err()
+}
+
+// statusKey 任务状态的redis key
+func (q *Queue) statusKey(id string) string {
+	return q.key("status:" + id)
+}
+
+// setStatus 设置任务的状态，fields为需要额外设置的字段
+func (q *Queue) setStatus(ctx context.Context, job *QueuedJob, state JobState, ttl time.Duration, fields ...any) {
+	if q.statusTTL <= 0 {
+		return
+	}
+	args := append([]any{
+		ttl.Milliseconds(),
+		"id", job.ID,
+		"name", job.Name,
+		"state", string(state),
+		"attempts", job.Attempts,
+		"dispatched_at", job.DispatchedAt.UnixMilli(),
+		"updated_at", time.Now().UnixMilli(),
+	}, fields...)
+	if err := q.cache.Script(jobStatusSetScript).Run(ctx,
+		[]string{q.statusKey(job.ID)},
+		args...).Err(); err != nil {
+		q.logger.WithContext(ctx).Errorf("[Queue]set status of job \"%s\"(%s) of queue \"%s\" to %s failed: %v", job.Name, job.ID, q.name, state, err)
+	}
+}
+
+// Status 查询任务的状态、进度和结果，状态不存在时返回ErrJobStatusNotFound
+func (q *Queue) Status(ctx context.Context, id string) (*JobStatus, error) {
+	fields, err := q.cache.HGetAll(ctx, q.statusKey(id), nil)
+	if err != nil {
+		return nil, err
+	} else if len(fields) == 0 {
+		return nil, errors.Wrapf(ErrJobStatusNotFound, "id: %s", id)
+	}
+
+	status := &JobStatus{
+		ID:           fields["id"],
+		Queue:        q.name,
+		Name:         fields["name"],
+		State:        JobState(fields["state"]),
+		Message:      fields["message"],
+		Error:        fields["error"],
+		DispatchedAt: parseUnixMilli(fields["dispatched_at"]),
+		UpdatedAt:    parseUnixMilli(fields["updated_at"]),
+	}
+	status.Progress, _ = strconv.Atoi(fields["progress"])
+	status.Attempts, _ = strconv.Atoi(fields["attempts"])
+	if result := fields["result"]; result != "" {
+		status.Result = json.RawMessage(result)
+	}
+	return status, nil
+}
+
+// JobResult 查询任务的状态，任务执行成功时将结果解码为R
+// example: report, status, err := worker.JobResult[*Report](ctx, queue, id)
+func JobResult[R any](ctx context.Context, q *Queue, id string) (R, *JobStatus, error) {
+	var result R
+	status, err := q.Status(ctx, id)
+	if err != nil || status.State != JobSucceeded || len(status.Result) == 0 {
+		return result, status, err
+	}
+	if err = json.Unmarshal(status.Result, &result); err != nil {
+		return result, status, errors.Wrapf(err, "unmarshal result of job \"%s\"(%s) failed", status.Name, id)
+	}
+	return result, status, nil
+}
+
+func parseUnixMilli(s string) time.Time {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"github.com/pkg/errors"
+	pHttp "gopkg.in/go-mixed/kratos-packages.v2/pkg/http"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type jobStatusHandler struct {
+	queue *Queue
+}
+
+// NewJobStatusHandler 查询持久化任务状态的接口，挂载在任意前缀下：
+//   - GET {prefix}/{id}   返回任务的状态、进度和结果（Queue.Status）
+//
+// 通常在Dispatch之后返回202和该接口的地址，由客户端轮询
+// example: httpServer.HandlePrefix("/jobs", worker.NewJobStatusHandler(queue))
+func NewJobStatusHandler(q *Queue) http.Handler {
+	return &jobStatusHandler{queue: q}
+}
+
+func (h *jobStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	id := path.Base(strings.TrimRight(r.URL.Path, "/"))
+	if id == "" || id == "." || id == "/" {
+		writeError(w, http.StatusNotFound, errors.Errorf("path %s is not found", r.URL.Path))
+		return
+	}
+
+	status, err := h.queue.Status(r.Context(), id)
+	if errors.Is(err, ErrJobStatusNotFound) {
+		writeError(w, http.StatusNotFound, err)
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+	} else {
+		writeJSON(w, http.StatusOK, pHttp.CommonResponseT[*JobStatus]{Data: status})
+	}
+}